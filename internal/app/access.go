@@ -0,0 +1,203 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+// AccessControl decides which Telegram users and chats may use the bot.
+// Users and chats listed here are always allowed; admins can additionally
+// allow or deny others at runtime with the /allow and /deny commands.
+type AccessControl struct {
+	AdminUserIds    map[int64]bool
+	AllowedUserIds  map[int64]bool
+	AllowedChatIds  map[int64]bool
+	ReplyToRejected bool
+}
+
+// NewAccessControlFromEnv reads the access control settings from the
+// ADMIN_USER_IDS, ALLOWED_USER_IDS, ALLOWED_CHAT_IDS and REPLY_TO_REJECTED
+// environment variables.
+func NewAccessControlFromEnv() (*AccessControl, error) {
+	adminUserIds, err := parseIdList(os.Getenv("ADMIN_USER_IDS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ADMIN_USER_IDS: %w", err)
+	}
+
+	allowedUserIds, err := parseIdList(os.Getenv("ALLOWED_USER_IDS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ALLOWED_USER_IDS: %w", err)
+	}
+
+	allowedChatIds, err := parseIdList(os.Getenv("ALLOWED_CHAT_IDS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ALLOWED_CHAT_IDS: %w", err)
+	}
+
+	return &AccessControl{
+		AdminUserIds:    adminUserIds,
+		AllowedUserIds:  allowedUserIds,
+		AllowedChatIds:  allowedChatIds,
+		ReplyToRejected: os.Getenv("REPLY_TO_REJECTED") == "true",
+	}, nil
+}
+
+// IsEnabled reports whether any access restriction is configured. Without
+// admins or allowlisted users and chats the bot stays open to everyone.
+func (ac *AccessControl) IsEnabled() bool {
+	if ac == nil {
+		return false
+	}
+	return len(ac.AdminUserIds) > 0 || len(ac.AllowedUserIds) > 0 || len(ac.AllowedChatIds) > 0
+}
+
+func (ac *AccessControl) IsAdmin(userID int64) bool {
+	return ac != nil && ac.AdminUserIds[userID]
+}
+
+func parseIdList(value string) (map[int64]bool, error) {
+	ids := make(map[int64]bool)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid ID", field)
+		}
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+func (app *App) isUpdateAllowed(ctx context.Context, update *tgbotapi.Update) bool {
+	if !app.Access.IsEnabled() {
+		return true
+	}
+
+	if user := update.SentFrom(); user != nil {
+		if app.Access.IsAdmin(user.ID) || app.Access.AllowedUserIds[user.ID] {
+			return true
+		}
+		entry, err := app.FindAllowlistEntry(ctx, models.AllowlistKindUser, user.ID)
+		if err != nil {
+			fmt.Printf("Error checking allowlist: %v\n", err)
+			return false
+		}
+		if entry != nil {
+			return true
+		}
+	}
+
+	if chat := update.FromChat(); chat != nil {
+		if app.Access.AllowedChatIds[chat.ID] {
+			return true
+		}
+		entry, err := app.FindAllowlistEntry(ctx, models.AllowlistKindChat, chat.ID)
+		if err != nil {
+			fmt.Printf("Error checking allowlist: %v\n", err)
+			return false
+		}
+		if entry != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (app *App) rejectUpdate(ctx context.Context, update *tgbotapi.Update) {
+	var userID, chatID int64
+	if user := update.SentFrom(); user != nil {
+		userID = user.ID
+	}
+	if chat := update.FromChat(); chat != nil {
+		chatID = chat.ID
+	}
+
+	fmt.Printf("Rejected update ID: %d from user %d in chat %d\n", update.UpdateID, userID, chatID)
+
+	if app.Access.ReplyToRejected && chatID != 0 {
+		app.Bot.SendMessage(ctx, chatID, "Sorry, you are not allowed to use this bot.")
+	}
+}
+
+// handleAllowCommand handles "/allow" and "/deny". Without arguments the
+// current chat is affected, otherwise "user <id>" or "chat <id>" is expected.
+func (app *App) handleAllowCommand(ctx context.Context, message *tgbotapi.Message, allow bool) {
+	if message.From == nil || !app.Access.IsAdmin(message.From.ID) {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Only admins can change the allowlist.")
+		return
+	}
+
+	kind, entityID, err := parseAllowCommandArguments(message)
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, err.Error())
+		return
+	}
+
+	subject := fmt.Sprintf("%s%s %d", strings.ToUpper(kind[:1]), kind[1:], entityID)
+
+	entry, err := app.FindAllowlistEntry(ctx, kind, entityID)
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to update the allowlist")
+		return
+	}
+
+	if allow {
+		if entry == nil {
+			_, err = app.StoreAllowlistEntry(ctx, &models.AllowlistEntry{
+				Kind:     kind,
+				EntityId: entityID,
+			})
+			if err != nil {
+				app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to update the allowlist")
+				return
+			}
+		}
+		app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("%s is now allowed.", subject))
+		return
+	}
+
+	if entry == nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("%s is not in the allowlist.", subject))
+		return
+	}
+
+	if err := app.DeleteAllowlistEntry(ctx, entry); err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to update the allowlist")
+		return
+	}
+	app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("%s is no longer allowed.", subject))
+}
+
+func parseAllowCommandArguments(message *tgbotapi.Message) (string, int64, error) {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		return models.AllowlistKindChat, message.Chat.ID, nil
+	}
+
+	usage := fmt.Errorf("Usage: /%s [user|chat <id>]", message.Command())
+	if len(args) != 2 {
+		return "", 0, usage
+	}
+
+	kind := strings.ToLower(args[0])
+	if kind != models.AllowlistKindUser && kind != models.AllowlistKindChat {
+		return "", 0, usage
+	}
+
+	entityID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return "", 0, usage
+	}
+
+	return kind, entityID, nil
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+)
+
+func newTestAccessControl() *AccessControl {
+	return &AccessControl{
+		AdminUserIds:   map[int64]bool{1: true},
+		AllowedUserIds: map[int64]bool{2: true},
+		AllowedChatIds: map[int64]bool{100: true},
+	}
+}
+
+func TestHandleUpdateAccessControl(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		chatID        int64
+		userID        int64
+		allowlistUser bool
+		expectStored  bool
+	}{
+		{
+			name:         "Admin is allowed in any chat",
+			chatID:       999,
+			userID:       1,
+			expectStored: true,
+		},
+		{
+			name:         "Configured user is allowed in any chat",
+			chatID:       999,
+			userID:       2,
+			expectStored: true,
+		},
+		{
+			name:         "Any user is allowed in a configured chat",
+			chatID:       100,
+			userID:       3,
+			expectStored: true,
+		},
+		{
+			name:          "User added with /allow is allowed",
+			chatID:        999,
+			userID:        3,
+			allowlistUser: true,
+			expectStored:  true,
+		},
+		{
+			name:         "Unknown user in unknown chat is rejected",
+			chatID:       999,
+			userID:       3,
+			expectStored: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := testutils.NewMockDatabaseClient()
+			mockBot := testutils.NewMockTelegramBot()
+			app := &App{DB: mockDB, Bot: mockBot, Access: newTestAccessControl()}
+
+			if tt.allowlistUser {
+				admin := testutils.NewTestCommandUpdate(1, tt.chatID, 1, "/allow user 3")
+				app.handleUpdate(ctx, admin)
+			}
+
+			app.handleUpdate(ctx, testutils.NewTestUpdateFromUser(2, tt.chatID, tt.userID, "Lunch 15.50 #food"))
+
+			spending, _ := mockDB.FindSpendingByMessageId(ctx, tt.chatID, 2)
+			if tt.expectStored && spending == nil {
+				t.Errorf("Expected spending to be stored")
+			}
+			if !tt.expectStored && spending != nil {
+				t.Errorf("Expected spending to be rejected")
+			}
+		})
+	}
+}
+
+func TestHandleAllowCommand(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot, Access: newTestAccessControl()}
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(1, 500, 1, "/allow"))
+	mockBot.VerifyMessage(t, "Chat 500 is now allowed.")
+
+	app.handleUpdate(ctx, testutils.NewTestUpdateFromUser(2, 500, 3, "Coffee 3"))
+	if spending, _ := mockDB.FindSpendingByMessageId(ctx, 500, 2); spending == nil {
+		t.Errorf("Expected spending in allowed chat to be stored")
+	}
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(3, 500, 1, "/deny chat 500"))
+	mockBot.VerifyMessage(t, "Chat 500 is no longer allowed.")
+
+	app.handleUpdate(ctx, testutils.NewTestUpdateFromUser(4, 500, 3, "Coffee 3"))
+	if spending, _ := mockDB.FindSpendingByMessageId(ctx, 500, 4); spending != nil {
+		t.Errorf("Expected spending in denied chat to be rejected")
+	}
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(5, 100, 2, "/allow user 3"))
+	mockBot.VerifyMessage(t, "Only admins can change the allowlist.")
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+func (app *App) StoreAllowlistEntry(ctx context.Context, entry *models.AllowlistEntry) (*models.AllowlistEntry, error) {
+	entry, err := app.DB.CreateAllowlistEntry(ctx, entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store allowlist entry: %w", err)
+	}
+	return entry, nil
+}
+
+func (app *App) FindAllowlistEntry(ctx context.Context, kind string, entityID int64) (*models.AllowlistEntry, error) {
+	entry, err := app.DB.FindAllowlistEntry(ctx, kind, entityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find allowlist entry: %w", err)
+	}
+	return entry, nil
+}
+
+func (app *App) DeleteAllowlistEntry(ctx context.Context, entry *models.AllowlistEntry) error {
+	err := app.DB.DeleteAllowlistEntry(ctx, entry)
+	if err != nil {
+		return fmt.Errorf("failed to delete allowlist entry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,608 @@
+package app
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/internal/database"
+	"github.com/kiasaty/spendings-tracker/models"
+	"github.com/kiasaty/spendings-tracker/pkg/extractors"
+)
+
+// openAPISpec describes the API served by serve-api
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+const (
+	defaultAPIAddress = "localhost:8080"
+	defaultAPILimit   = 50
+	maxAPILimit       = 500
+	// apiDateLayout is how dates without a time are written in queries
+	apiDateLayout = "2006-01-02"
+	// maxAPIBodySize limits the size of request bodies
+	maxAPIBodySize = 1 << 20
+)
+
+// apiError is an error answered with its HTTP status and message.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func apiErrorf(status int, format string, args ...any) error {
+	return &apiError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// apiSpending is a spending as the API returns it
+type apiSpending struct {
+	ID                uint      `json:"id"`
+	ChatID            int64     `json:"chat_id"`
+	SenderID          int64     `json:"sender_id"`
+	Sender            string    `json:"sender"`
+	Cost              float64   `json:"cost"`
+	Currency          string    `json:"currency"`
+	Description       string    `json:"description"`
+	Date              time.Time `json:"date"`
+	Tags              []string  `json:"tags"`
+	ManuallyCorrected bool      `json:"manually_corrected"`
+}
+
+// apiSpendingInput is the body of requests creating and updating spendings.
+// Fields left out keep their value on updates.
+type apiSpendingInput struct {
+	ChatID      *int64    `json:"chat_id"`
+	SenderID    *int64    `json:"sender_id"`
+	Sender      *string   `json:"sender"`
+	Cost        *float64  `json:"cost"`
+	Description *string   `json:"description"`
+	Date        *string   `json:"date"`
+	Tags        *[]string `json:"tags"`
+}
+
+type apiSpendingList struct {
+	Spendings []apiSpending `json:"spendings"`
+	Total     int64         `json:"total"`
+	Offset    int           `json:"offset"`
+	Limit     int           `json:"limit"`
+}
+
+type apiTag struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type apiReport struct {
+	From     *string    `json:"from"`
+	To       *string    `json:"to"`
+	Currency string     `json:"currency"`
+	Total    float64    `json:"total"`
+	Tags     []apiTotal `json:"tags"`
+	People   []apiTotal `json:"people"`
+}
+
+type apiTotal struct {
+	Name  string  `json:"name"`
+	Total float64 `json:"total"`
+}
+
+// ServeAPI runs the serve-api subcommand, which serves the JSON API
+// described in openapi.yaml and the dashboard until ctx is cancelled.
+// Requests need an API token created with the /api_token command, or a
+// login link to the dashboard sent by /dashboard, and only see the
+// spendings of the chat they were created in.
+func (app *App) ServeAPI(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("serve-api", flag.ContinueOnError)
+	flags.SetOutput(out)
+	address := flags.String("addr", defaultAPIAddress, "address to listen on")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	server := &http.Server{
+		Addr:              *address,
+		Handler:           app.WebHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	fmt.Fprintf(out, "Serving the API on http://%s/api and the dashboard on http://%s/dashboard\n", *address, *address)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down API server: %w", err)
+	}
+	return nil
+}
+
+// WebHandler returns the handler of everything serve-api serves: the JSON
+// API and the dashboard.
+func (app *App) WebHandler() http.Handler {
+	dashboard := app.DashboardHandler()
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", app.APIHandler())
+	mux.Handle("/dashboard", dashboard)
+	mux.Handle("/dashboard/", dashboard)
+	return mux
+}
+
+// APIHandler returns the handler of the JSON API. Only its specification
+// can be read without an API token.
+func (app *App) APIHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(openAPISpec)
+	})
+	mux.HandleFunc("GET /api/spendings", app.apiHandler(app.apiListSpendings))
+	mux.HandleFunc("POST /api/spendings", app.apiHandler(app.apiCreateSpending))
+	mux.HandleFunc("GET /api/spendings/{id}", app.apiHandler(app.apiGetSpending))
+	mux.HandleFunc("PATCH /api/spendings/{id}", app.apiHandler(app.apiUpdateSpending))
+	mux.HandleFunc("DELETE /api/spendings/{id}", app.apiHandler(app.apiDeleteSpending))
+	mux.HandleFunc("GET /api/tags", app.apiHandler(app.apiListTags))
+	mux.HandleFunc("GET /api/reports", app.apiHandler(app.apiReport))
+	return mux
+}
+
+// apiHandler turns a function returning the status and body of a response
+// into a handler writing them as JSON. Requests are only handled with a
+// valid API token, which the function gets to limit them to its chat, and
+// read-only tokens can't change anything. Errors other than apiErrors are
+// logged and answered with a generic message.
+func (app *App) apiHandler(handle func(r *http.Request, token *models.APIToken) (int, any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var status int
+		var body any
+		token, err := app.authenticateAPIRequest(r)
+		if err == nil {
+			status, body, err = handle(r, token)
+		}
+		if err != nil {
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) {
+				fmt.Printf("Error handling %s %s: %v\n", r.Method, r.URL.Path, err)
+				apiErr = &apiError{status: http.StatusInternalServerError, message: "internal error"}
+			}
+			if apiErr.status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			}
+			status, body = apiErr.status, map[string]string{"error": apiErr.message}
+		}
+
+		if body == nil {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			fmt.Printf("Error writing response to %s %s: %v\n", r.Method, r.URL.Path, err)
+		}
+	}
+}
+
+// authenticateAPIRequest returns the API token of the request's
+// Authorization header, checking that it allows the request's method.
+func (app *App) authenticateAPIRequest(r *http.Request) (*models.APIToken, error) {
+	scheme, secret, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || secret == "" {
+		return nil, apiErrorf(http.StatusUnauthorized, "an API token is required, create one with /api_token")
+	}
+
+	token, err := app.authenticateAPIToken(r.Context(), strings.TrimSpace(secret))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, apiErrorf(http.StatusUnauthorized, "invalid or revoked API token")
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && token.Scope != models.APITokenScopeWrite {
+		return nil, apiErrorf(http.StatusForbidden, "the API token is read-only")
+	}
+	return token, nil
+}
+
+// apiChatID checks that the chat_id of a request, if any, is the chat of
+// its token, and returns the chat of the token.
+func apiChatID(value string, token *models.APIToken) (int64, error) {
+	chatID, err := apiQueryInt(value, "chat_id")
+	if err != nil {
+		return 0, err
+	}
+	if chatID != 0 && chatID != token.ChatId {
+		return 0, apiErrorf(http.StatusForbidden, "the API token is for another chat")
+	}
+	return token.ChatId, nil
+}
+
+func (app *App) apiListSpendings(r *http.Request, token *models.APIToken) (int, any, error) {
+	query := r.URL.Query()
+	filter := database.SpendingFilter{Text: query.Get("text"), Tags: query["tag"], Limit: defaultAPILimit}
+
+	var err error
+	if filter.ChatId, err = apiChatID(query.Get("chat_id"), token); err != nil {
+		return 0, nil, err
+	}
+	if filter.StartDate, filter.EndDate, err = apiDateRange(query.Get("from"), query.Get("to")); err != nil {
+		return 0, nil, err
+	}
+	for parameter, operator := range map[string]string{"min_cost": ">=", "max_cost": "<="} {
+		if value := query.Get(parameter); value != "" {
+			amount, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return 0, nil, apiErrorf(http.StatusBadRequest, "invalid %s %q", parameter, value)
+			}
+			filter.Costs = append(filter.Costs, database.CostCondition{Operator: operator, Amount: amount})
+		}
+	}
+	if value := query.Get("offset"); value != "" {
+		if filter.Offset, err = strconv.Atoi(value); err != nil || filter.Offset < 0 {
+			return 0, nil, apiErrorf(http.StatusBadRequest, "invalid offset %q", value)
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil || filter.Limit < 1 || filter.Limit > maxAPILimit {
+			return 0, nil, apiErrorf(http.StatusBadRequest, "invalid limit %q, expected 1 to %d", value, maxAPILimit)
+		}
+	}
+
+	spendings, total, err := app.FindSpendings(r.Context(), filter)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	list := apiSpendingList{Spendings: make([]apiSpending, 0, len(spendings)), Total: total, Offset: filter.Offset, Limit: filter.Limit}
+	for _, spending := range spendings {
+		list.Spendings = append(list.Spendings, app.toAPISpending(spending))
+	}
+	return http.StatusOK, list, nil
+}
+
+func (app *App) apiGetSpending(r *http.Request, token *models.APIToken) (int, any, error) {
+	spending, err := app.apiFindSpending(r, token)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, app.toAPISpending(*spending), nil
+}
+
+// apiCreateSpending creates a spending in the token's chat like a message
+// would. The cost, date and tags are extracted from the description when
+// they aren't given.
+func (app *App) apiCreateSpending(r *http.Request, token *models.APIToken) (int, any, error) {
+	var input apiSpendingInput
+	if err := decodeAPIBody(r, &input); err != nil {
+		return 0, nil, err
+	}
+	if input.ChatID != nil && *input.ChatID != token.ChatId {
+		return 0, nil, apiErrorf(http.StatusForbidden, "the API token is for another chat")
+	}
+
+	spending := models.Spending{ChatId: token.ChatId}
+	if input.SenderID != nil {
+		spending.SenderId = *input.SenderID
+	}
+	if input.Sender != nil {
+		spending.SenderName = *input.Sender
+	}
+	if input.Description != nil {
+		spending.Description = *input.Description
+	}
+
+	if input.Cost != nil {
+		spending.Cost = *input.Cost
+	} else if price, err := extractors.ExtractPrice(spending.Description); err == nil {
+		spending.Cost = price
+	} else {
+		return 0, nil, apiErrorf(http.StatusBadRequest, "cost is required when the description has no price")
+	}
+
+	if input.Date != nil {
+		date, err := parseAPIDate(*input.Date)
+		if err != nil {
+			return 0, nil, err
+		}
+		spending.SpentAt = date
+	} else if date, err := extractors.ExtractDate(spending.Description); err == nil {
+		spending.SpentAt = date
+	} else {
+		spending.SpentAt = time.Now()
+	}
+
+	tags := extractors.ExtractHashtags(spending.Description)
+	if input.Tags != nil {
+		tags = *input.Tags
+	}
+
+	if err := app.saveAPISpending(r.Context(), &spending, tags, true); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, app.toAPISpending(spending), nil
+}
+
+// apiUpdateSpending changes the given fields of a spending and marks it as
+// corrected by hand, so reparsing its description doesn't undo the change.
+func (app *App) apiUpdateSpending(r *http.Request, token *models.APIToken) (int, any, error) {
+	spending, err := app.apiFindSpending(r, token)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var input apiSpendingInput
+	if err := decodeAPIBody(r, &input); err != nil {
+		return 0, nil, err
+	}
+	if input.ChatID != nil && *input.ChatID != spending.ChatId {
+		return 0, nil, apiErrorf(http.StatusBadRequest, "the chat of a spending can't be changed")
+	}
+
+	updated := *spending
+	if input.SenderID != nil {
+		updated.SenderId = *input.SenderID
+	}
+	if input.Sender != nil {
+		updated.SenderName = *input.Sender
+	}
+	if input.Cost != nil {
+		updated.Cost = *input.Cost
+	}
+	if input.Description != nil {
+		updated.Description = *input.Description
+	}
+	if input.Date != nil {
+		if updated.SpentAt, err = parseAPIDate(*input.Date); err != nil {
+			return 0, nil, err
+		}
+	}
+	tags := spendingTagNames(*spending)
+	if input.Tags != nil {
+		tags = *input.Tags
+	}
+	updated.ManuallyCorrected = true
+	updated.Tags = nil
+
+	resplit := updated.Cost != spending.Cost || updated.Description != spending.Description
+	if err := app.saveAPISpending(r.Context(), &updated, tags, resplit); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, app.toAPISpending(updated), nil
+}
+
+func (app *App) apiDeleteSpending(r *http.Request, token *models.APIToken) (int, any, error) {
+	spending, err := app.apiFindSpending(r, token)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := app.DeleteSpending(r.Context(), spending); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+func (app *App) apiListTags(r *http.Request, token *models.APIToken) (int, any, error) {
+	chatID, err := apiChatID(r.URL.Query().Get("chat_id"), token)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	tags, err := app.FindTags(r.Context(), chatID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := make([]apiTag, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, apiTag{ID: tag.ID, Name: tag.Name})
+	}
+	return http.StatusOK, result, nil
+}
+
+// apiReport sums up the spendings of a period like /report does. The period
+// is one of those of /list, or the range given by from and to.
+func (app *App) apiReport(r *http.Request, token *models.APIToken) (int, any, error) {
+	query := r.URL.Query()
+	chatID, err := apiChatID(query.Get("chat_id"), token)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	filter := database.SpendingFilter{ChatId: chatID}
+	if query.Get("from") != "" || query.Get("to") != "" {
+		if filter.StartDate, filter.EndDate, err = apiDateRange(query.Get("from"), query.Get("to")); err != nil {
+			return 0, nil, err
+		}
+	} else {
+		page, ok := listPage(chatID, query.Get("period"), time.Now())
+		if !ok {
+			return 0, nil, apiErrorf(http.StatusBadRequest, "invalid period %q, expected today, week, month, last_month, year or all", query.Get("period"))
+		}
+		filter.StartDate, filter.EndDate = page.filter.StartDate, page.filter.EndDate
+	}
+
+	spendings, _, err := app.FindSpendings(r.Context(), filter)
+	if err != nil {
+		return 0, nil, err
+	}
+	summary := newSpendingReport(spendings)
+
+	report := apiReport{
+		Currency: app.Currency,
+		Total:    summary.Total,
+		Tags:     make([]apiTotal, 0, len(summary.Tags)),
+		People:   make([]apiTotal, 0, len(summary.People)),
+	}
+	if !filter.StartDate.IsZero() {
+		from := filter.StartDate.Format(apiDateLayout)
+		report.From = &from
+	}
+	if !filter.EndDate.IsZero() {
+		to := filter.EndDate.Format(apiDateLayout)
+		report.To = &to
+	}
+	for _, tag := range summary.tagNames() {
+		report.Tags = append(report.Tags, apiTotal{Name: tag, Total: summary.Tags[tag]})
+	}
+	for _, person := range summary.personNames() {
+		report.People = append(report.People, apiTotal{Name: person, Total: summary.People[person]})
+	}
+	return http.StatusOK, report, nil
+}
+
+// saveAPISpending stores a new or changed spending with its tags. Group
+// spendings are split again between the members mentioned in their
+// description when split is set.
+func (app *App) saveAPISpending(ctx context.Context, spending *models.Spending, tagNames []string, split bool) error {
+	// Telegram is asked before the transaction, so the database isn't locked
+	// while waiting for it
+	if split {
+		isGroup, err := app.isGroupChatID(ctx, spending.ChatId)
+		if err != nil {
+			return err
+		}
+		split = isGroup
+	}
+
+	var tags []models.Tag
+	err := app.withTransaction(ctx, func(tx *App) error {
+		var err error
+		tags, err = tx.findOrCreateTags(ctx, tagNames)
+		if err != nil {
+			return err
+		}
+
+		if spending.ID == 0 {
+			_, err = tx.StoreSpending(ctx, spending)
+		} else {
+			_, err = tx.UpdateSpending(ctx, spending)
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.SyncSpendingTags(ctx, spending, &tags); err != nil {
+			return err
+		}
+
+		if !split {
+			return nil
+		}
+		return tx.splitSpending(ctx, spending, extractors.ExtractMentions(spending.Description))
+	})
+	if err != nil {
+		return err
+	}
+
+	spending.Tags = tags
+	return nil
+}
+
+// apiFindSpending returns the spending with the ID in the request's path.
+// Spendings of other chats than the token's are treated as missing.
+func (app *App) apiFindSpending(r *http.Request, token *models.APIToken) (*models.Spending, error) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return nil, apiErrorf(http.StatusNotFound, "spending not found")
+	}
+
+	spending, err := app.FindSpending(r.Context(), uint(id))
+	if err != nil {
+		return nil, err
+	}
+	if spending == nil || spending.ChatId != token.ChatId {
+		return nil, apiErrorf(http.StatusNotFound, "spending not found")
+	}
+	return spending, nil
+}
+
+func (app *App) toAPISpending(spending models.Spending) apiSpending {
+	return apiSpending{
+		ID:                spending.ID,
+		ChatID:            spending.ChatId,
+		SenderID:          spending.SenderId,
+		Sender:            spending.SenderName,
+		Cost:              spending.Cost,
+		Currency:          app.Currency,
+		Description:       spending.Description,
+		Date:              spending.SpentAt.Local(),
+		Tags:              spendingTagNames(spending),
+		ManuallyCorrected: spending.ManuallyCorrected,
+	}
+}
+
+func decodeAPIBody(r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxAPIBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return apiErrorf(http.StatusBadRequest, "invalid request body: %v", err)
+	}
+	return nil
+}
+
+func apiQueryInt(value, name string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, apiErrorf(http.StatusBadRequest, "invalid %s %q", name, value)
+	}
+	return n, nil
+}
+
+// apiDateRange returns the range from the start of the day from to the end
+// of the day to. Either can be empty for an open range.
+func apiDateRange(from, to string) (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+	if from != "" {
+		if start, err = time.ParseInLocation(apiDateLayout, from, time.Local); err != nil {
+			return start, end, apiErrorf(http.StatusBadRequest, "invalid from date %q, expected YYYY-MM-DD", from)
+		}
+	}
+	if to != "" {
+		if end, err = time.ParseInLocation(apiDateLayout, to, time.Local); err != nil {
+			return start, end, apiErrorf(http.StatusBadRequest, "invalid to date %q, expected YYYY-MM-DD", to)
+		}
+		end = end.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	return start, end, nil
+}
+
+// parseAPIDate parses the date of a spending, either a date and time like
+// 2024-05-11T12:30:00+02:00 or a local date like 2024-05-11.
+func parseAPIDate(value string) (time.Time, error) {
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+	if date, err := time.ParseInLocation(apiDateLayout, value, time.Local); err == nil {
+		return date, nil
+	}
+	return time.Time{}, apiErrorf(http.StatusBadRequest, "invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+}
@@ -0,0 +1,339 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+func newAPITestApp(t *testing.T) (*App, http.Handler) {
+	t.Helper()
+
+	app := &App{DB: testutils.NewMockDatabaseClient(), Bot: testutils.NewMockTelegramBot(), Currency: "EUR"}
+	return app, app.APIHandler()
+}
+
+// newAPITestToken creates an API token for the chat and returns it.
+func newAPITestToken(t *testing.T, app *App, chatID int64, scope string) string {
+	t.Helper()
+
+	token, _, err := app.issueAPIToken(context.Background(), chatID, 1, scope)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return token
+}
+
+// apiRequest sends a request with the API token, unless it is empty, to the
+// API and decodes the JSON response into result, unless it is nil.
+func apiRequest(t *testing.T, handler http.Handler, token, method, path, body string, expectedStatus int, result any) {
+	t.Helper()
+
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != expectedStatus {
+		t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, expectedStatus, recorder.Code, recorder.Body.String())
+	}
+	if result != nil {
+		if err := json.Unmarshal(recorder.Body.Bytes(), result); err != nil {
+			t.Fatalf("%s %s: invalid JSON response %q: %v", method, path, recorder.Body.String(), err)
+		}
+	}
+}
+
+func TestAPISpendings(t *testing.T) {
+	app, handler := newAPITestApp(t)
+	token := newAPITestToken(t, app, 123, models.APITokenScopeWrite)
+	otherToken := newAPITestToken(t, app, 456, models.APITokenScopeWrite)
+
+	var lunch apiSpending
+	apiRequest(t, handler, token, "POST", "/api/spendings", `{"chat_id": 123, "sender": "Sara", "description": "Lunch 25.50 #food 2024-05-11"}`, http.StatusCreated, &lunch)
+	if lunch.ID == 0 || lunch.ChatID != 123 || lunch.Cost != 25.5 || lunch.Sender != "Sara" || lunch.Currency != "EUR" ||
+		lunch.Date.Format(apiDateLayout) != "2024-05-11" || strings.Join(lunch.Tags, ",") != "food" || lunch.ManuallyCorrected {
+		t.Errorf("Expected the spending extracted from the description, got %+v", lunch)
+	}
+
+	var taxi apiSpending
+	apiRequest(t, handler, token, "POST", "/api/spendings", `{"cost": 12, "description": "Taxi home", "date": "2024-05-12", "tags": ["transport"]}`, http.StatusCreated, &taxi)
+	if taxi.ChatID != 123 || taxi.Cost != 12 || taxi.Date.Format(apiDateLayout) != "2024-05-12" || strings.Join(taxi.Tags, ",") != "transport" {
+		t.Errorf("Expected the given cost, date and tags, got %+v", taxi)
+	}
+	apiRequest(t, handler, otherToken, "POST", "/api/spendings", `{"description": "Books 40 2024-05-13"}`, http.StatusCreated, nil)
+
+	tests := []struct {
+		query    string
+		expected []uint
+		total    int64
+	}{
+		{"", []uint{2, 1}, 2},
+		{"?chat_id=123", []uint{2, 1}, 2},
+		{"?tag=food", []uint{1}, 1},
+		{"?from=2024-05-12&to=2024-05-12", []uint{2}, 1},
+		{"?from=2024-05-13", nil, 0},
+		{"?min_cost=20&max_cost=30", []uint{1}, 1},
+		{"?text=TAXI", []uint{2}, 1},
+		{"?limit=1&offset=1", []uint{1}, 2},
+	}
+	for _, tt := range tests {
+		var list apiSpendingList
+		apiRequest(t, handler, token, "GET", "/api/spendings"+tt.query, "", http.StatusOK, &list)
+
+		var ids []uint
+		for _, spending := range list.Spendings {
+			ids = append(ids, spending.ID)
+		}
+		if len(ids) != len(tt.expected) || list.Total != tt.total {
+			t.Errorf("%q: expected spendings %v of %d, got %v of %d", tt.query, tt.expected, tt.total, ids, list.Total)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.expected[i] {
+				t.Errorf("%q: expected spendings %v, got %v", tt.query, tt.expected, ids)
+				break
+			}
+		}
+	}
+
+	var updated apiSpending
+	apiRequest(t, handler, token, "PATCH", "/api/spendings/1", `{"cost": 24, "tags": ["food", "work"]}`, http.StatusOK, &updated)
+	if updated.Cost != 24 || updated.Description != lunch.Description || strings.Join(updated.Tags, ",") != "food,work" || !updated.ManuallyCorrected {
+		t.Errorf("Expected the cost and tags to be corrected by hand, got %+v", updated)
+	}
+
+	var found apiSpending
+	apiRequest(t, handler, token, "GET", "/api/spendings/1", "", http.StatusOK, &found)
+	if found.Cost != 24 || strings.Join(found.Tags, ",") != "food,work" || !found.ManuallyCorrected {
+		t.Errorf("Expected the updated spending to be stored, got %+v", found)
+	}
+	if spending, _ := app.FindSpending(context.Background(), 1); spending == nil || !spending.ManuallyCorrected {
+		t.Errorf("Expected the spending to be marked as corrected by hand, got %+v", spending)
+	}
+
+	apiRequest(t, handler, token, "DELETE", "/api/spendings/2", "", http.StatusNoContent, nil)
+	apiRequest(t, handler, token, "GET", "/api/spendings/2", "", http.StatusNotFound, nil)
+	apiRequest(t, handler, token, "DELETE", "/api/spendings/2", "", http.StatusNotFound, nil)
+}
+
+func TestAPIGroupSpending(t *testing.T) {
+	app, handler := newAPITestApp(t)
+	token := newAPITestToken(t, app, -100, models.APITokenScopeWrite)
+
+	// Nobody wrote in the group yet, but it is one
+	var dinner apiSpending
+	apiRequest(t, handler, token, "POST", "/api/spendings", `{"description": "Dinner 30 @alice @bob"}`, http.StatusCreated, &dinner)
+
+	spending, _ := app.FindSpending(context.Background(), dinner.ID)
+	if spending == nil || len(spending.Shares) != 2 || spending.Shares[0].Amount != 15 {
+		t.Fatalf("Expected the spending to be split between alice and bob, got %+v", spending)
+	}
+
+	apiRequest(t, handler, token, "PATCH", "/api/spendings/1", `{"cost": 31}`, http.StatusOK, nil)
+	spending, _ = app.FindSpending(context.Background(), dinner.ID)
+	if len(spending.Shares) != 2 || spending.Shares[0].Amount+spending.Shares[1].Amount != 31 {
+		t.Errorf("Expected the changed cost to be split again, got %+v", spending.Shares)
+	}
+
+	// Channels have negative IDs too
+	app.Bot.(*testutils.MockTelegramBot).SetChatType(-200, "channel")
+	channelToken := newAPITestToken(t, app, -200, models.APITokenScopeWrite)
+	var post apiSpending
+	apiRequest(t, handler, channelToken, "POST", "/api/spendings", `{"description": "Ads 50 @alice"}`, http.StatusCreated, &post)
+	if spending, _ := app.FindSpending(context.Background(), post.ID); spending == nil || len(spending.Shares) != 0 {
+		t.Errorf("Expected the channel spending not to be split, got %+v", spending)
+	}
+}
+
+func TestAPITagsAndReport(t *testing.T) {
+	app, handler := newAPITestApp(t)
+	token := newAPITestToken(t, app, 123, models.APITokenScopeWrite)
+	otherToken := newAPITestToken(t, app, 456, models.APITokenScopeWrite)
+
+	for _, body := range []string{
+		`{"sender": "Sara", "description": "Lunch 25 #food #work 2024-05-11"}`,
+		`{"sender": "Ali", "description": "Groceries 10 #food 2024-05-12"}`,
+		`{"sender": "Ali", "description": "Taxi 5 2024-05-12"}`,
+		`{"sender": "Ali", "description": "Rent 900 #rent 2024-06-01"}`,
+	} {
+		apiRequest(t, handler, token, "POST", "/api/spendings", body, http.StatusCreated, nil)
+	}
+	apiRequest(t, handler, otherToken, "POST", "/api/spendings", `{"description": "Books 40 #books 2024-05-13"}`, http.StatusCreated, nil)
+
+	var tags []apiTag
+	apiRequest(t, handler, otherToken, "GET", "/api/tags", "", http.StatusOK, &tags)
+	if len(tags) != 1 || tags[0].Name != "books" {
+		t.Errorf("Expected the tags of chat 456, got %+v", tags)
+	}
+	apiRequest(t, handler, token, "GET", "/api/tags?chat_id=123", "", http.StatusOK, &tags)
+	if len(tags) != 3 {
+		t.Errorf("Expected the 3 tags of chat 123, got %+v", tags)
+	}
+
+	var report apiReport
+	apiRequest(t, handler, token, "GET", "/api/reports?from=2024-05-01&to=2024-05-31", "", http.StatusOK, &report)
+	expected := apiReport{
+		Currency: "EUR",
+		Total:    40,
+		Tags:     []apiTotal{{"food", 35}, {"work", 25}, {"other", 5}},
+		People:   []apiTotal{{"Ali", 15}, {"Sara", 25}},
+	}
+	if report.From == nil || *report.From != "2024-05-01" || report.To == nil || *report.To != "2024-05-31" {
+		t.Errorf("Expected the report of May, got %v to %v", report.From, report.To)
+	}
+	report.From, report.To = nil, nil
+	if got, _ := json.Marshal(report); string(got) != mustJSON(t, expected) {
+		t.Errorf("Expected report %s, got %s", mustJSON(t, expected), got)
+	}
+
+	apiRequest(t, handler, token, "GET", "/api/reports?period=all", "", http.StatusOK, &report)
+	if report.From != nil || report.To != nil || report.Total != 940 {
+		t.Errorf("Expected a report of all time, got %+v", report)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	app, handler := newAPITestApp(t)
+	token := newAPITestToken(t, app, 123, models.APITokenScopeWrite)
+	apiRequest(t, handler, token, "POST", "/api/spendings", `{"chat_id": 123, "description": "Lunch 25"}`, http.StatusCreated, nil)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"POST", "/api/spendings", `{"chat_id": 123, "description": "Lunch"}`, http.StatusBadRequest},
+		{"POST", "/api/spendings", `{"chat_id": 123, "cost": 5, "date": "yesterday"}`, http.StatusBadRequest},
+		{"POST", "/api/spendings", `{"chat_id": 123, "cost": 5, "price": 5}`, http.StatusBadRequest},
+		{"POST", "/api/spendings", `not json`, http.StatusBadRequest},
+		{"PATCH", "/api/spendings/1", `{"chat_id": 456}`, http.StatusBadRequest},
+		{"PATCH", "/api/spendings/99", `{"cost": 5}`, http.StatusNotFound},
+		{"GET", "/api/spendings/abc", "", http.StatusNotFound},
+		{"GET", "/api/spendings?limit=1000", "", http.StatusBadRequest},
+		{"GET", "/api/spendings?from=05/11/2024", "", http.StatusBadRequest},
+		{"GET", "/api/spendings?chat_id=abc", "", http.StatusBadRequest},
+		{"GET", "/api/reports?period=decade", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		var response map[string]string
+		apiRequest(t, handler, token, tt.method, tt.path, tt.body, tt.status, &response)
+		if response["error"] == "" {
+			t.Errorf("%s %s: expected an error message, got %v", tt.method, tt.path, response)
+		}
+	}
+
+	apiRequest(t, handler, token, "GET", "/api/unknown", "", http.StatusNotFound, nil)
+	apiRequest(t, handler, token, "PUT", "/api/spendings/1", `{}`, http.StatusMethodNotAllowed, nil)
+}
+
+func TestAPIAuthentication(t *testing.T) {
+	app, handler := newAPITestApp(t)
+	token := newAPITestToken(t, app, 123, models.APITokenScopeWrite)
+	readToken := newAPITestToken(t, app, 123, models.APITokenScopeRead)
+	otherToken := newAPITestToken(t, app, 456, models.APITokenScopeWrite)
+	apiRequest(t, handler, token, "POST", "/api/spendings", `{"description": "Lunch 25"}`, http.StatusCreated, nil)
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"no token", "", "GET", "/api/spendings", "", http.StatusUnauthorized},
+		{"unknown token", "st_unknown", "GET", "/api/spendings", "", http.StatusUnauthorized},
+		{"read-only list", readToken, "GET", "/api/spendings", "", http.StatusOK},
+		{"read-only get", readToken, "GET", "/api/spendings/1", "", http.StatusOK},
+		{"read-only report", readToken, "GET", "/api/reports", "", http.StatusOK},
+		{"read-only create", readToken, "POST", "/api/spendings", `{"description": "Taxi 5"}`, http.StatusForbidden},
+		{"read-only update", readToken, "PATCH", "/api/spendings/1", `{"cost": 5}`, http.StatusForbidden},
+		{"read-only delete", readToken, "DELETE", "/api/spendings/1", "", http.StatusForbidden},
+		{"other chat's list", otherToken, "GET", "/api/spendings?chat_id=123", "", http.StatusForbidden},
+		{"other chat's tags", otherToken, "GET", "/api/tags?chat_id=123", "", http.StatusForbidden},
+		{"other chat's report", otherToken, "GET", "/api/reports?chat_id=123", "", http.StatusForbidden},
+		{"create in other chat", otherToken, "POST", "/api/spendings", `{"chat_id": 123, "description": "Taxi 5"}`, http.StatusForbidden},
+		{"other chat's spending", otherToken, "GET", "/api/spendings/1", "", http.StatusNotFound},
+		{"update other chat's spending", otherToken, "PATCH", "/api/spendings/1", `{"cost": 5}`, http.StatusNotFound},
+		{"delete other chat's spending", otherToken, "DELETE", "/api/spendings/1", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiRequest(t, handler, tt.token, tt.method, tt.path, tt.body, tt.status, nil)
+		})
+	}
+
+	var list apiSpendingList
+	apiRequest(t, handler, otherToken, "GET", "/api/spendings", "", http.StatusOK, &list)
+	if list.Total != 0 {
+		t.Errorf("Expected no spendings for the other chat, got %+v", list)
+	}
+
+	request := httptest.NewRequest("GET", "/api/spendings", nil)
+	request.Header.Set("Authorization", "Basic "+token)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusUnauthorized || recorder.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected other schemes to be asked for a bearer token, got %d %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestAPIOpenAPISpec(t *testing.T) {
+	_, handler := newAPITestApp(t)
+
+	request := httptest.NewRequest("GET", "/api/openapi.yaml", nil)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Body.String(), "openapi: 3") {
+		t.Errorf("Expected the OpenAPI spec, got %d: %.40s", recorder.Code, recorder.Body.String())
+	}
+	for _, path := range []string{"/api/spendings:", "/api/spendings/{id}:", "/api/tags:", "/api/reports:"} {
+		if !strings.Contains(recorder.Body.String(), path) {
+			t.Errorf("Expected the spec to describe %s", path)
+		}
+	}
+}
+
+func TestServeAPI(t *testing.T) {
+	app, _ := newAPITestApp(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	var out strings.Builder
+	go func() {
+		done <- app.ServeAPI(ctx, []string{"-addr", "127.0.0.1:0"}, &out)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected the server to shut down cleanly, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the server to stop when the context is cancelled")
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,259 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+// tokenPrefix starts every API token and dashboard login token, so they are
+// easy to recognize e.g. when scanning for leaked secrets
+const tokenPrefix = "st_"
+
+func (app *App) StoreAPIToken(ctx context.Context, token *models.APIToken) (*models.APIToken, error) {
+	token, err := app.DB.CreateAPIToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store API token: %w", err)
+	}
+	return token, nil
+}
+
+func (app *App) DeleteAPIToken(ctx context.Context, token *models.APIToken) error {
+	err := app.DB.DeleteAPIToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("failed to delete API token: %w", err)
+	}
+	return nil
+}
+
+func (app *App) FindAPIToken(ctx context.Context, id uint) (*models.APIToken, error) {
+	token, err := app.DB.FindAPIToken(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find API token: %w", err)
+	}
+	return token, nil
+}
+
+func (app *App) GetAPITokensByChat(ctx context.Context, chatID int64) ([]models.APIToken, error) {
+	tokens, err := app.DB.GetAPITokensByChat(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// issueAPIToken creates a token for the chat and returns it along with the
+// stored record, which only holds its hash.
+func (app *App) issueAPIToken(ctx context.Context, chatID, userID int64, scope string) (string, *models.APIToken, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	record, err := app.StoreAPIToken(ctx, &models.APIToken{
+		ChatId:    chatID,
+		UserId:    userID,
+		Scope:     scope,
+		TokenHash: hashToken(token),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return token, record, nil
+}
+
+// authenticateAPIToken returns the stored token matching the given one, or
+// nil when it's unknown or revoked.
+func (app *App) authenticateAPIToken(ctx context.Context, token string) (*models.APIToken, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, nil
+	}
+	record, err := app.DB.FindAPITokenByHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find API token: %w", err)
+	}
+	return record, nil
+}
+
+// newToken returns a random token to hand out. Only its hash is stored.
+func newToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// isPrivateChat reports whether a message was sent in the private chat of
+// its sender with the bot, whose ID is the one of the user.
+func isPrivateChat(message *tgbotapi.Message) bool {
+	return message.From != nil && message.Chat.ID == message.From.ID
+}
+
+// describeChat names the chat of a message in a private message about it.
+func describeChat(message *tgbotapi.Message) string {
+	switch {
+	case isPrivateChat(message):
+		return "this chat"
+	case message.Chat.Title != "":
+		return fmt.Sprintf("the chat %q", message.Chat.Title)
+	default:
+		return fmt.Sprintf("chat %d", message.Chat.ID)
+	}
+}
+
+// sendPrivately sends a secret, like a token or a login link, to the sender
+// of the message in their private chat with the bot, so other members of a
+// group don't see it. It reports whether the secret was sent.
+func (app *App) sendPrivately(ctx context.Context, message *tgbotapi.Message, text string) bool {
+	if message.From == nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "I can only send this to a user.")
+		return false
+	}
+
+	if err := app.Bot.SendMessage(ctx, message.From.ID, text); err != nil {
+		fmt.Printf("Error sending private message to user %d: %v\n", message.From.ID, err)
+		app.Bot.SendMessage(ctx, message.Chat.ID, "I couldn't send you a private message. Start a chat with me, then try again.")
+		return false
+	}
+
+	if !isPrivateChat(message) {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "I sent it to you in a private message.")
+	}
+	return true
+}
+
+func describeAPITokenScope(scope string) string {
+	if scope == models.APITokenScopeWrite {
+		return "read-write"
+	}
+	return "read-only"
+}
+
+// handleAPITokenCommand creates, lists and revokes the API tokens of the
+// chat, e.g. "/api_token write", "/api_token list" or "/api_token revoke 2".
+// Without arguments it creates a read-only token. Tokens are only sent to the
+// requester in private. In groups only admins can create read-write tokens,
+// and tokens can only be revoked by whoever created them or an admin.
+func (app *App) handleAPITokenCommand(ctx context.Context, message *tgbotapi.Message) {
+	usage := "Usage: /api_token [read|write], /api_token list or /api_token revoke <id>"
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		args = []string{models.APITokenScopeRead}
+	}
+
+	switch strings.ToLower(args[0]) {
+	case models.APITokenScopeRead, models.APITokenScopeWrite:
+		if len(args) != 1 {
+			app.Bot.SendMessage(ctx, message.Chat.ID, usage)
+			return
+		}
+		app.handleAPITokenCreateCommand(ctx, message, strings.ToLower(args[0]))
+	case "list":
+		app.handleAPITokenListCommand(ctx, message)
+	case "revoke":
+		app.handleAPITokenRevokeCommand(ctx, message, args[1:])
+	default:
+		app.Bot.SendMessage(ctx, message.Chat.ID, usage)
+	}
+}
+
+func (app *App) handleAPITokenCreateCommand(ctx context.Context, message *tgbotapi.Message, scope string) {
+	if message.From == nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "I can only send this to a user.")
+		return
+	}
+	if scope == models.APITokenScopeWrite && !isPrivateChat(message) && !app.Access.IsAdmin(message.From.ID) {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Only admins can create read-write API tokens in groups.")
+		return
+	}
+
+	token, record, err := app.issueAPIToken(ctx, message.Chat.ID, message.From.ID, scope)
+	if err != nil {
+		fmt.Printf("Error creating API token: %v\n", err)
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to create the API token")
+		return
+	}
+
+	sent := app.sendPrivately(ctx, message, fmt.Sprintf(
+		"API token #%d (%s) for %s:\n\n%s\n\n"+
+			"It is only shown this once, and anyone who has it can access the spendings of the chat. "+
+			"Send it in the Authorization header as \"Bearer <token>\", and revoke it with /api_token revoke %d.",
+		record.ID, describeAPITokenScope(scope), describeChat(message), token, record.ID,
+	))
+	// Nobody can use a token that never arrived
+	if !sent {
+		if err := app.DeleteAPIToken(ctx, record); err != nil {
+			fmt.Printf("Error deleting undelivered API token: %v\n", err)
+		}
+	}
+}
+
+func (app *App) handleAPITokenListCommand(ctx context.Context, message *tgbotapi.Message) {
+	tokens, err := app.GetAPITokensByChat(ctx, message.Chat.ID)
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to list API tokens")
+		return
+	}
+
+	if len(tokens) == 0 {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "There are no API tokens. Use /api_token [read|write] to create one.")
+		return
+	}
+
+	var list strings.Builder
+	list.WriteString("API tokens:\n")
+	for _, token := range tokens {
+		list.WriteString(fmt.Sprintf("\n#%d %s, created %s", token.ID, describeAPITokenScope(token.Scope), token.CreatedAt.Local().Format("2006-01-02")))
+	}
+
+	app.Bot.SendMessage(ctx, message.Chat.ID, list.String())
+}
+
+func (app *App) handleAPITokenRevokeCommand(ctx context.Context, message *tgbotapi.Message, args []string) {
+	if len(args) != 1 {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Usage: /api_token revoke <id>")
+		return
+	}
+
+	id, err := strconv.ParseUint(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Usage: /api_token revoke <id>")
+		return
+	}
+
+	token, err := app.FindAPIToken(ctx, uint(id))
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to revoke the API token")
+		return
+	}
+	if token == nil || token.ChatId != message.Chat.ID {
+		app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("There is no API token #%d.", id))
+		return
+	}
+	if message.From == nil || (message.From.ID != token.UserId && !app.Access.IsAdmin(message.From.ID)) {
+		app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("Only whoever created API token #%d or an admin can revoke it.", token.ID))
+		return
+	}
+
+	if err := app.DeleteAPIToken(ctx, token); err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to revoke the API token")
+		return
+	}
+
+	app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("Revoked API token #%d.", token.ID))
+}
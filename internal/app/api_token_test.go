@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+var apiTokenPattern = regexp.MustCompile(`st_[A-Za-z0-9_-]+`)
+
+func TestAPITokenCommand(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot}
+	handler := app.APIHandler()
+
+	// Private chats have the ID of the user
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(1, 7, 7, "/api_token"))
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(2, 7, 7, "/api_token write"))
+
+	messages := mockBot.SentMessagesTo(7)
+	if len(messages) != 2 || !strings.HasPrefix(messages[0], "API token #1 (read-only) for this chat:") ||
+		!strings.HasPrefix(messages[1], "API token #2 (read-write) for this chat:") {
+		t.Fatalf("Expected a read-only and a read-write token, got %q", messages)
+	}
+	readToken := apiTokenPattern.FindString(messages[0])
+	writeToken := apiTokenPattern.FindString(messages[1])
+	if readToken == "" || writeToken == "" || readToken == writeToken {
+		t.Fatalf("Expected two different tokens, got %q and %q", readToken, writeToken)
+	}
+
+	stored, _ := mockDB.FindAPIToken(ctx, 2)
+	if stored == nil || stored.ChatId != 7 || stored.UserId != 7 || stored.Scope != models.APITokenScopeWrite ||
+		stored.TokenHash != hashToken(writeToken) {
+		t.Errorf("Expected only the hash of the token to be stored, got %+v", stored)
+	}
+
+	apiRequest(t, handler, writeToken, "POST", "/api/spendings", `{"description": "Lunch 25"}`, http.StatusCreated, nil)
+	apiRequest(t, handler, readToken, "GET", "/api/spendings/1", "", http.StatusOK, nil)
+
+	mockBot.Reset()
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(3, 7, 7, "/api_token list"))
+	if messages := mockBot.SentMessages(); len(messages) != 1 || !strings.Contains(messages[0], "#1 read-only, created") ||
+		!strings.Contains(messages[0], "#2 read-write, created") || apiTokenPattern.MatchString(messages[0]) {
+		t.Errorf("Expected the tokens to be listed without their secrets, got %q", messages)
+	}
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(4, 8, 8, "/api_token revoke 2"))
+	mockBot.VerifyMessage(t, "There is no API token #2.")
+	apiRequest(t, handler, writeToken, "GET", "/api/spendings", "", http.StatusOK, nil)
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(5, 7, 7, "/api_token revoke 2"))
+	mockBot.VerifyMessage(t, "Revoked API token #2.")
+	apiRequest(t, handler, writeToken, "GET", "/api/spendings", "", http.StatusUnauthorized, nil)
+	apiRequest(t, handler, readToken, "GET", "/api/spendings", "", http.StatusOK, nil)
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(6, 8, 8, "/api_token list"))
+	mockBot.VerifyMessage(t, "There are no API tokens. Use /api_token [read|write] to create one.")
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(7, 7, 7, "/api_token admin"))
+	mockBot.VerifyMessage(t, "Usage: /api_token [read|write], /api_token list or /api_token revoke <id>")
+}
+
+func TestAPITokenCommandInGroups(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot, Access: &AccessControl{
+		AdminUserIds:   map[int64]bool{1: true},
+		AllowedChatIds: map[int64]bool{-100: true},
+	}}
+
+	kia := tgbotapi.User{ID: 1, FirstName: "Kia"}
+	ali := tgbotapi.User{ID: 2, FirstName: "Ali"}
+	sara := tgbotapi.User{ID: 3, FirstName: "Sara"}
+	group := func(messageID int, from tgbotapi.User, text string) *tgbotapi.Update {
+		update := testutils.NewTestGroupUpdate(messageID, -100, from, text)
+		update.Message.Chat.Title = "Flat"
+		return update
+	}
+
+	// Members only get read-only tokens, sent to them in private
+	app.handleUpdate(ctx, group(1, ali, "/api_token write"))
+	app.handleUpdate(ctx, group(2, ali, "/api_token"))
+	if messages := mockBot.SentMessagesTo(-100); len(messages) != 2 ||
+		messages[0] != "Only admins can create read-write API tokens in groups." || messages[1] != "I sent it to you in a private message." {
+		t.Errorf("Expected the group to see no token, got %q", messages)
+	}
+	if messages := mockBot.SentMessagesTo(2); len(messages) != 1 || !strings.HasPrefix(messages[0], `API token #1 (read-only) for the chat "Flat":`) ||
+		!apiTokenPattern.MatchString(messages[0]) {
+		t.Errorf("Expected the token in a private message, got %q", messages)
+	}
+
+	app.handleUpdate(ctx, group(3, kia, "/api_token write"))
+	if messages := mockBot.SentMessagesTo(1); len(messages) != 1 || !strings.HasPrefix(messages[0], `API token #2 (read-write) for the chat "Flat":`) {
+		t.Errorf("Expected the admin to get a read-write token, got %q", messages)
+	}
+
+	// Tokens that can't be delivered are dropped
+	mockBot.SetUnreachable(3)
+	app.handleUpdate(ctx, group(4, sara, "/api_token"))
+	mockBot.VerifyMessage(t, "I couldn't send you a private message. Start a chat with me, then try again.")
+	if tokens, _ := mockDB.GetAPITokensByChat(ctx, -100); len(tokens) != 2 {
+		t.Errorf("Expected the undelivered token to be deleted, got %d tokens", len(tokens))
+	}
+
+	// Only the creator or an admin can revoke a token
+	app.handleUpdate(ctx, group(5, sara, "/api_token revoke 1"))
+	mockBot.VerifyMessage(t, "Only whoever created API token #1 or an admin can revoke it.")
+	if token, _ := mockDB.FindAPIToken(ctx, 1); token == nil {
+		t.Errorf("Expected the token to be kept")
+	}
+	app.handleUpdate(ctx, group(6, kia, "/api_token revoke 1"))
+	app.handleUpdate(ctx, group(7, kia, "/api_token revoke 2"))
+	if tokens, _ := mockDB.GetAPITokensByChat(ctx, -100); len(tokens) != 0 {
+		t.Errorf("Expected both tokens to be revoked, got %+v", tokens)
+	}
+}
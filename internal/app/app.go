@@ -1,30 +1,85 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/kiasaty/spendings-tracker/internal/database"
+	"github.com/kiasaty/spendings-tracker/pkg/ledger"
 	"github.com/kiasaty/spendings-tracker/pkg/telegram"
 )
 
+const defaultWorkers = 4
+
 type App struct {
-	DB  database.DatabaseClient
-	Bot telegram.BotInterface
+	DB     database.DatabaseClient
+	Bot    telegram.BotInterface
+	Access *AccessControl
+
+	// Workers is the number of updates handled in parallel
+	Workers int
+
+	// Currency is the currency spendings are recorded in, e.g. EUR
+	Currency string
+
+	// LedgerAccounts maps tags, chats and payers to the accounts of hledger
+	// and Beancount exports; nil uses the default accounts
+	LedgerAccounts *ledger.Accounts
+
+	// DashboardURL is the address serve-api is reachable at, used in the
+	// login links of the dashboard; without it /dashboard is disabled
+	DashboardURL string
 }
 
 func NewApp(databaseClient database.DatabaseClient, bot telegram.BotInterface) (*App, error) {
+	access, err := NewAccessControlFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	workers := defaultWorkers
+	if value := os.Getenv("UPDATE_WORKERS"); value != "" {
+		workers, err = strconv.Atoi(value)
+		if err != nil || workers < 1 {
+			return nil, fmt.Errorf("invalid UPDATE_WORKERS: %q is not a positive number", value)
+		}
+	}
+
+	var ledgerAccounts *ledger.Accounts
+	if path := os.Getenv("LEDGER_ACCOUNTS_FILE"); path != "" {
+		ledgerAccounts, err = loadLedgerAccounts(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &App{
-		DB:  databaseClient,
-		Bot: bot,
+		DB:             databaseClient,
+		Bot:            bot,
+		Access:         access,
+		Workers:        workers,
+		Currency:       os.Getenv("CURRENCY"),
+		LedgerAccounts: ledgerAccounts,
+		DashboardURL:   os.Getenv("DASHBOARD_URL"),
 	}, nil
 }
 
-func (app *App) HandleCommand() {
+// withTransaction runs fn with a copy of the app whose database changes are
+// applied atomically: all of them when fn returns nil, none otherwise.
+func (app *App) withTransaction(ctx context.Context, fn func(tx *App) error) error {
+	return app.DB.Transaction(ctx, func(tx database.DatabaseClient) error {
+		txApp := *app
+		txApp.DB = tx
+		return fn(&txApp)
+	})
+}
+
+func (app *App) HandleCommand(ctx context.Context) {
 	if len(os.Args) < 2 {
-		fmt.Println("List of existing commands:")
-		fmt.Println("  fetch-updates - Fetch and process new messages from Telegram")
-		fmt.Println("  migrate-database - Set up the database schema")
+		printCommands()
 		os.Exit(1)
 	}
 
@@ -32,14 +87,75 @@ func (app *App) HandleCommand() {
 
 	switch command {
 	case "fetch-updates":
-		app.FetchUpdates()
+		app.FetchUpdates(ctx)
 	case "migrate-database":
-		app.DB.Migrate()
+		action := "up"
+		if len(os.Args) > 2 {
+			action = os.Args[2]
+		}
+		if err := app.MigrateDatabase(ctx, action); err != nil {
+			fmt.Println("Migrating database failed:", err)
+			os.Exit(1)
+		}
+	case "run-recurring":
+		created, err := app.RunRecurring(ctx, time.Now())
+		if err != nil {
+			fmt.Println("Running recurring spendings failed:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Created %d recurring spendings\n", created)
+	case "import-csv":
+		if err := app.ImportCSV(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Println("Importing spendings failed:", err)
+			os.Exit(1)
+		}
+	case "import-telegram-export":
+		if err := app.ImportTelegramExport(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Println("Importing Telegram export failed:", err)
+			os.Exit(1)
+		}
+	case "reconcile":
+		if err := app.Reconcile(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Println("Reconciling statement failed:", err)
+			os.Exit(1)
+		}
+	case "reparse":
+		if err := app.Reparse(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Println("Reparsing spendings failed:", err)
+			os.Exit(1)
+		}
+	case "serve-api":
+		if err := app.ServeAPI(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Println("Serving the API failed:", err)
+			os.Exit(1)
+		}
+	case "search":
+		if err := app.Search(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Println("Searching spendings failed:", err)
+			os.Exit(1)
+		}
+	case "export":
+		if err := app.Export(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Println("Exporting spendings failed:", err)
+			os.Exit(1)
+		}
 	default:
 		fmt.Println("Unknown command:", command)
-		fmt.Println("List of existing commands:")
-		fmt.Println("  fetch-updates - Fetch and process new messages from Telegram")
-		fmt.Println("  migrate-database - Set up the database schema")
+		printCommands()
 		os.Exit(1)
 	}
 }
+
+func printCommands() {
+	fmt.Println("List of existing commands:")
+	fmt.Println("  export [-chat ID] [-period PERIOD] [-format csv|json|hledger|beancount] [-accounts FILE] [-output FILE] - Export spendings like /export, to stdout by default. All amounts are in the currency set by CURRENCY")
+	fmt.Println("  fetch-updates - Fetch and process new messages from Telegram")
+	fmt.Println("  import-csv -chat ID [-date COLUMN] [-amount COLUMN] [-description COLUMN] [-tags COLUMN] [-date-layout LAYOUT] [-decimal .|,] [-delimiter CHAR] [-dry-run] <file> - Import spendings from a CSV file, skipping rows imported before")
+	fmt.Println("  import-telegram-export [-chat ID] <result.json> - Backfill spendings from a chat exported with Telegram Desktop")
+	fmt.Println("  migrate-database [up|down|status] - Apply pending migrations (default), revert the latest one or list them")
+	fmt.Println("  reconcile -chat ID [-format ofx|qif|camt053] [-window DAYS] [-dry-run] <file> - Match the payments of a bank statement with spendings and list the ones without a match")
+	fmt.Println("  reparse [-chat ID] [-keep IDS] [-apply] - Extract cost, date and tags from spending descriptions again, showing the changes and only applying them with -apply")
+	fmt.Println("  run-recurring - Create the spendings of due recurring spendings")
+	fmt.Println("  search [-chat ID] [-limit N] <query> - Print the spendings matching a query like the one of /search")
+	fmt.Println("  serve-api [-addr ADDRESS] - Serve the JSON API described at /api/openapi.yaml and the dashboard at /dashboard")
+}
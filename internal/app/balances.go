@@ -0,0 +1,219 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/models"
+	"github.com/kiasaty/spendings-tracker/pkg/settlement"
+)
+
+// splitSpending divides a group spending between the mentioned members, or
+// between every known member of the chat when nobody is mentioned. Cents
+// that can't be divided evenly go to the payer's share when they take part,
+// so the others don't owe them more than they paid.
+func (app *App) splitSpending(ctx context.Context, spending *models.Spending, mentions []string) error {
+	var participants []models.ChatMember
+	for _, userName := range mentions {
+		member, err := app.findOrCreateMentionedMember(ctx, spending.ChatId, userName)
+		if err != nil {
+			return err
+		}
+		participants = append(participants, *member)
+	}
+
+	if len(participants) == 0 {
+		members, err := app.GetChatMembers(ctx, spending.ChatId)
+		if err != nil {
+			return err
+		}
+		participants = members
+	}
+
+	// The first shares get the leftover cents
+	amounts := settlement.Split(spending.Cost, len(participants))
+	for i, participant := range participants {
+		if spending.SenderId != 0 && participant.UserId == spending.SenderId {
+			amounts[0], amounts[i] = amounts[i], amounts[0]
+			break
+		}
+	}
+
+	shares := make([]models.SpendingShare, len(participants))
+	for i, participant := range participants {
+		shares[i] = models.SpendingShare{
+			MemberId: participant.ID,
+			Amount:   amounts[i],
+		}
+	}
+
+	return app.SyncSpendingShares(ctx, spending, &shares)
+}
+
+// chatBalances returns the net balance of every member of a chat. Members
+// with a positive balance are owed money, negative ones owe money.
+func (app *App) chatBalances(ctx context.Context, chatID int64) (map[uint]float64, error) {
+	members, err := app.GetChatMembers(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	memberIdsByUserId := make(map[int64]uint)
+	for _, member := range members {
+		if member.UserId != 0 {
+			memberIdsByUserId[member.UserId] = member.ID
+		}
+	}
+
+	spendings, err := app.DB.GetSharedSpendingsByChat(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared spendings: %w", err)
+	}
+
+	// Summed in cents, so the balances add up to exactly zero
+	cents := make(map[uint]int64)
+	for _, spending := range spendings {
+		payerID, ok := memberIdsByUserId[spending.SenderId]
+		if !ok {
+			continue
+		}
+		for _, share := range spending.Shares {
+			cents[payerID] += settlement.ToCents(share.Amount)
+			cents[share.MemberId] -= settlement.ToCents(share.Amount)
+		}
+	}
+
+	settlements, err := app.DB.GetSettlementsByChat(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settlements: %w", err)
+	}
+
+	for _, repayment := range settlements {
+		cents[repayment.FromMemberId] += settlement.ToCents(repayment.Amount)
+		cents[repayment.ToMemberId] -= settlement.ToCents(repayment.Amount)
+	}
+
+	balances := make(map[uint]float64, len(cents))
+	for memberID, amount := range cents {
+		balances[memberID] = settlement.FromCents(amount)
+	}
+	return balances, nil
+}
+
+func (app *App) handleBalancesCommand(ctx context.Context, message *tgbotapi.Message) {
+	if !isGroupChat(message.Chat) {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Balances are only available in group chats.")
+		return
+	}
+
+	members, err := app.GetChatMembers(ctx, message.Chat.ID)
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to calculate balances")
+		return
+	}
+
+	balances, err := app.chatBalances(ctx, message.Chat.ID)
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to calculate balances")
+		return
+	}
+
+	names := make(map[uint]string)
+	for _, member := range members {
+		names[member.ID] = member.Name()
+	}
+
+	transfers := settlement.Minimize(balances)
+	if len(transfers) == 0 {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Everyone is settled up.")
+		return
+	}
+
+	// Sort members by name for consistent output
+	var memberIds []uint
+	for memberID, balance := range balances {
+		if math.Abs(balance) >= 0.005 {
+			memberIds = append(memberIds, memberID)
+		}
+	}
+	sort.Slice(memberIds, func(i, j int) bool {
+		return names[memberIds[i]] < names[memberIds[j]]
+	})
+
+	var report strings.Builder
+	report.WriteString("Balances:\n")
+	for _, memberID := range memberIds {
+		report.WriteString(fmt.Sprintf("%s: %+.2f\n", names[memberID], balances[memberID]))
+	}
+
+	report.WriteString("\nTo settle up:\n")
+	for i, transfer := range transfers {
+		if i > 0 {
+			report.WriteString("\n")
+		}
+		report.WriteString(fmt.Sprintf("%s pays %s %.2f", names[transfer.From], names[transfer.To], transfer.Amount))
+	}
+
+	app.Bot.SendMessage(ctx, message.Chat.ID, report.String())
+}
+
+// handleSettleCommand records a repayment from the sender to the mentioned
+// member, e.g. "/settle @ali 30". Errors are returned without replying, so
+// the update is handled again. A message that was already recorded, because
+// its update is handled again, is left alone.
+func (app *App) handleSettleCommand(ctx context.Context, message *tgbotapi.Message, payer *models.ChatMember) error {
+	if !isGroupChat(message.Chat) || payer == nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Settling up is only available in group chats.")
+		return nil
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 2 || !strings.HasPrefix(args[0], "@") {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Usage: /settle @username amount")
+		return nil
+	}
+
+	amount, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || amount <= 0 {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Usage: /settle @username amount")
+		return nil
+	}
+
+	existing, err := app.FindSettlementByMessageId(ctx, message.Chat.ID, message.MessageID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+
+	recipient, err := app.FindChatMemberByUserName(ctx, message.Chat.ID, args[0][1:])
+	if err != nil {
+		return err
+	}
+	if recipient == nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("I don't know %s in this chat yet.", args[0]))
+		return nil
+	}
+
+	_, err = app.StoreSettlement(ctx, &models.Settlement{
+		ChatId:       message.Chat.ID,
+		MessageId:    message.MessageID,
+		FromMemberId: payer.ID,
+		ToMemberId:   recipient.ID,
+		Amount:       amount,
+		SettledAt:    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("Recorded: %s paid %s %.2f.", payer.Name(), recipient.Name(), amount))
+	return nil
+}
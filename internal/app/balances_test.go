@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+)
+
+func TestGroupSpendingBalances(t *testing.T) {
+	ctx := context.Background()
+
+	kia := tgbotapi.User{ID: 1, FirstName: "Kia", UserName: "kia"}
+	ali := tgbotapi.User{ID: 2, FirstName: "Ali", UserName: "ali"}
+	sara := tgbotapi.User{ID: 3, FirstName: "Sara", UserName: "sara"}
+
+	tests := []struct {
+		name             string
+		messages         []*tgbotapi.Update
+		expectedBalances string
+	}{
+		{
+			name: "Spending is split between everyone by default",
+			messages: []*tgbotapi.Update{
+				testutils.NewTestGroupUpdate(1, -100, ali, "hi"),
+				testutils.NewTestGroupUpdate(2, -100, sara, "hello"),
+				testutils.NewTestGroupUpdate(3, -100, kia, "groceries 90 #food"),
+			},
+			expectedBalances: "Balances:\nAli: -30.00\nKia: +60.00\nSara: -30.00\n\nTo settle up:\nAli pays Kia 30.00\nSara pays Kia 30.00",
+		},
+		{
+			name: "Leftover cents go to the payer's share",
+			messages: []*tgbotapi.Update{
+				testutils.NewTestGroupUpdate(1, -100, ali, "hi"),
+				testutils.NewTestGroupUpdate(2, -100, sara, "hello"),
+				testutils.NewTestGroupUpdate(3, -100, kia, "groceries 100 #food"),
+			},
+			expectedBalances: "Balances:\nAli: -33.33\nKia: +66.66\nSara: -33.33\n\nTo settle up:\nAli pays Kia 33.33\nSara pays Kia 33.33",
+		},
+		{
+			name: "Spending is split between mentioned members only",
+			messages: []*tgbotapi.Update{
+				testutils.NewTestGroupUpdate(1, -100, kia, "dinner 90 @ali @sara"),
+			},
+			expectedBalances: "Balances:\n@ali: -45.00\n@sara: -45.00\nKia: +90.00\n\nTo settle up:\n@ali pays Kia 45.00\n@sara pays Kia 45.00",
+		},
+		{
+			name: "Members mentioned twice get one share",
+			messages: []*tgbotapi.Update{
+				testutils.NewTestGroupUpdate(1, -100, kia, "dinner 90 @ali @sara @ali, mail kia@example.com"),
+			},
+			expectedBalances: "Balances:\n@ali: -45.00\n@sara: -45.00\nKia: +90.00\n\nTo settle up:\n@ali pays Kia 45.00\n@sara pays Kia 45.00",
+		},
+		{
+			name: "Settlements reduce balances",
+			messages: []*tgbotapi.Update{
+				testutils.NewTestGroupUpdate(1, -100, kia, "dinner 90 @ali @sara"),
+				testutils.NewTestGroupUpdate(2, -100, ali, "/settle @kia 45"),
+			},
+			expectedBalances: "Balances:\n@sara: -45.00\nKia: +45.00\n\nTo settle up:\n@sara pays Kia 45.00",
+		},
+		{
+			name: "Members that fully settled up have no balance",
+			messages: []*tgbotapi.Update{
+				testutils.NewTestGroupUpdate(1, -100, kia, "taxi 20 @ali"),
+				testutils.NewTestGroupUpdate(2, -100, ali, "/settle @kia 20"),
+			},
+			expectedBalances: "Everyone is settled up.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := testutils.NewMockDatabaseClient()
+			mockBot := testutils.NewMockTelegramBot()
+			app := &App{DB: mockDB, Bot: mockBot}
+
+			for _, update := range tt.messages {
+				app.handleUpdate(ctx, update)
+			}
+
+			app.handleUpdate(ctx, testutils.NewTestGroupUpdate(100, -100, kia, "/balances"))
+
+			mockBot.VerifyMessage(t, tt.expectedBalances)
+		})
+	}
+}
+
+func TestSettleIsRecordedOnce(t *testing.T) {
+	ctx := context.Background()
+
+	kia := tgbotapi.User{ID: 1, FirstName: "Kia", UserName: "kia"}
+	ali := tgbotapi.User{ID: 2, FirstName: "Ali", UserName: "ali"}
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot}
+
+	app.handleUpdate(ctx, testutils.NewTestGroupUpdate(1, -100, kia, "taxi 20 @ali"))
+
+	// The update is handled again, e.g. after a restart
+	settle := testutils.NewTestGroupUpdate(2, -100, ali, "/settle @kia 20")
+	for range 2 {
+		if err := app.handleUpdate(ctx, settle); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if settlements, _ := mockDB.GetSettlementsByChat(ctx, -100); len(settlements) != 1 {
+		t.Errorf("Expected the settlement to be recorded once, got %d", len(settlements))
+	}
+
+	app.handleUpdate(ctx, testutils.NewTestGroupUpdate(3, -100, kia, "/balances"))
+	mockBot.VerifyMessage(t, "Everyone is settled up.")
+}
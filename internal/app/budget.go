@@ -0,0 +1,260 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+// Budget usage ratios that trigger a warning when a new spending crosses them
+var budgetThresholds = []float64{1.0, 0.8}
+
+func (app *App) StoreBudget(ctx context.Context, budget *models.Budget) (*models.Budget, error) {
+	budget, err := app.DB.CreateBudget(ctx, budget)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store budget: %w", err)
+	}
+	return budget, nil
+}
+
+func (app *App) UpdateBudget(ctx context.Context, budget *models.Budget) (*models.Budget, error) {
+	err := app.DB.UpdateBudget(ctx, budget)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update budget: %w", err)
+	}
+	return budget, nil
+}
+
+func (app *App) DeleteBudget(ctx context.Context, budget *models.Budget) error {
+	err := app.DB.DeleteBudget(ctx, budget)
+	if err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+	return nil
+}
+
+func (app *App) FindBudget(ctx context.Context, chatID int64, tagName string) (*models.Budget, error) {
+	budget, err := app.DB.FindBudget(ctx, chatID, tagName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find budget: %w", err)
+	}
+	return budget, nil
+}
+
+func (app *App) GetBudgetsByChat(ctx context.Context, chatID int64) ([]models.Budget, error) {
+	budgets, err := app.DB.GetBudgetsByChat(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budgets: %w", err)
+	}
+	return budgets, nil
+}
+
+// budgetPeriodRange returns the first and last moment of the budget period
+// containing now.
+func budgetPeriodRange(period string, now time.Time) (time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var startDate, nextStartDate time.Time
+	switch period {
+	case models.BudgetPeriodWeekly:
+		// Weeks start on Monday
+		startDate = today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		nextStartDate = startDate.AddDate(0, 0, 7)
+	case models.BudgetPeriodYearly:
+		startDate = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+		nextStartDate = startDate.AddDate(1, 0, 0)
+	default:
+		startDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		nextStartDate = startDate.AddDate(0, 1, 0)
+	}
+
+	return startDate, nextStartDate.Add(-time.Second)
+}
+
+// budgetDaysLeft returns the number of days left in the period, today included.
+// The calendar dates are compared at midnight UTC, since days in the local
+// time zone are an hour shorter or longer when daylight saving time changes.
+func budgetDaysLeft(endDate, now time.Time) int {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	lastDay := time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 0, 0, 0, 0, time.UTC)
+	return int(lastDay.Sub(today).Hours()/24) + 1
+}
+
+// budgetSpent sums the spendings of a chat tagged with the budget's tag in
+// the given date range.
+func (app *App) budgetSpent(ctx context.Context, budget models.Budget, startDate, endDate time.Time) (float64, error) {
+	spendings, err := app.DB.GetChatSpendingsByDateRange(ctx, budget.ChatId, startDate, endDate)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get spendings: %w", err)
+	}
+
+	var spent float64
+	for _, spending := range spendings {
+		for _, tag := range spending.Tags {
+			if tag.Name == budget.TagName {
+				spent += spending.Cost
+				break
+			}
+		}
+	}
+	return spent, nil
+}
+
+// checkBudgets warns the chat when a new spending pushes one of its tags
+// over 80% or 100% of the budget for the current period.
+func (app *App) checkBudgets(ctx context.Context, spending *models.Spending, tags []models.Tag) error {
+	now := time.Now()
+
+	for _, tag := range tags {
+		budget, err := app.FindBudget(ctx, spending.ChatId, tag.Name)
+		if err != nil {
+			return err
+		}
+		if budget == nil || budget.Amount <= 0 {
+			continue
+		}
+
+		startDate, endDate := budgetPeriodRange(budget.Period, now)
+		if spending.SpentAt.Before(startDate) || spending.SpentAt.After(endDate) {
+			continue
+		}
+
+		spent, err := app.budgetSpent(ctx, *budget, startDate, endDate)
+		if err != nil {
+			return err
+		}
+
+		before := (spent - spending.Cost) / budget.Amount
+		after := spent / budget.Amount
+
+		for _, threshold := range budgetThresholds {
+			if before >= threshold || after < threshold {
+				continue
+			}
+
+			var warning string
+			if threshold >= 1 {
+				warning = fmt.Sprintf("Budget exceeded: %s spending is at %.2f of the %s budget of %.2f.", budget.TagName, spent, budget.Period, budget.Amount)
+			} else {
+				warning = fmt.Sprintf("Warning: %.0f%% of the %s %s budget is used (%.2f of %.2f).", after*100, budget.Period, budget.TagName, spent, budget.Amount)
+			}
+			app.Bot.SendMessage(ctx, spending.ChatId, warning)
+			break
+		}
+	}
+
+	return nil
+}
+
+// handleBudgetCommand sets or removes the budget of a tag, e.g.
+// "/budget food 400 monthly" or "/budget food off".
+func (app *App) handleBudgetCommand(ctx context.Context, message *tgbotapi.Message) {
+	usage := "Usage: /budget <tag> <amount> [weekly|monthly|yearly] or /budget <tag> off"
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) < 2 || len(args) > 3 {
+		app.Bot.SendMessage(ctx, message.Chat.ID, usage)
+		return
+	}
+
+	tagName := strings.TrimPrefix(args[0], "#")
+
+	budget, err := app.FindBudget(ctx, message.Chat.ID, tagName)
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to save the budget")
+		return
+	}
+
+	if args[1] == "off" {
+		if budget == nil {
+			app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("There is no budget for %s.", tagName))
+			return
+		}
+		if err := app.DeleteBudget(ctx, budget); err != nil {
+			app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to remove the budget")
+			return
+		}
+		app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("Removed the budget for %s.", tagName))
+		return
+	}
+
+	amount, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || amount <= 0 {
+		app.Bot.SendMessage(ctx, message.Chat.ID, usage)
+		return
+	}
+
+	period := models.BudgetPeriodMonthly
+	if len(args) == 3 {
+		period = strings.ToLower(args[2])
+	}
+	if period != models.BudgetPeriodWeekly && period != models.BudgetPeriodMonthly && period != models.BudgetPeriodYearly {
+		app.Bot.SendMessage(ctx, message.Chat.ID, usage)
+		return
+	}
+
+	if budget == nil {
+		_, err = app.StoreBudget(ctx, &models.Budget{
+			ChatId:  message.Chat.ID,
+			TagName: tagName,
+			Amount:  amount,
+			Period:  period,
+		})
+	} else {
+		budget.Amount = amount
+		budget.Period = period
+		_, err = app.UpdateBudget(ctx, budget)
+	}
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to save the budget")
+		return
+	}
+
+	app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("Budget for %s set to %.2f %s.", tagName, amount, period))
+}
+
+func (app *App) handleBudgetsCommand(ctx context.Context, message *tgbotapi.Message) {
+	budgets, err := app.GetBudgetsByChat(ctx, message.Chat.ID)
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to list budgets")
+		return
+	}
+
+	if len(budgets) == 0 {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "No budgets set. Use /budget <tag> <amount> [weekly|monthly|yearly] to add one.")
+		return
+	}
+
+	now := time.Now()
+
+	var report strings.Builder
+	report.WriteString("Budgets:\n")
+
+	for _, budget := range budgets {
+		startDate, endDate := budgetPeriodRange(budget.Period, now)
+
+		spent, err := app.budgetSpent(ctx, budget, startDate, endDate)
+		if err != nil {
+			app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to list budgets")
+			return
+		}
+
+		remaining := budget.Amount - spent
+		status := fmt.Sprintf("%.2f remaining", remaining)
+		if remaining < 0 {
+			status = fmt.Sprintf("%.2f over", -remaining)
+		}
+
+		report.WriteString(fmt.Sprintf(
+			"\n%s (%s): %.2f of %.2f spent, %s, %d days left",
+			budget.TagName, budget.Period, spent, budget.Amount, status, budgetDaysLeft(endDate, now),
+		))
+	}
+
+	app.Bot.SendMessage(ctx, message.Chat.ID, report.String())
+}
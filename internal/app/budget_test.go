@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+func TestBudgetAlerts(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot}
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(1, 123456789, 1, "/budget food 100 monthly"))
+	mockBot.VerifyMessage(t, "Budget for food set to 100.00 monthly.")
+
+	app.handleUpdate(ctx, testutils.NewTestUpdate(2, 123456789, "Groceries 50 #food"))
+	app.handleUpdate(ctx, testutils.NewTestUpdate(3, 123456789, "Lunch 35 #food"))
+	mockBot.VerifyMessage(t, "Warning: 85% of the monthly food budget is used (85.00 of 100.00).")
+
+	app.handleUpdate(ctx, testutils.NewTestUpdate(4, 123456789, "Dinner 20 #food"))
+	mockBot.VerifyMessage(t, "Budget exceeded: food spending is at 105.00 of the monthly budget of 100.00.")
+
+	mockBot.Reset()
+	app.handleUpdate(ctx, testutils.NewTestUpdate(5, 123456789, "Snack 5 #food"))
+	app.handleUpdate(ctx, testutils.NewTestUpdate(6, 123456789, "Taxi 500 #transport"))
+	mockBot.VerifyExpectations(t)
+
+	_, endDate := budgetPeriodRange(models.BudgetPeriodMonthly, time.Now())
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(7, 123456789, 1, "/budgets"))
+	mockBot.VerifyMessage(t, fmt.Sprintf(
+		"Budgets:\n\nfood (monthly): 110.00 of 100.00 spent, 10.00 over, %d days left",
+		budgetDaysLeft(endDate, time.Now()),
+	))
+}
+
+func TestBudgetPeriodRange(t *testing.T) {
+	now := time.Date(2024, 5, 9, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		period        string
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		{
+			period:        models.BudgetPeriodWeekly,
+			expectedStart: time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2024, 5, 12, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			period:        models.BudgetPeriodMonthly,
+			expectedStart: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2024, 5, 31, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			period:        models.BudgetPeriodYearly,
+			expectedStart: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.period, func(t *testing.T) {
+			startDate, endDate := budgetPeriodRange(tt.period, now)
+
+			if !startDate.Equal(tt.expectedStart) || !endDate.Equal(tt.expectedEnd) {
+				t.Errorf("Expected %v - %v, got %v - %v", tt.expectedStart, tt.expectedEnd, startDate, endDate)
+			}
+		})
+	}
+
+	if daysLeft := budgetDaysLeft(time.Date(2024, 5, 31, 23, 59, 59, 0, time.UTC), now); daysLeft != 23 {
+		t.Errorf("Expected 23 days left, got %d", daysLeft)
+	}
+
+	// Daylight saving time starts on March 31, 2024 in Berlin
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("Time zone data not available: %v", err)
+	}
+	saturday := time.Date(2024, 3, 30, 12, 0, 0, 0, berlin)
+	if daysLeft := budgetDaysLeft(time.Date(2024, 4, 7, 23, 59, 59, 0, berlin), saturday); daysLeft != 9 {
+		t.Errorf("Expected 9 days left across daylight saving time, got %d", daysLeft)
+	}
+}
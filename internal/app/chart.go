@@ -0,0 +1,213 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/internal/database"
+	"github.com/kiasaty/spendings-tracker/models"
+	"github.com/kiasaty/spendings-tracker/pkg/chart"
+)
+
+const chartUsage = "Usage: /chart [pie|daily|trend] [today|week|month|last_month|year|all]"
+
+// chartTypes are the charts /chart draws, the first being the default
+var chartTypes = []string{"pie", "daily", "trend"}
+
+const (
+	// maxDailyChartDays is the longest period a daily chart has a bar for
+	// each day of
+	maxDailyChartDays = 31
+	chartTrendMonths  = 12
+)
+
+var errChartPeriodTooLong = fmt.Errorf("daily charts cover at most %d days", maxDailyChartDays)
+
+// handleChartCommand sends a chart of the chat's spendings of a period as a
+// photo: a pie of the totals by tag, a bar for each day or the trend of the
+// 12 months up to the end of the period. The type and period can be given in
+// any order.
+func (app *App) handleChartCommand(ctx context.Context, message *tgbotapi.Message) {
+	kind, period := "", ""
+	for _, arg := range strings.Fields(message.CommandArguments()) {
+		switch {
+		case kind == "" && slices.Contains(chartTypes, arg):
+			kind = arg
+		case period == "":
+			period = arg
+		default:
+			app.Bot.SendMessage(ctx, message.Chat.ID, chartUsage)
+			return
+		}
+	}
+	if kind == "" {
+		kind = chartTypes[0]
+	}
+
+	now := time.Now()
+	page, ok := listPage(message.Chat.ID, period, now)
+	if !ok {
+		app.Bot.SendMessage(ctx, message.Chat.ID, chartUsage)
+		return
+	}
+	page.filter.Limit = 0
+
+	data, caption, err := app.drawChart(ctx, kind, page, now)
+	if errors.Is(err, chart.ErrNoData) {
+		app.Bot.SendMessage(ctx, message.Chat.ID, page.empty)
+		return
+	}
+	if errors.Is(err, errChartPeriodTooLong) {
+		app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("Daily charts cover at most %d days, try /chart daily month or /chart daily week.", maxDailyChartDays))
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error drawing chart: %v\n", err)
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to draw chart")
+		return
+	}
+
+	fileName := fmt.Sprintf("chart-%s-%s.png", kind, page.callbackArgument)
+	if err := app.Bot.SendPhoto(ctx, message.Chat.ID, fileName, data, caption); err != nil {
+		fmt.Printf("Error sending chart: %v\n", err)
+	}
+}
+
+// chartValuesFunc returns the title and values of a chart and the total of
+// the spendings in it.
+type chartValuesFunc func(ctx context.Context, page *spendingPage, now time.Time) (string, []chart.Value, float64, error)
+
+// drawChart draws the chart of the kind for the spendings of the page and
+// returns it as PNG with a caption. It returns chart.ErrNoData when there is
+// nothing to draw.
+func (app *App) drawChart(ctx context.Context, kind string, page *spendingPage, now time.Time) ([]byte, string, error) {
+	var collect chartValuesFunc
+	var draw func(io.Writer, string, []chart.Value) error
+	switch kind {
+	case "pie":
+		collect, draw = app.tagChartValues, chart.Pie
+	case "daily":
+		collect, draw = app.dailyChartValues, chart.Bars
+	case "trend":
+		collect, draw = app.trendChartValues, chart.Trend
+	default:
+		return nil, "", fmt.Errorf("unknown chart type %q", kind)
+	}
+
+	title, values, total, err := collect(ctx, page, now)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var data bytes.Buffer
+	if err := draw(&data, title, values); err != nil {
+		return nil, "", err
+	}
+	return data.Bytes(), fmt.Sprintf("%s\nTotal: %.2f", title, total), nil
+}
+
+// tagChartValues returns the totals by tag of the page's spendings, "other"
+// last like in /report.
+func (app *App) tagChartValues(ctx context.Context, page *spendingPage, now time.Time) (string, []chart.Value, float64, error) {
+	spendings, _, err := app.FindSpendings(ctx, page.filter)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	summary := newSpendingReport(spendings)
+	var values []chart.Value
+	for _, tag := range summary.tagNames() {
+		values = append(values, chart.Value{Label: tag, Value: summary.Tags[tag]})
+	}
+	return page.title + " by tag", values, summary.Total, nil
+}
+
+// dailyChartValues returns the total of each day of the page's period, or
+// from the first spending until now when the period is all time.
+func (app *App) dailyChartValues(ctx context.Context, page *spendingPage, now time.Time) (string, []chart.Value, float64, error) {
+	start, end := page.filter.StartDate, page.filter.EndDate
+	if !start.IsZero() && end.Sub(start) > maxDailyChartDays*24*time.Hour {
+		return "", nil, 0, errChartPeriodTooLong
+	}
+
+	spendings, _, err := app.FindSpendings(ctx, page.filter)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	if len(spendings) == 0 {
+		return "", nil, 0, chart.ErrNoData
+	}
+
+	if start.IsZero() {
+		start, end = now, now
+		for _, spending := range spendings {
+			if spending.SpentAt.Before(start) {
+				start = spending.SpentAt
+			}
+		}
+	}
+	start = start.In(now.Location())
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, now.Location())
+
+	byDay := make(map[string][]models.Spending)
+	for _, spending := range spendings {
+		key := spending.SpentAt.In(now.Location()).Format(time.DateOnly)
+		byDay[key] = append(byDay[key], spending)
+	}
+
+	var values []chart.Value
+	var total float64
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if len(values) == maxDailyChartDays {
+			return "", nil, 0, errChartPeriodTooLong
+		}
+		summary := newSpendingReport(byDay[day.Format(time.DateOnly)])
+		values = append(values, chart.Value{Label: strconv.Itoa(day.Day()), Value: summary.Total})
+		total += summary.Total
+	}
+	return page.title + " by day", values, total, nil
+}
+
+// trendChartValues returns the monthly totals of the 12 months up to the end
+// of the page's period, or up to now when it is later or all time.
+func (app *App) trendChartValues(ctx context.Context, page *spendingPage, now time.Time) (string, []chart.Value, float64, error) {
+	end := page.filter.EndDate
+	if end.IsZero() || end.After(now) {
+		end = now
+	}
+	last := time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, now.Location())
+	first := last.AddDate(0, 1-chartTrendMonths, 0)
+
+	spendings, _, err := app.FindSpendings(ctx, database.SpendingFilter{
+		ChatId:    page.filter.ChatId,
+		StartDate: first,
+		EndDate:   last.AddDate(0, 1, 0).Add(-time.Second),
+	})
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	byMonth := make(map[string][]models.Spending)
+	for _, spending := range spendings {
+		key := spending.SpentAt.In(now.Location()).Format(dashboardMonthLayout)
+		byMonth[key] = append(byMonth[key], spending)
+	}
+
+	var values []chart.Value
+	var total float64
+	for month := first; !month.After(last); month = month.AddDate(0, 1, 0) {
+		summary := newSpendingReport(byMonth[month.Format(dashboardMonthLayout)])
+		values = append(values, chart.Value{Label: month.Format("Jan"), Value: summary.Total})
+		total += summary.Total
+	}
+	title := fmt.Sprintf("Spendings from %s to %s", first.Format("January 2006"), last.Format("January 2006"))
+	return title, values, total, nil
+}
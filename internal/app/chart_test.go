@@ -0,0 +1,146 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+	"github.com/kiasaty/spendings-tracker/models"
+	"github.com/kiasaty/spendings-tracker/pkg/chart"
+)
+
+func newChartTestApp(t *testing.T, spentAt ...time.Time) (*App, *testutils.MockTelegramBot) {
+	t.Helper()
+	ctx := context.Background()
+
+	bot := testutils.NewMockTelegramBot()
+	app := &App{DB: testutils.NewMockDatabaseClient(), Bot: bot}
+	spendings := []struct {
+		description string
+		cost        float64
+		tags        []string
+	}{
+		{"Lunch 25", 25, []string{"food", "work"}},
+		{"Groceries 10", 10, []string{"food"}},
+		{"Taxi 5", 5, nil},
+		{"Rent 900", 900, []string{"rent"}},
+	}
+	for i, spending := range spendings {
+		stored := &models.Spending{ChatId: 123, Description: spending.description, Cost: spending.cost, SpentAt: spentAt[i]}
+		if err := app.saveAPISpending(ctx, stored, spending.tags, false); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	return app, bot
+}
+
+func TestChartCommand(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month(), 1, 12, 0, 0, 0, time.Local)
+	app, bot := newChartTestApp(t, month, month, month.AddDate(0, 0, 1), month.AddDate(0, -3, 0))
+
+	tests := []struct {
+		command  string
+		fileName string
+		caption  string
+	}{
+		{"/chart", "chart-pie-month.png", "Spendings for current month by tag\nTotal: 40.00"},
+		{"/chart daily", "chart-daily-month.png", "Spendings for current month by day\nTotal: 40.00"},
+		{"/chart all daily", "", "Daily charts cover at most 31 days, try /chart daily month or /chart daily week."},
+		{"/chart pie all", "chart-pie-all.png", "Spendings for all time by tag\nTotal: 940.00"},
+		{"/chart trend", "chart-trend-month.png", "Spendings from " + month.AddDate(0, -11, 0).Format("January 2006") + " to " + month.Format("January 2006") + "\nTotal: 940.00"},
+		{"/chart daily year", "", "Daily charts cover at most 31 days, try /chart daily month or /chart daily week."},
+		{"/chart bars", "", chartUsage},
+		{"/chart pie month daily", "", chartUsage},
+	}
+	for i, test := range tests {
+		bot.Reset()
+		app.handleUpdate(ctx, testutils.NewTestCommandUpdate(i+1, 123, 7, test.command))
+
+		photos := bot.Photos()
+		if test.fileName == "" {
+			if len(photos) != 0 {
+				t.Errorf("%s: expected no chart, got %+v", test.command, photos)
+			}
+			bot.VerifyMessage(t, test.caption)
+			continue
+		}
+		if len(photos) != 1 {
+			t.Fatalf("%s: expected a chart, got %d photos and messages %q", test.command, len(photos), bot.SentMessages())
+		}
+		if photos[0].ChatID != 123 || photos[0].FileName != test.fileName || photos[0].Caption != test.caption {
+			t.Errorf("%s: expected %s with caption %q, got %s with caption %q", test.command, test.fileName, test.caption, photos[0].FileName, photos[0].Caption)
+		}
+		image, err := png.Decode(bytes.NewReader(photos[0].Data))
+		if err != nil {
+			t.Fatalf("%s: expected a PNG, got %v", test.command, err)
+		}
+		if image.Bounds().Dx() != chart.Width || image.Bounds().Dy() != chart.Height {
+			t.Errorf("%s: expected a %dx%d chart, got %v", test.command, chart.Width, chart.Height, image.Bounds())
+		}
+	}
+
+	bot.Reset()
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(len(tests)+1, 456, 7, "/chart"))
+	bot.VerifyMessage(t, "No spendings for current month.")
+}
+
+func TestChartValues(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.Local)
+	app, _ := newChartTestApp(t,
+		time.Date(2024, 5, 11, 13, 0, 0, 0, time.Local),
+		time.Date(2024, 5, 11, 18, 0, 0, 0, time.Local),
+		time.Date(2024, 5, 31, 23, 0, 0, 0, time.Local),
+		time.Date(2023, 7, 1, 9, 0, 0, 0, time.Local),
+	)
+	page, _ := listPage(123, "last_month", now)
+	page.filter.Limit = 0
+
+	title, values, total, err := app.tagChartValues(ctx, page, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectedTags := []chart.Value{{Label: "food", Value: 35}, {Label: "work", Value: 25}, {Label: "other", Value: 5}}
+	if title != "Spendings for last month by tag" || total != 40 || len(values) != len(expectedTags) {
+		t.Fatalf("Expected the tags of May, got %q %v %+v", title, total, values)
+	}
+	for i, value := range expectedTags {
+		if values[i] != value {
+			t.Errorf("Expected %+v, got %+v", value, values[i])
+		}
+	}
+
+	title, values, total, err = app.dailyChartValues(ctx, page, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if title != "Spendings for last month by day" || total != 40 || len(values) != 31 ||
+		values[0] != (chart.Value{Label: "1", Value: 0}) || values[10] != (chart.Value{Label: "11", Value: 35}) || values[30] != (chart.Value{Label: "31", Value: 5}) {
+		t.Errorf("Expected a bar for each day of May, got %q %v %+v", title, total, values)
+	}
+
+	title, values, total, err = app.trendChartValues(ctx, page, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if title != "Spendings from June 2023 to May 2024" || total != 940 || len(values) != 12 ||
+		values[0] != (chart.Value{Label: "Jun", Value: 0}) || values[1] != (chart.Value{Label: "Jul", Value: 900}) || values[11] != (chart.Value{Label: "May", Value: 40}) {
+		t.Errorf("Expected the trend from June 2023 to May 2024, got %q %v %+v", title, total, values)
+	}
+
+	// All time trends end now and daily charts start with the first spending
+	page, _ = listPage(123, "all", time.Date(2024, 6, 3, 12, 0, 0, 0, time.Local))
+	title, values, _, err = app.trendChartValues(ctx, page, time.Date(2024, 6, 3, 12, 0, 0, 0, time.Local))
+	if err != nil || !strings.HasSuffix(title, "to June 2024") || values[0].Label != "Jul" {
+		t.Errorf("Expected the trend up to June 2024, got %q %+v %v", title, values, err)
+	}
+	if _, _, _, err := app.dailyChartValues(ctx, page, now); err != errChartPeriodTooLong {
+		t.Errorf("Expected all time to be too long for a daily chart, got %v", err)
+	}
+}
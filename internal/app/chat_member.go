@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+func (app *App) StoreChatMember(ctx context.Context, member *models.ChatMember) (*models.ChatMember, error) {
+	member, err := app.DB.CreateChatMember(ctx, member)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store chat member: %w", err)
+	}
+	return member, nil
+}
+
+func (app *App) UpdateChatMember(ctx context.Context, member *models.ChatMember) (*models.ChatMember, error) {
+	err := app.DB.UpdateChatMember(ctx, member)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update chat member: %w", err)
+	}
+	return member, nil
+}
+
+func (app *App) FindChatMemberByUserId(ctx context.Context, chatID, userID int64) (*models.ChatMember, error) {
+	member, err := app.DB.FindChatMemberByUserId(ctx, chatID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find chat member: %w", err)
+	}
+	return member, nil
+}
+
+func (app *App) FindChatMemberByUserName(ctx context.Context, chatID int64, userName string) (*models.ChatMember, error) {
+	member, err := app.DB.FindChatMemberByUserName(ctx, chatID, userName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find chat member: %w", err)
+	}
+	return member, nil
+}
+
+func (app *App) GetChatMembers(ctx context.Context, chatID int64) ([]models.ChatMember, error) {
+	members, err := app.DB.GetChatMembers(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat members: %w", err)
+	}
+	return members, nil
+}
+
+// rememberChatMember records the sender of a group chat message as a member
+// of that chat, so spendings can later be split between everyone. Members
+// that were only mentioned so far are linked to their Telegram user here.
+func (app *App) rememberChatMember(ctx context.Context, message *tgbotapi.Message) (*models.ChatMember, error) {
+	if !isGroupChat(message.Chat) || message.From == nil {
+		return nil, nil
+	}
+
+	member, err := app.FindChatMemberByUserId(ctx, message.Chat.ID, message.From.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if member == nil && message.From.UserName != "" {
+		member, err = app.FindChatMemberByUserName(ctx, message.Chat.ID, message.From.UserName)
+		if err != nil {
+			return nil, err
+		}
+		if member != nil && member.UserId != 0 {
+			// The username now belongs to somebody else
+			member = nil
+		}
+	}
+
+	if member == nil {
+		return app.StoreChatMember(ctx, &models.ChatMember{
+			ChatId:      message.Chat.ID,
+			UserId:      message.From.ID,
+			UserName:    message.From.UserName,
+			DisplayName: senderName(message.From),
+		})
+	}
+
+	displayName := senderName(message.From)
+	if member.UserId == message.From.ID && member.UserName == message.From.UserName && member.DisplayName == displayName {
+		return member, nil
+	}
+
+	member.UserId = message.From.ID
+	member.UserName = message.From.UserName
+	member.DisplayName = displayName
+	return app.UpdateChatMember(ctx, member)
+}
+
+// findOrCreateMentionedMember returns the member behind an @mention, adding
+// a placeholder member when that person has not written in the chat yet.
+func (app *App) findOrCreateMentionedMember(ctx context.Context, chatID int64, userName string) (*models.ChatMember, error) {
+	member, err := app.FindChatMemberByUserName(ctx, chatID, userName)
+	if err != nil || member != nil {
+		return member, err
+	}
+
+	return app.StoreChatMember(ctx, &models.ChatMember{
+		ChatId:   chatID,
+		UserName: userName,
+	})
+}
+
+// isGroupChat reports whether a chat is a group or supergroup, whose
+// spendings are split between its members.
+func isGroupChat(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
+
+// isGroupChatID reports whether the chat with the ID is a group chat, for
+// when only the ID is known, like for stored spendings. Private chats have
+// the positive ID of their user. Channels have negative IDs like groups, so
+// Telegram is asked for the type of the others.
+func (app *App) isGroupChatID(ctx context.Context, chatID int64) (bool, error) {
+	if chatID > 0 {
+		return false, nil
+	}
+
+	chat, err := app.Bot.GetChat(ctx, chatID)
+	if err != nil {
+		return false, err
+	}
+	return isGroupChat(chat), nil
+}
@@ -0,0 +1,171 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/internal/database"
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+	"github.com/kiasaty/spendings-tracker/pkg/telegram"
+)
+
+// newConversationApp returns an app talking to a fake Telegram Bot API through
+// the real Telegram client, storing spendings in an in-memory SQLite database.
+func newConversationApp(t *testing.T) (*App, *testutils.FakeTelegramServer) {
+	t.Helper()
+
+	server := testutils.NewFakeTelegramServer(t)
+	t.Setenv("TELEGRAM_API_ENDPOINT", server.Endpoint())
+	t.Setenv("TELEGRAM_BOT_TOKEN", "test-token")
+	t.Setenv("DATABASE_URL", "sqlite://:memory:")
+
+	bot, err := telegram.NewTelegramBot()
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+
+	db, err := database.NewDatabaseClient()
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.MigrateUp(context.Background()); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	return &App{DB: db, Bot: bot, Workers: 2}, server
+}
+
+func TestConversation(t *testing.T) {
+	app, server := newConversationApp(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		app.FetchUpdates(ctx)
+		close(stopped)
+	}()
+
+	server.QueueUpdates(
+		testutils.NewTestUpdateFromUser(1, 100, 7, "Lunch 25 #food"),
+		testutils.NewTestUpdateFromUser(2, 100, 7, "Taxi 12.50"),
+		testutils.NewTestCommandUpdate(3, 100, 7, "/report"),
+	)
+
+	messages := server.WaitForMessages(t, 1, 5*time.Second)
+	expected := "Spending report for current month:\n\nfood: 25.00\nother: 12.50\n\nTotal: 37.50"
+	if messages[0].ChatID != 100 || messages[0].Text != expected {
+		t.Errorf("Expected report %q to chat 100, got %q to chat %d", expected, messages[0].Text, messages[0].ChatID)
+	}
+
+	// Editing the message updates the spending
+	edit := testutils.NewTestUpdateFromUser(1, 100, 7, "Lunch 30 #food")
+	edit.EditedMessage, edit.Message = edit.Message, nil
+	server.QueueUpdates(edit, testutils.NewTestCommandUpdate(4, 100, 7, "/report"))
+
+	messages = server.WaitForMessages(t, 2, 5*time.Second)
+	expected = "Spending report for current month:\n\nfood: 30.00\nother: 12.50\n\nTotal: 42.50"
+	if messages[1].Text != expected {
+		t.Errorf("Expected report %q, got %q", expected, messages[1].Text)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected fetching updates to stop after cancelling")
+	}
+
+	lastUpdateID, err := app.DB.GetLastUpdateId(context.Background())
+	if err != nil || lastUpdateID != 5 {
+		t.Errorf("Expected last update ID 5 to be stored, got %d (%v)", lastUpdateID, err)
+	}
+}
+
+func TestConversationResumesAfterLastUpdate(t *testing.T) {
+	app, server := newConversationApp(t)
+
+	if err := app.DB.SaveLastUpdateId(context.Background(), 10); err != nil {
+		t.Fatalf("Failed to save last update id: %v", err)
+	}
+
+	// Already handled before the restart
+	handled := testutils.NewTestUpdateFromUser(1, 100, 7, "Lunch 25")
+	handled.UpdateID = 10
+	pending := testutils.NewTestCommandUpdate(2, 100, 7, "/report")
+	pending.UpdateID = 11
+	server.QueueUpdates(handled, pending)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		app.FetchUpdates(ctx)
+		close(stopped)
+	}()
+
+	messages := server.WaitForMessages(t, 1, 5*time.Second)
+	if expected := "Spending report for current month:\n\nTotal: 0.00"; messages[0].Text != expected {
+		t.Errorf("Expected report %q, got %q", expected, messages[0].Text)
+	}
+	if offset := server.Offset(); offset < 11 {
+		t.Errorf("Expected polling to resume after update 10, got offset %d", offset)
+	}
+
+	cancel()
+	<-stopped
+}
+
+func TestConversationListPaging(t *testing.T) {
+	app, server := newConversationApp(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		app.FetchUpdates(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	var updates []*tgbotapi.Update
+	for i := 1; i <= 12; i++ {
+		updates = append(updates, testutils.NewTestUpdateFromUser(i, 100, 7, fmt.Sprintf("Coffee %d", i)))
+	}
+	updates = append(updates, testutils.NewTestCommandUpdate(13, 100, 7, "/list"))
+	server.QueueUpdates(updates...)
+
+	list := server.WaitForMessages(t, 1, 5*time.Second)[0]
+	if !strings.HasPrefix(list.Text, "Spendings for current month (1-10 of 12):") {
+		t.Fatalf("Expected the first page, got %q", list.Text)
+	}
+	if !strings.Contains(list.ReplyMarkup, `"callback_data":"list:10:month"`) {
+		t.Fatalf("Expected a next button, got %s", list.ReplyMarkup)
+	}
+
+	server.QueueUpdates(&tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "callback-1",
+		From:    &tgbotapi.User{ID: 7},
+		Message: &tgbotapi.Message{MessageID: list.MessageID, Chat: &tgbotapi.Chat{ID: 100}},
+		Data:    "list:10:month",
+	}})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.CallbackAnswers()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	edits := server.EditedMessages()
+	if len(edits) != 1 || edits[0].MessageID != list.MessageID || !strings.HasPrefix(edits[0].Text, "Spendings for current month (11-12 of 12):") {
+		t.Fatalf("Expected the list to show the second page, got %+v", edits)
+	}
+	if answers := server.CallbackAnswers(); len(answers) != 1 || answers[0].CallbackQueryID != "callback-1" {
+		t.Errorf("Expected the button press to be answered, got %+v", answers)
+	}
+}
@@ -0,0 +1,508 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/internal/database"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+// dashboardFiles holds the templates and static assets of the dashboard,
+// so it works without anything but the binary and without external CDNs
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+var dashboardTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"money": func(amount float64) string {
+		return fmt.Sprintf("%.2f", amount)
+	},
+}).ParseFS(dashboardFiles, "dashboard/*.html"))
+
+const (
+	dashboardCookieName = "dashboard_session"
+	// dashboardLinkLifetime is how long login links sent by the bot work
+	dashboardLinkLifetime = 15 * time.Minute
+	// dashboardSessionLifetime is how long a browser stays logged in
+	dashboardSessionLifetime = 30 * 24 * time.Hour
+	// dashboardMonthLayout is how the month of the dashboard is written in
+	// its URL
+	dashboardMonthLayout = "2006-01"
+	// dashboardTrendMonths is the number of months shown in the trend,
+	// ending with the month of the dashboard
+	dashboardTrendMonths = 12
+	// dashboardTableLimit limits the number of spendings in the table
+	dashboardTableLimit = 200
+)
+
+// dashboardPolicy only allows the dashboard's own assets and forms
+const dashboardPolicy = "default-src 'none'; style-src 'self'; img-src 'self'; form-action 'self'; frame-ancestors 'none'; base-uri 'none'"
+
+// Size of the trend chart, in the units of its viewBox
+const (
+	dashboardChartHeight = 160
+	dashboardBarWidth    = 40
+	dashboardBarGap      = 10
+)
+
+// dashboardPage is what the dashboard template shows.
+type dashboardPage struct {
+	ChatID   int64
+	Currency string
+
+	Month         string
+	MonthLabel    string
+	PreviousMonth string
+	NextMonth     string
+
+	Total         float64
+	PreviousTotal float64
+	Count         int
+	Tags          []dashboardAmount
+	People        []dashboardAmount
+	Trend         []dashboardBar
+
+	// Filters of the spendings table
+	Tag      string
+	Text     string
+	AllTags  []string
+	Table    []dashboardSpending
+	Matching int64
+}
+
+type dashboardAmount struct {
+	Name    string
+	Total   float64
+	Percent float64
+}
+
+// dashboardBar is a month of the trend chart.
+type dashboardBar struct {
+	Month    string
+	Label    string
+	Total    float64
+	Selected bool
+	X        int
+	Y        float64
+	Height   float64
+}
+
+type dashboardSpending struct {
+	Date        string
+	Sender      string
+	Description string
+	Tags        string
+	Cost        float64
+}
+
+// dashboardMessage is shown on pages with nothing but a message, like
+// failed logins.
+type dashboardMessage struct {
+	Title   string
+	Message string
+}
+
+func (app *App) StoreDashboardSession(ctx context.Context, session *models.DashboardSession) (*models.DashboardSession, error) {
+	session, err := app.DB.CreateDashboardSession(ctx, session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store dashboard session: %w", err)
+	}
+	return session, nil
+}
+
+func (app *App) UpdateDashboardSession(ctx context.Context, session *models.DashboardSession) error {
+	err := app.DB.UpdateDashboardSession(ctx, session)
+	if err != nil {
+		return fmt.Errorf("failed to update dashboard session: %w", err)
+	}
+	return nil
+}
+
+func (app *App) DeleteDashboardSession(ctx context.Context, session *models.DashboardSession) error {
+	err := app.DB.DeleteDashboardSession(ctx, session)
+	if err != nil {
+		return fmt.Errorf("failed to delete dashboard session: %w", err)
+	}
+	return nil
+}
+
+func (app *App) FindDashboardSessionByHash(ctx context.Context, tokenHash string) (*models.DashboardSession, error) {
+	session, err := app.DB.FindDashboardSessionByHash(ctx, tokenHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find dashboard session: %w", err)
+	}
+	return session, nil
+}
+
+// DashboardHandler returns the handler of the dashboard. Browsers log in
+// with a link the /dashboard command sends, and only see the spendings of
+// its chat.
+func (app *App) DashboardHandler() http.Handler {
+	static, err := fs.Sub(dashboardFiles, "dashboard/static")
+	if err != nil {
+		panic(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /dashboard/static/", http.StripPrefix("/dashboard/static/", http.FileServerFS(static)))
+	mux.HandleFunc("GET /dashboard", app.dashboardHome)
+	mux.HandleFunc("GET /dashboard/login", app.dashboardLoginPage)
+	mux.HandleFunc("POST /dashboard/login", app.dashboardLogin)
+	mux.HandleFunc("POST /dashboard/logout", app.dashboardLogout)
+	return mux
+}
+
+func (app *App) dashboardHome(w http.ResponseWriter, r *http.Request) {
+	session, err := app.dashboardSession(r)
+	if err != nil {
+		app.dashboardError(w, r, err)
+		return
+	}
+	if session == nil {
+		renderDashboard(w, r, http.StatusUnauthorized, "message.html", dashboardMessage{
+			Title:   "Not logged in",
+			Message: "Send /dashboard to the bot in the chat whose spendings you want to see, and open the link it replies with.",
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	if value := query.Get("month"); value != "" {
+		month, err = time.ParseInLocation(dashboardMonthLayout, value, time.Local)
+		if err != nil {
+			renderDashboard(w, r, http.StatusBadRequest, "message.html", dashboardMessage{
+				Title:   "Invalid month",
+				Message: fmt.Sprintf("%q isn't a month like %s.", value, now.Format(dashboardMonthLayout)),
+			})
+			return
+		}
+	}
+
+	page, err := app.dashboardData(r.Context(), session.ChatId, month, strings.TrimPrefix(query.Get("tag"), "#"), query.Get("text"))
+	if err != nil {
+		app.dashboardError(w, r, err)
+		return
+	}
+	renderDashboard(w, r, http.StatusOK, "dashboard.html", page)
+}
+
+// dashboardData sums up the spendings of the chat in the month and the
+// months before it like /report does, and lists the spendings of the month
+// matching the tag and text.
+func (app *App) dashboardData(ctx context.Context, chatID int64, month time.Time, tag, text string) (*dashboardPage, error) {
+	page := &dashboardPage{
+		ChatID:        chatID,
+		Currency:      app.Currency,
+		Month:         month.Format(dashboardMonthLayout),
+		MonthLabel:    month.Format("January 2006"),
+		PreviousMonth: month.AddDate(0, -1, 0).Format(dashboardMonthLayout),
+		NextMonth:     month.AddDate(0, 1, 0).Format(dashboardMonthLayout),
+		Tag:           tag,
+		Text:          text,
+	}
+
+	monthEnd := month.AddDate(0, 1, 0).Add(-time.Second)
+	trendStart := month.AddDate(0, 1-dashboardTrendMonths, 0)
+	spendings, _, err := app.FindSpendings(ctx, database.SpendingFilter{ChatId: chatID, StartDate: trendStart, EndDate: monthEnd})
+	if err != nil {
+		return nil, err
+	}
+
+	byMonth := make(map[string][]models.Spending)
+	for _, spending := range spendings {
+		key := spending.SpentAt.Local().Format(dashboardMonthLayout)
+		byMonth[key] = append(byMonth[key], spending)
+	}
+
+	var highest float64
+	for i := 0; i < dashboardTrendMonths; i++ {
+		start := trendStart.AddDate(0, i, 0)
+		key := start.Format(dashboardMonthLayout)
+		summary := newSpendingReport(byMonth[key])
+		page.Trend = append(page.Trend, dashboardBar{
+			Month:    key,
+			Label:    start.Format("Jan 06"),
+			Total:    summary.Total,
+			Selected: key == page.Month,
+			X:        i * (dashboardBarWidth + dashboardBarGap),
+		})
+		if summary.Total > highest {
+			highest = summary.Total
+		}
+	}
+	for i := range page.Trend {
+		bar := &page.Trend[i]
+		if highest > 0 {
+			bar.Height = bar.Total / highest * dashboardChartHeight
+		}
+		bar.Y = dashboardChartHeight - bar.Height
+	}
+	page.PreviousTotal = page.Trend[len(page.Trend)-2].Total
+
+	summary := newSpendingReport(byMonth[page.Month])
+	page.Total = summary.Total
+	page.Count = len(byMonth[page.Month])
+	for _, name := range summary.tagNames() {
+		page.Tags = append(page.Tags, dashboardAmount{Name: name, Total: summary.Tags[name], Percent: percentOf(summary.Tags[name], summary.Total)})
+	}
+	for _, name := range summary.personNames() {
+		page.People = append(page.People, dashboardAmount{Name: name, Total: summary.People[name], Percent: percentOf(summary.People[name], summary.Total)})
+	}
+
+	tags, err := app.FindTags(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		page.AllTags = append(page.AllTags, tag.Name)
+	}
+
+	filter := database.SpendingFilter{ChatId: chatID, StartDate: month, EndDate: monthEnd, Text: text, Limit: dashboardTableLimit}
+	if tag != "" {
+		filter.Tags = []string{tag}
+	}
+	table, matching, err := app.FindSpendings(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	page.Matching = matching
+	for _, spending := range table {
+		page.Table = append(page.Table, dashboardSpending{
+			Date:        spending.SpentAt.Local().Format("2006-01-02"),
+			Sender:      reportPersonName(spending),
+			Description: spending.Description,
+			Tags:        strings.Join(spendingTagNames(spending), ", "),
+			Cost:        spending.Cost,
+		})
+	}
+
+	return page, nil
+}
+
+// percentOf returns how many percent part is of total, rounded to one
+// decimal.
+func percentOf(part, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(int(part/total*1000+0.5)) / 10
+}
+
+// dashboardLoginPage asks to log in with the token of a login link. The
+// link isn't used up until the form is sent, so link previews of chat apps
+// opening it don't log anyone out of it.
+func (app *App) dashboardLoginPage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	session, err := app.findDashboardLink(r.Context(), token)
+	if err != nil {
+		app.dashboardError(w, r, err)
+		return
+	}
+	if session == nil {
+		renderInvalidDashboardLink(w, r)
+		return
+	}
+
+	renderDashboard(w, r, http.StatusOK, "login.html", struct {
+		ChatID int64
+		Token  string
+	}{session.ChatId, token})
+}
+
+// dashboardLogin exchanges the token of a login link for a session cookie.
+// The link can't be used again afterwards.
+func (app *App) dashboardLogin(w http.ResponseWriter, r *http.Request) {
+	session, err := app.findDashboardLink(r.Context(), r.PostFormValue("token"))
+	if err != nil {
+		app.dashboardError(w, r, err)
+		return
+	}
+	if session == nil {
+		renderInvalidDashboardLink(w, r)
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		app.dashboardError(w, r, err)
+		return
+	}
+	now := time.Now()
+	session.TokenHash = hashToken(token)
+	session.LoggedInAt = &now
+	session.ExpiresAt = now.Add(dashboardSessionLifetime)
+	if err := app.UpdateDashboardSession(r.Context(), session); err != nil {
+		app.dashboardError(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     dashboardCookieName,
+		Value:    token,
+		Path:     "/dashboard",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   app.dashboardCookieSecure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+func (app *App) dashboardLogout(w http.ResponseWriter, r *http.Request) {
+	session, err := app.dashboardSession(r)
+	if err != nil {
+		app.dashboardError(w, r, err)
+		return
+	}
+	if session != nil {
+		if err := app.DeleteDashboardSession(r.Context(), session); err != nil {
+			app.dashboardError(w, r, err)
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     dashboardCookieName,
+		Path:     "/dashboard",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   app.dashboardCookieSecure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	renderDashboard(w, r, http.StatusOK, "message.html", dashboardMessage{
+		Title:   "Logged out",
+		Message: "Send /dashboard to the bot to log in again.",
+	})
+}
+
+// dashboardSession returns the session of the request's cookie, or nil when
+// it has none or it expired.
+func (app *App) dashboardSession(r *http.Request) (*models.DashboardSession, error) {
+	cookie, err := r.Cookie(dashboardCookieName)
+	if err != nil || !strings.HasPrefix(cookie.Value, tokenPrefix) {
+		return nil, nil
+	}
+
+	session, err := app.FindDashboardSessionByHash(r.Context(), hashToken(cookie.Value))
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.LoggedInAt == nil || !time.Now().Before(session.ExpiresAt) {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// findDashboardLink returns the session of an unused login link that
+// hasn't expired, or nil.
+func (app *App) findDashboardLink(ctx context.Context, token string) (*models.DashboardSession, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, nil
+	}
+
+	session, err := app.FindDashboardSessionByHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.LoggedInAt != nil || !time.Now().Before(session.ExpiresAt) {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// dashboardCookieSecure reports whether the session cookie should only be
+// sent over HTTPS, which is the case when the dashboard is served with it.
+func (app *App) dashboardCookieSecure(r *http.Request) bool {
+	return r.TLS != nil || strings.HasPrefix(app.DashboardURL, "https://")
+}
+
+func renderInvalidDashboardLink(w http.ResponseWriter, r *http.Request) {
+	renderDashboard(w, r, http.StatusUnauthorized, "message.html", dashboardMessage{
+		Title:   "Invalid login link",
+		Message: "The login link was already used or expired. Send /dashboard to the bot for a new one.",
+	})
+}
+
+func (app *App) dashboardError(w http.ResponseWriter, r *http.Request, err error) {
+	fmt.Printf("Error handling %s %s: %v\n", r.Method, r.URL.Path, err)
+	renderDashboard(w, r, http.StatusInternalServerError, "message.html", dashboardMessage{
+		Title:   "Something went wrong",
+		Message: "The page couldn't be loaded, please try again later.",
+	})
+}
+
+// renderDashboard writes a page of the dashboard. Pages are rendered in
+// full before anything is written, so template errors don't leave half a
+// page behind.
+func renderDashboard(w http.ResponseWriter, r *http.Request, status int, name string, data any) {
+	var page bytes.Buffer
+	if err := dashboardTemplates.ExecuteTemplate(&page, name, data); err != nil {
+		fmt.Printf("Error rendering %s for %s %s: %v\n", name, r.Method, r.URL.Path, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Content-Security-Policy", dashboardPolicy)
+	header.Set("Cache-Control", "no-store")
+	header.Set("Referrer-Policy", "no-referrer")
+	header.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(page.Bytes())
+}
+
+// issueDashboardLink creates a login link to the dashboard of the chat.
+func (app *App) issueDashboardLink(ctx context.Context, chatID, userID int64) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = app.StoreDashboardSession(ctx, &models.DashboardSession{
+		ChatId:    chatID,
+		UserId:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(dashboardLinkLifetime),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(app.DashboardURL, "/") + "/dashboard/login?token=" + token, nil
+}
+
+// handleDashboardCommand sends a login link to the dashboard of the chat to
+// the requester in private, since anyone with the link can log in.
+func (app *App) handleDashboardCommand(ctx context.Context, message *tgbotapi.Message) {
+	if app.DashboardURL == "" {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "The dashboard isn't set up. Set DASHBOARD_URL to the address serve-api is reachable at.")
+		return
+	}
+	if message.From == nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "I can only send this to a user.")
+		return
+	}
+
+	link, err := app.issueDashboardLink(ctx, message.Chat.ID, message.From.ID)
+	if err != nil {
+		fmt.Printf("Error creating dashboard link: %v\n", err)
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to create the dashboard link")
+		return
+	}
+
+	app.sendPrivately(ctx, message, fmt.Sprintf(
+		"Open the dashboard of %s with this link within %d minutes. It only works once:\n\n%s",
+		describeChat(message), int(dashboardLinkLifetime.Minutes()), link,
+	))
+}
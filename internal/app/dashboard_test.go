@@ -0,0 +1,252 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+var dashboardLinkPattern = regexp.MustCompile(`https://spendings\.example\.com/dashboard/login\?token=(st_[A-Za-z0-9_-]+)`)
+
+func newDashboardTestApp(t *testing.T) (*App, *testutils.MockTelegramBot, http.Handler) {
+	t.Helper()
+
+	bot := testutils.NewMockTelegramBot()
+	app := &App{DB: testutils.NewMockDatabaseClient(), Bot: bot, Currency: "EUR", DashboardURL: "https://spendings.example.com/"}
+	return app, bot, app.WebHandler()
+}
+
+// dashboardRequest sends a request to the dashboard with the session cookie,
+// unless it is empty, and returns the response.
+func dashboardRequest(t *testing.T, handler http.Handler, method, path string, form url.Values, cookie string, expectedStatus int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var request *http.Request
+	if form != nil {
+		request = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		request = httptest.NewRequest(method, path, nil)
+	}
+	if cookie != "" {
+		request.AddCookie(&http.Cookie{Name: dashboardCookieName, Value: cookie})
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != expectedStatus {
+		t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, expectedStatus, recorder.Code, recorder.Body.String())
+	}
+	return recorder
+}
+
+// loginToDashboard asks the bot for a login link in the chat, uses it and
+// returns the session cookie.
+func loginToDashboard(t *testing.T, app *App, bot *testutils.MockTelegramBot, handler http.Handler, chatID int64) string {
+	t.Helper()
+
+	bot.Reset()
+	app.handleUpdate(context.Background(), testutils.NewTestCommandUpdate(1, chatID, 7, "/dashboard"))
+	messages := bot.SentMessagesTo(7)
+	if len(messages) != 1 {
+		t.Fatalf("Expected a login link, got %q", messages)
+	}
+	match := dashboardLinkPattern.FindStringSubmatch(messages[0])
+	if match == nil {
+		t.Fatalf("Expected a login link, got %q", messages[0])
+	}
+
+	link := strings.TrimPrefix(match[0], "https://spendings.example.com")
+	dashboardRequest(t, handler, "GET", link, nil, "", http.StatusOK)
+	response := dashboardRequest(t, handler, "POST", "/dashboard/login", url.Values{"token": {match[1]}}, "", http.StatusSeeOther)
+	for _, cookie := range response.Result().Cookies() {
+		if cookie.Name == dashboardCookieName {
+			return cookie.Value
+		}
+	}
+	t.Fatalf("Expected a session cookie, got %v", response.Header())
+	return ""
+}
+
+func TestDashboardLogin(t *testing.T) {
+	ctx := context.Background()
+	app, bot, handler := newDashboardTestApp(t)
+
+	dashboardRequest(t, handler, "GET", "/dashboard", nil, "", http.StatusUnauthorized)
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(1, 123, 7, "/dashboard"))
+	match := dashboardLinkPattern.FindStringSubmatch(strings.Join(bot.SentMessages(), "\n"))
+	if match == nil {
+		t.Fatalf("Expected a login link, got %q", bot.SentMessages())
+	}
+	token := match[1]
+
+	// Opening the link, like link previews do, doesn't use it up
+	page := dashboardRequest(t, handler, "GET", "/dashboard/login?token="+token, nil, "", http.StatusOK)
+	page = dashboardRequest(t, handler, "GET", "/dashboard/login?token="+token, nil, "", http.StatusOK)
+	if !strings.Contains(page.Body.String(), `name="token" value="`+token+`"`) {
+		t.Errorf("Expected a form to log in with the token, got %s", page.Body.String())
+	}
+
+	response := dashboardRequest(t, handler, "POST", "/dashboard/login", url.Values{"token": {token}}, "", http.StatusSeeOther)
+	if location := response.Header().Get("Location"); location != "/dashboard" {
+		t.Errorf("Expected a redirect to the dashboard, got %q", location)
+	}
+	var cookie *http.Cookie
+	for _, c := range response.Result().Cookies() {
+		if c.Name == dashboardCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil || cookie.Value == token || !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("Expected a new secure session cookie, got %+v", cookie)
+	}
+
+	dashboardRequest(t, handler, "POST", "/dashboard/login", url.Values{"token": {token}}, "", http.StatusUnauthorized)
+	dashboardRequest(t, handler, "GET", "/dashboard/login?token="+token, nil, "", http.StatusUnauthorized)
+	dashboardRequest(t, handler, "GET", "/dashboard", nil, token, http.StatusUnauthorized)
+
+	page = dashboardRequest(t, handler, "GET", "/dashboard", nil, cookie.Value, http.StatusOK)
+	if !strings.Contains(page.Body.String(), "Spendings of chat 123") {
+		t.Errorf("Expected the dashboard of chat 123, got %s", page.Body.String())
+	}
+	if policy := page.Header().Get("Content-Security-Policy"); !strings.Contains(policy, "default-src 'none'") {
+		t.Errorf("Expected a strict content security policy, got %q", policy)
+	}
+
+	dashboardRequest(t, handler, "POST", "/dashboard/logout", url.Values{}, cookie.Value, http.StatusOK)
+	dashboardRequest(t, handler, "GET", "/dashboard", nil, cookie.Value, http.StatusUnauthorized)
+}
+
+func TestDashboardLinkIsSentPrivately(t *testing.T) {
+	ctx := context.Background()
+	app, bot, _ := newDashboardTestApp(t)
+
+	update := testutils.NewTestGroupUpdate(1, -100, tgbotapi.User{ID: 7, FirstName: "Kia"}, "/dashboard")
+	update.Message.Chat.Title = "Flat"
+	app.handleUpdate(ctx, update)
+
+	private := bot.SentMessagesTo(7)
+	if len(private) != 1 || !strings.HasPrefix(private[0], `Open the dashboard of the chat "Flat" with this link`) || !dashboardLinkPattern.MatchString(private[0]) {
+		t.Errorf("Expected the login link in a private message, got %q", private)
+	}
+	if group := bot.SentMessagesTo(-100); len(group) != 1 || group[0] != "I sent it to you in a private message." {
+		t.Errorf("Expected only a note in the group, got %q", group)
+	}
+
+	// Users who never talked to the bot can't get a link
+	bot.Reset()
+	bot.SetUnreachable(8)
+	app.handleUpdate(ctx, testutils.NewTestGroupUpdate(2, -100, tgbotapi.User{ID: 8, FirstName: "Ali"}, "/dashboard"))
+	if messages := bot.SentMessages(); len(messages) != 1 || dashboardLinkPattern.MatchString(messages[0]) {
+		t.Errorf("Expected no link to be sent, got %q", messages)
+	}
+	bot.VerifyMessage(t, "I couldn't send you a private message. Start a chat with me, then try again.")
+}
+
+func TestDashboardLoginExpired(t *testing.T) {
+	ctx := context.Background()
+	app, bot, handler := newDashboardTestApp(t)
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(1, 123, 7, "/dashboard"))
+	match := dashboardLinkPattern.FindStringSubmatch(strings.Join(bot.SentMessages(), "\n"))
+	if match == nil {
+		t.Fatalf("Expected a login link, got %q", bot.SentMessages())
+	}
+
+	session, _ := app.FindDashboardSessionByHash(ctx, hashToken(match[1]))
+	session.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := app.UpdateDashboardSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	dashboardRequest(t, handler, "POST", "/dashboard/login", url.Values{"token": {match[1]}}, "", http.StatusUnauthorized)
+
+	app.DashboardURL = ""
+	bot.Reset()
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(2, 123, 7, "/dashboard"))
+	bot.VerifyMessage(t, "The dashboard isn't set up. Set DASHBOARD_URL to the address serve-api is reachable at.")
+}
+
+func TestDashboardPage(t *testing.T) {
+	ctx := context.Background()
+	app, bot, handler := newDashboardTestApp(t)
+
+	for _, spending := range []struct {
+		chatID      int64
+		sender      string
+		description string
+		cost        float64
+		spentAt     time.Time
+		tags        []string
+	}{
+		{123, "Sara", "Lunch <b>25</b>", 25, time.Date(2024, 5, 11, 13, 0, 0, 0, time.Local), []string{"food", "work"}},
+		{123, "Ali", "Groceries 10", 10, time.Date(2024, 5, 12, 18, 0, 0, 0, time.Local), []string{"food"}},
+		{123, "Ali", "Taxi 5", 5, time.Date(2024, 5, 31, 23, 0, 0, 0, time.Local), nil},
+		{123, "Ali", "Rent 900", 900, time.Date(2024, 4, 1, 9, 0, 0, 0, time.Local), []string{"rent"}},
+		{123, "Ali", "Old rent 800", 800, time.Date(2023, 5, 1, 9, 0, 0, 0, time.Local), []string{"rent"}},
+		{456, "Bob", "Secret books 40", 40, time.Date(2024, 5, 13, 10, 0, 0, 0, time.Local), []string{"books"}},
+	} {
+		stored := &models.Spending{ChatId: spending.chatID, SenderName: spending.sender, Description: spending.description, Cost: spending.cost, SpentAt: spending.spentAt}
+		if err := app.saveAPISpending(ctx, stored, spending.tags, false); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	page, err := app.dashboardData(ctx, 123, time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local), "", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if page.Total != 40 || page.PreviousTotal != 900 || page.Count != 3 || page.Matching != 3 || len(page.Table) != 3 {
+		t.Errorf("Expected May to have 3 spendings of 40 after 900 in April, got %+v", page)
+	}
+	expectedTags := []dashboardAmount{{"food", 35, 87.5}, {"work", 25, 62.5}, {"other", 5, 12.5}}
+	if len(page.Tags) != len(expectedTags) {
+		t.Fatalf("Expected tags %+v, got %+v", expectedTags, page.Tags)
+	}
+	for i, tag := range expectedTags {
+		if page.Tags[i] != tag {
+			t.Errorf("Expected tag %+v, got %+v", tag, page.Tags[i])
+		}
+	}
+	if len(page.Trend) != 12 || page.Trend[0].Month != "2023-06" || page.Trend[10].Total != 900 || page.Trend[10].Height != dashboardChartHeight ||
+		!page.Trend[11].Selected || page.Trend[11].Total != 40 {
+		t.Errorf("Expected the trend from June 2023 to May 2024, got %+v", page.Trend)
+	}
+	if strings.Join(page.AllTags, ",") != "food,rent,work" {
+		t.Errorf("Expected the tags of the chat, got %v", page.AllTags)
+	}
+
+	cookie := loginToDashboard(t, app, bot, handler, 123)
+	body := dashboardRequest(t, handler, "GET", "/dashboard?month=2024-05", nil, cookie, http.StatusOK).Body.String()
+	for _, expected := range []string{"May 2024", "40.00 EUR", "900.00 EUR", "#food", "Lunch &lt;b&gt;25&lt;/b&gt;", "Taxi 5", "/dashboard/static/dashboard.css"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected the dashboard to contain %q", expected)
+		}
+	}
+	for _, unexpected := range []string{"Secret books", "books", "Old rent", "<b>25</b>", "https://", "http://"} {
+		if strings.Contains(body, unexpected) {
+			t.Errorf("Expected the dashboard not to contain %q", unexpected)
+		}
+	}
+
+	body = dashboardRequest(t, handler, "GET", "/dashboard?month=2024-05&tag=food&text=lunch", nil, cookie, http.StatusOK).Body.String()
+	if !strings.Contains(body, "Lunch &lt;b&gt;25") || strings.Contains(body, "Groceries 10") || strings.Contains(body, "Taxi 5") {
+		t.Errorf("Expected only lunch to match the filters, got %s", body)
+	}
+
+	dashboardRequest(t, handler, "GET", "/dashboard?month=May", nil, cookie, http.StatusBadRequest)
+
+	css := dashboardRequest(t, handler, "GET", "/dashboard/static/dashboard.css", nil, "", http.StatusOK)
+	if !strings.HasPrefix(css.Header().Get("Content-Type"), "text/css") {
+		t.Errorf("Expected the stylesheet, got %q", css.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,216 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// updateDispatcher hands updates to a fixed number of workers. Updates of the
+// same chat always go to the same worker, so they are handled in the order
+// they arrived (an edit never overtakes the original message), while updates
+// of different chats are handled in parallel.
+//
+// It also keeps track of the watermark: the highest update ID up to which
+// every update has been handled. Only the watermark may be acknowledged to
+// Telegram, since updates after it can still be in progress.
+//
+// Updates whose handling fails are retried, with growing delays, before the
+// next update of their worker, so they keep their order. Updates that still
+// fail after maxUpdateAttempts are skipped, so the watermark moves past them.
+// When the dispatcher is stopped while an update still fails, it is given up
+// without counting as handled, so the watermark stays below it and it is
+// fetched again after a restart.
+type updateDispatcher struct {
+	handle    func(*tgbotapi.Update) error
+	queues    []chan tgbotapi.Update
+	workers   sync.WaitGroup
+	inFlight  sync.WaitGroup
+	completed chan struct{}
+	stopping  chan struct{}
+	// retryDelay is the delay before the first retry of a failed update,
+	// doubling with each further one
+	retryDelay time.Duration
+
+	mu        sync.Mutex
+	watermark int
+	pending   []int
+	done      map[int]bool
+}
+
+// maxUpdateAttempts is how often a failing update is handled before it is
+// skipped, so it doesn't hold up the updates after it forever
+const maxUpdateAttempts = 6
+
+func newUpdateDispatcher(workers int, watermark int, handle func(*tgbotapi.Update) error) *updateDispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+
+	d := &updateDispatcher{
+		handle:     handle,
+		queues:     make([]chan tgbotapi.Update, workers),
+		completed:  make(chan struct{}, 1),
+		stopping:   make(chan struct{}),
+		retryDelay: time.Second,
+		watermark:  watermark,
+		done:       make(map[int]bool),
+	}
+
+	for i := range d.queues {
+		d.queues[i] = make(chan tgbotapi.Update, 100)
+		d.workers.Add(1)
+		go d.work(d.queues[i])
+	}
+
+	return d
+}
+
+func (d *updateDispatcher) work(queue chan tgbotapi.Update) {
+	defer d.workers.Done()
+
+	// After giving up on an update, the later ones of its chats are left for
+	// the restart too, so they aren't handled before it
+	gaveUp := false
+	for update := range queue {
+		if !gaveUp && d.handleWithRetries(&update) {
+			d.complete(update.UpdateID)
+		} else {
+			gaveUp = true
+			d.giveUp(update.UpdateID)
+		}
+	}
+}
+
+// handleWithRetries handles the update until it succeeds, fails
+// maxUpdateAttempts times or the dispatcher is stopped. It reports whether
+// the update is done with, which is not the case when the dispatcher was
+// stopped while it still failed.
+func (d *updateDispatcher) handleWithRetries(update *tgbotapi.Update) bool {
+	delay := d.retryDelay
+	for attempt := 1; ; attempt++ {
+		err := d.handle(update)
+		if err == nil {
+			return true
+		}
+		if attempt == maxUpdateAttempts {
+			fmt.Printf("Error handling update %d, skipping it after %d attempts: %v\n", update.UpdateID, attempt, err)
+			return true
+		}
+		fmt.Printf("Error handling update %d, retrying in %v: %v\n", update.UpdateID, delay, err)
+
+		select {
+		case <-d.stopping:
+			return false
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// Dispatch queues an update for handling. Updates that are already handled
+// or in progress are ignored, which happens when Telegram returns updates
+// again because they weren't acknowledged yet. It reports whether the
+// update was queued.
+func (d *updateDispatcher) Dispatch(update tgbotapi.Update) bool {
+	d.mu.Lock()
+	if update.UpdateID <= d.watermark || d.isPending(update.UpdateID) {
+		d.mu.Unlock()
+		return false
+	}
+	d.pending = append(d.pending, update.UpdateID)
+	sort.Ints(d.pending)
+	d.mu.Unlock()
+
+	d.inFlight.Add(1)
+	d.queues[updateChatId(&update)%uint64(len(d.queues))] <- update
+	return true
+}
+
+func (d *updateDispatcher) isPending(updateID int) bool {
+	i := sort.SearchInts(d.pending, updateID)
+	return i < len(d.pending) && d.pending[i] == updateID
+}
+
+func (d *updateDispatcher) complete(updateID int) {
+	d.mu.Lock()
+	d.done[updateID] = true
+	for len(d.pending) > 0 && d.done[d.pending[0]] {
+		d.watermark = d.pending[0]
+		delete(d.done, d.pending[0])
+		d.pending = d.pending[1:]
+	}
+	d.mu.Unlock()
+
+	d.inFlight.Done()
+
+	select {
+	case d.completed <- struct{}{}:
+	default:
+	}
+}
+
+// giveUp stops waiting for an update that failed. It stays pending, so the
+// watermark doesn't move past it.
+func (d *updateDispatcher) giveUp(updateID int) {
+	fmt.Printf("Gave up on update %d, it will be handled again after a restart\n", updateID)
+	d.inFlight.Done()
+}
+
+// Watermark returns the highest update ID up to which all dispatched updates
+// have been handled.
+func (d *updateDispatcher) Watermark() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.watermark
+}
+
+// Pending returns the number of dispatched updates that are not handled yet.
+func (d *updateDispatcher) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.pending)
+}
+
+// Completed receives a value after updates have been handled.
+func (d *updateDispatcher) Completed() <-chan struct{} {
+	return d.completed
+}
+
+// Wait blocks until all dispatched updates have been handled.
+func (d *updateDispatcher) Wait() {
+	d.inFlight.Wait()
+}
+
+// Stop waits for the dispatched updates to be handled and stops the workers.
+// Failing updates aren't retried any more. No updates may be dispatched
+// afterwards.
+func (d *updateDispatcher) Stop() {
+	close(d.stopping)
+	for _, queue := range d.queues {
+		close(queue)
+	}
+	d.workers.Wait()
+}
+
+// updateChatId returns the ID of the chat an update belongs to, or 0 for
+// updates without a chat.
+func updateChatId(update *tgbotapi.Update) uint64 {
+	var chat *tgbotapi.Chat
+	switch {
+	case update.Message != nil:
+		chat = update.Message.Chat
+	case update.EditedMessage != nil:
+		chat = update.EditedMessage.Chat
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		chat = update.CallbackQuery.Message.Chat
+	}
+
+	if chat == nil {
+		return 0
+	}
+	return uint64(chat.ID)
+}
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+)
+
+func newTestChatUpdate(updateID int, chatID int64) tgbotapi.Update {
+	update := testutils.NewTestUpdate(updateID, chatID, "")
+	update.UpdateID = updateID
+	return *update
+}
+
+func TestDispatcherKeepsOrderWithinChat(t *testing.T) {
+	var mu sync.Mutex
+	handled := make(map[int64][]int)
+
+	dispatcher := newUpdateDispatcher(3, 0, func(update *tgbotapi.Update) error {
+		// Let later updates of other chats overtake this one
+		time.Sleep(time.Duration(update.UpdateID%3) * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		chatID := update.Message.Chat.ID
+		handled[chatID] = append(handled[chatID], update.UpdateID)
+		return nil
+	})
+
+	for updateID := 1; updateID <= 60; updateID++ {
+		dispatcher.Dispatch(newTestChatUpdate(updateID, int64(updateID%4)))
+	}
+	dispatcher.Stop()
+
+	for chatID, updateIDs := range handled {
+		if len(updateIDs) != 15 {
+			t.Errorf("Expected 15 updates for chat %d, got %d", chatID, len(updateIDs))
+		}
+		for i := 1; i < len(updateIDs); i++ {
+			if updateIDs[i] < updateIDs[i-1] {
+				t.Errorf("Update %d of chat %d was handled before update %d", updateIDs[i], chatID, updateIDs[i-1])
+			}
+		}
+	}
+
+	if watermark := dispatcher.Watermark(); watermark != 60 {
+		t.Errorf("Expected watermark 60, got %d", watermark)
+	}
+}
+
+func TestDispatcherHandlesChatsInParallel(t *testing.T) {
+	release := make(chan struct{})
+	slowStarted := make(chan struct{})
+	fastHandled := make(chan struct{})
+
+	dispatcher := newUpdateDispatcher(2, 0, func(update *tgbotapi.Update) error {
+		if update.Message.Chat.ID == 1 {
+			close(slowStarted)
+			<-release
+			return nil
+		}
+		close(fastHandled)
+		return nil
+	})
+	defer dispatcher.Stop()
+
+	dispatcher.Dispatch(newTestChatUpdate(1, 1))
+	<-slowStarted
+	dispatcher.Dispatch(newTestChatUpdate(2, 2))
+
+	select {
+	case <-fastHandled:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected an update of another chat to be handled while the first one is slow")
+	}
+
+	// The slow update is still in progress, so it can't be acknowledged yet
+	if watermark := dispatcher.Watermark(); watermark != 0 {
+		t.Errorf("Expected watermark 0 while update 1 is in progress, got %d", watermark)
+	}
+
+	close(release)
+	dispatcher.Wait()
+
+	if watermark := dispatcher.Watermark(); watermark != 2 {
+		t.Errorf("Expected watermark 2, got %d", watermark)
+	}
+}
+
+func TestDispatcherIgnoresUpdatesInProgressOrHandled(t *testing.T) {
+	release := make(chan struct{})
+
+	dispatcher := newUpdateDispatcher(1, 5, func(update *tgbotapi.Update) error {
+		<-release
+		return nil
+	})
+	defer dispatcher.Stop()
+
+	if dispatcher.Dispatch(newTestChatUpdate(5, 1)) {
+		t.Errorf("Expected update before the watermark to be ignored")
+	}
+	if !dispatcher.Dispatch(newTestChatUpdate(6, 1)) {
+		t.Errorf("Expected new update to be dispatched")
+	}
+	if dispatcher.Dispatch(newTestChatUpdate(6, 1)) {
+		t.Errorf("Expected update in progress to be ignored")
+	}
+
+	close(release)
+	dispatcher.Wait()
+
+	if dispatcher.Pending() != 0 {
+		t.Errorf("Expected no pending updates, got %d", dispatcher.Pending())
+	}
+}
+
+func TestDispatcherRetriesFailedUpdates(t *testing.T) {
+	var mu sync.Mutex
+	var handled []int
+	attempts := 0
+
+	dispatcher := newUpdateDispatcher(1, 0, func(update *tgbotapi.Update) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if update.UpdateID == 1 && attempts < 2 {
+			attempts++
+			return errors.New("database is down")
+		}
+		handled = append(handled, update.UpdateID)
+		return nil
+	})
+	dispatcher.retryDelay = time.Millisecond
+	defer dispatcher.Stop()
+
+	dispatcher.Dispatch(newTestChatUpdate(1, 1))
+	dispatcher.Dispatch(newTestChatUpdate(2, 1))
+	dispatcher.Wait()
+
+	if !slices.Equal(handled, []int{1, 2}) {
+		t.Errorf("Expected the failed update to be retried before the next one, got %v", handled)
+	}
+	if watermark := dispatcher.Watermark(); watermark != 2 {
+		t.Errorf("Expected watermark 2, got %d", watermark)
+	}
+}
+
+func TestDispatcherSkipsUpdatesThatNeverSucceed(t *testing.T) {
+	var mu sync.Mutex
+	var handled []int
+	attempts := 0
+
+	dispatcher := newUpdateDispatcher(1, 0, func(update *tgbotapi.Update) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if update.UpdateID == 1 {
+			attempts++
+			return errors.New("invalid update")
+		}
+		handled = append(handled, update.UpdateID)
+		return nil
+	})
+	dispatcher.retryDelay = time.Microsecond
+	defer dispatcher.Stop()
+
+	dispatcher.Dispatch(newTestChatUpdate(1, 1))
+	dispatcher.Dispatch(newTestChatUpdate(2, 1))
+	dispatcher.Wait()
+
+	if attempts != maxUpdateAttempts {
+		t.Errorf("Expected %d attempts, got %d", maxUpdateAttempts, attempts)
+	}
+	if !slices.Equal(handled, []int{2}) {
+		t.Errorf("Expected the next update to be handled after skipping the failing one, got %v", handled)
+	}
+	if watermark := dispatcher.Watermark(); watermark != 2 {
+		t.Errorf("Expected the watermark to move past the skipped update, got %d", watermark)
+	}
+}
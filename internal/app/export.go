@@ -0,0 +1,302 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/models"
+	"github.com/kiasaty/spendings-tracker/pkg/ledger"
+)
+
+const exportUsage = "Usage: /export [today|week|month|last_month|year|all] [csv|json|hledger|beancount]"
+
+// exportFileExtensions are the file extensions of the export formats
+var exportFileExtensions = map[string]string{
+	"csv":       "csv",
+	"json":      "json",
+	"hledger":   "journal",
+	"beancount": "beancount",
+}
+
+// exportDateLayout is how dates are written to CSV exports, which
+// spreadsheets read as a date and time
+const exportDateLayout = "2006-01-02 15:04:05"
+
+var exportColumns = []string{"id", "date", "chat_id", "sender_id", "sender", "cost", "currency", "tags", "description"}
+
+// exportedSpending is a spending as it appears in JSON exports
+type exportedSpending struct {
+	ID          uint      `json:"id"`
+	Date        time.Time `json:"date"`
+	ChatID      int64     `json:"chat_id"`
+	SenderID    int64     `json:"sender_id"`
+	Sender      string    `json:"sender"`
+	Cost        float64   `json:"cost"`
+	Currency    string    `json:"currency"`
+	Tags        []string  `json:"tags"`
+	Description string    `json:"description"`
+}
+
+// handleExportCommand sends the chat's spendings of a period as a CSV, JSON,
+// hledger or Beancount file. The period and format can be given in any order.
+func (app *App) handleExportCommand(ctx context.Context, message *tgbotapi.Message) {
+	period, format := "", "csv"
+	for _, arg := range strings.Fields(message.CommandArguments()) {
+		switch {
+		case exportFileExtensions[arg] != "":
+			format = arg
+		case period == "":
+			period = arg
+		default:
+			app.Bot.SendMessage(ctx, message.Chat.ID, exportUsage)
+			return
+		}
+	}
+
+	page, ok := listPage(message.Chat.ID, period, time.Now())
+	if !ok {
+		app.Bot.SendMessage(ctx, message.Chat.ID, exportUsage)
+		return
+	}
+	page.filter.Limit = 0
+
+	spendings, _, err := app.FindSpendings(ctx, page.filter)
+	if err != nil {
+		fmt.Printf("Error exporting spendings: %v\n", err)
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to export spendings")
+		return
+	}
+	if len(spendings) == 0 {
+		app.Bot.SendMessage(ctx, message.Chat.ID, page.empty)
+		return
+	}
+
+	var data bytes.Buffer
+	if err := app.writeSpendings(&data, format, spendings); err != nil {
+		fmt.Printf("Error exporting spendings: %v\n", err)
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to export spendings")
+		return
+	}
+
+	fileName := fmt.Sprintf("spendings-%s.%s", page.callbackArgument, exportFileExtensions[format])
+	if err := app.Bot.SendDocument(ctx, message.Chat.ID, fileName, data.Bytes(), page.title); err != nil {
+		fmt.Printf("Error sending export: %v\n", err)
+	}
+}
+
+// Export runs the export subcommand, which writes the spendings of a period
+// like /export does, of all chats unless one is given. Spendings don't store
+// a currency, so all of them are exported in the one set by CURRENCY.
+func (app *App) Export(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(out)
+	chatID := flags.Int64("chat", 0, "only export the spendings of this chat")
+	period := flags.String("period", "all", "today, week, month, last_month, year or all")
+	format := flags.String("format", "csv", "csv, json, hledger or beancount")
+	output := flags.String("output", "", "file to write to instead of stdout")
+	accountsFile := flags.String("accounts", "", "file mapping tags, chats and payers to accounts for hledger and beancount, instead of LEDGER_ACCOUNTS_FILE")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+	if exportFileExtensions[*format] == "" {
+		return fmt.Errorf("unknown export format %q, expected csv, json, hledger or beancount", *format)
+	}
+
+	if *accountsFile != "" {
+		accounts, err := loadLedgerAccounts(*accountsFile)
+		if err != nil {
+			return err
+		}
+		exportApp := *app
+		exportApp.LedgerAccounts = accounts
+		app = &exportApp
+	}
+
+	page, ok := listPage(*chatID, *period, time.Now())
+	if !ok {
+		return fmt.Errorf("unknown period %q, expected today, week, month, last_month, year or all", *period)
+	}
+	page.filter.Limit = 0
+
+	spendings, _, err := app.FindSpendings(ctx, page.filter)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return app.writeSpendings(out, *format, spendings)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	if err := app.writeSpendings(file, *format, spendings); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	fmt.Fprintf(out, "Exported %d spendings to %s\n", len(spendings), *output)
+	return nil
+}
+
+// writeSpendings writes spendings oldest first in the given format, csv,
+// json, hledger or beancount.
+func (app *App) writeSpendings(w io.Writer, format string, spendings []models.Spending) error {
+	spendings = slices.Clone(spendings)
+	sort.SliceStable(spendings, func(i, j int) bool {
+		if !spendings[i].SpentAt.Equal(spendings[j].SpentAt) {
+			return spendings[i].SpentAt.Before(spendings[j].SpentAt)
+		}
+		return spendings[i].ID < spendings[j].ID
+	})
+
+	switch format {
+	case "csv":
+		return app.writeSpendingsCSV(w, spendings)
+	case "json":
+		return app.writeSpendingsJSON(w, spendings)
+	case "hledger":
+		return ledger.WriteHledger(w, app.ledgerTransactions(spendings))
+	case "beancount":
+		if app.Currency == "" {
+			return errors.New("beancount exports need the currency of spendings, set CURRENCY")
+		}
+		return ledger.WriteBeancount(w, app.ledgerTransactions(spendings))
+	default:
+		return fmt.Errorf("unknown export format %q, expected csv, json, hledger or beancount", format)
+	}
+}
+
+func (app *App) writeSpendingsCSV(w io.Writer, spendings []models.Spending) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	for _, spending := range spendings {
+		err := writer.Write([]string{
+			strconv.FormatUint(uint64(spending.ID), 10),
+			spending.SpentAt.Local().Format(exportDateLayout),
+			strconv.FormatInt(spending.ChatId, 10),
+			strconv.FormatInt(spending.SenderId, 10),
+			csvText(spending.SenderName),
+			strconv.FormatFloat(spending.Cost, 'f', 2, 64),
+			app.Currency,
+			csvText(strings.Join(spendingTagNames(spending), ",")),
+			csvText(spending.Description),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// csvText returns text written by users for a CSV cell. Spreadsheets run
+// cells starting with =, +, -, @, a tab or a carriage return as formulas, so
+// those are prefixed with a quote to be shown as text.
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func (app *App) writeSpendingsJSON(w io.Writer, spendings []models.Spending) error {
+	exported := make([]exportedSpending, 0, len(spendings))
+	for _, spending := range spendings {
+		exported = append(exported, exportedSpending{
+			ID:          spending.ID,
+			Date:        spending.SpentAt.Local(),
+			ChatID:      spending.ChatId,
+			SenderID:    spending.SenderId,
+			Sender:      spending.SenderName,
+			Cost:        spending.Cost,
+			Currency:    app.Currency,
+			Tags:        spendingTagNames(spending),
+			Description: spending.Description,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(exported); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	return nil
+}
+
+// ledgerTransactions turns spendings into transactions paid from the account
+// of their chat or payer for the account of their tags.
+func (app *App) ledgerTransactions(spendings []models.Spending) []ledger.Transaction {
+	transactions := make([]ledger.Transaction, 0, len(spendings))
+	for _, spending := range spendings {
+		tags := spendingTagNames(spending)
+		transactions = append(transactions, ledger.Transaction{
+			Date:        spending.SpentAt.Local(),
+			Payee:       spending.SenderName,
+			Description: spending.Description,
+			Tags:        tags,
+			Metadata: []ledger.Metadata{
+				{Key: "spending_id", Value: strconv.FormatUint(uint64(spending.ID), 10)},
+				{Key: "chat_id", Value: strconv.FormatInt(spending.ChatId, 10)},
+			},
+			Amount:         spending.Cost,
+			Currency:       app.Currency,
+			ExpenseAccount: app.LedgerAccounts.ExpenseAccount(tags),
+			AssetAccount:   app.LedgerAccounts.AssetAccount(spending.ChatId, spending.SenderId),
+		})
+	}
+	return transactions
+}
+
+// loadLedgerAccounts reads the mapping of tags, chats and payers to accounts
+// from a file.
+func loadLedgerAccounts(path string) (*ledger.Accounts, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open accounts file: %w", err)
+	}
+	defer file.Close()
+
+	accounts, err := ledger.ParseAccounts(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid accounts file %s: %w", path, err)
+	}
+	return accounts, nil
+}
+
+// spendingTagNames returns the sorted names of a spending's tags
+func spendingTagNames(spending models.Spending) []string {
+	names := make([]string, 0, len(spending.Tags))
+	for _, tag := range spending.Tags {
+		names = append(names, tag.Name)
+	}
+	sort.Strings(names)
+	return names
+}
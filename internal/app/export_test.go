@@ -0,0 +1,177 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+)
+
+func newExportTestApp(t *testing.T) (*App, *testutils.MockTelegramBot) {
+	t.Helper()
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot, Currency: "EUR"}
+
+	sender := &tgbotapi.User{ID: 7, FirstName: "Sara"}
+	for _, update := range []*tgbotapi.Update{
+		testutils.NewTestUpdate(1, 123456789, "Lunch, with \"friends\" 25.50 #food #work 2024-05-11"),
+		testutils.NewTestUpdate(2, 123456789, "Taxi 12 2024-05-10"),
+		testutils.NewTestUpdate(3, 987654321, "Books 40 2024-05-12"),
+	} {
+		update.Message.From = sender
+		app.handleUpdate(ctx, update)
+	}
+
+	return app, mockBot
+}
+
+func exportDate(day int) string {
+	return time.Date(2024, 5, day, 0, 0, 0, 0, time.UTC).Local().Format(exportDateLayout)
+}
+
+func TestExportCommand(t *testing.T) {
+	ctx := context.Background()
+	app, mockBot := newExportTestApp(t)
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(4, 123456789, 7, "/export all"))
+
+	documents := mockBot.Documents()
+	if len(documents) != 1 {
+		t.Fatalf("Expected a document to be sent, got %d", len(documents))
+	}
+	if documents[0].FileName != "spendings-all.csv" || documents[0].Caption != "Spendings for all time" {
+		t.Errorf("Unexpected document %q with caption %q", documents[0].FileName, documents[0].Caption)
+	}
+
+	expected := "id,date,chat_id,sender_id,sender,cost,currency,tags,description\n" +
+		"2," + exportDate(10) + ",123456789,7,Sara,12.00,EUR,,Taxi 12 2024-05-10\n" +
+		"1," + exportDate(11) + ",123456789,7,Sara,25.50,EUR,\"food,work\",\"Lunch, with \"\"friends\"\" 25.50 #food #work 2024-05-11\"\n"
+	if string(documents[0].Data) != expected {
+		t.Errorf("Expected CSV:\n%s\nGot:\n%s", expected, documents[0].Data)
+	}
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(5, 123456789, 7, "/export json year"))
+	mockBot.VerifyMessage(t, "No spendings for current year.")
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(6, 123456789, 7, "/export all xml"))
+	mockBot.VerifyMessage(t, exportUsage)
+}
+
+func TestCSVText(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"Lunch 25", "Lunch 25"},
+		{"", ""},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1 taxi", "'+1 taxi"},
+		{"-5 refund", "'-5 refund"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tTab", "'\tTab"},
+	}
+
+	for _, tt := range tests {
+		if text := csvText(tt.text); text != tt.expected {
+			t.Errorf("Expected %q for %q, got %q", tt.expected, tt.text, text)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	app, _ := newExportTestApp(t)
+
+	var out bytes.Buffer
+	if err := app.Export(ctx, []string{"-format", "json"}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var exported []exportedSpending
+	if err := json.Unmarshal(out.Bytes(), &exported); err != nil {
+		t.Fatalf("Failed to parse the export: %v", err)
+	}
+	if len(exported) != 3 {
+		t.Fatalf("Expected the spendings of all chats, got %d", len(exported))
+	}
+	lunch := exported[1]
+	if lunch.ChatID != 123456789 || lunch.Sender != "Sara" || lunch.Cost != 25.5 || lunch.Currency != "EUR" ||
+		strings.Join(lunch.Tags, ",") != "food,work" || !lunch.Date.Equal(time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected exported spending %+v", lunch)
+	}
+	if exported[2].ChatID != 987654321 || exported[2].Tags == nil {
+		t.Errorf("Expected the last spending with an empty list of tags, got %+v", exported[2])
+	}
+
+	output := filepath.Join(t.TempDir(), "spendings.csv")
+	out.Reset()
+	if err := app.Export(ctx, []string{"-chat", "987654321", "-output", output}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out.String() != "Exported 1 spendings to "+output+"\n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read the export: %v", err)
+	}
+	if !strings.HasSuffix(string(data), ",987654321,7,Sara,40.00,EUR,,Books 40 2024-05-12\n") {
+		t.Errorf("Unexpected CSV:\n%s", data)
+	}
+
+	if err := app.Export(ctx, []string{"-period", "fortnight"}, &out); err == nil {
+		t.Error("Expected an error for an unknown period")
+	}
+	if err := app.Export(ctx, []string{"-format", "xml"}, &out); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestExportLedger(t *testing.T) {
+	ctx := context.Background()
+	app, mockBot := newExportTestApp(t)
+
+	accountsFile := filepath.Join(t.TempDir(), "accounts.txt")
+	accounts := "tag.food = Expenses:Food:Eating Out\nchat.123456789 = Assets:Bank:Shared\n"
+	if err := os.WriteFile(accountsFile, []byte(accounts), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := app.Export(ctx, []string{"-format", "hledger", "-chat", "123456789", "-accounts", accountsFile}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"Sara | Lunch, with \"friends\" 25.50 #food #work 2024-05-11\n    ; spending_id:1, chat_id:123456789, food:, work:\n",
+		"    Expenses:Food:Eating Out  25.50 EUR\n    Assets:Bank:Shared\n",
+		"    Expenses:Other  12.00 EUR\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected the journal to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(4, 987654321, 7, "/export beancount all"))
+	documents := mockBot.Documents()
+	if len(documents) != 1 || documents[0].FileName != "spendings-all.beancount" {
+		t.Fatalf("Expected a Beancount document to be sent, got %+v", documents)
+	}
+	if !strings.Contains(string(documents[0].Data), "  Expenses:Other  40.00 EUR\n  Assets:Cash\n") {
+		t.Errorf("Unexpected Beancount file:\n%s", documents[0].Data)
+	}
+
+	app.Currency = ""
+	if err := app.Export(ctx, []string{"-format", "beancount"}, &out); err == nil || !strings.Contains(err.Error(), "CURRENCY") {
+		t.Errorf("Expected Beancount exports without a currency to fail, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+)
+
+func newTestUpdateWithId(updateID int, messageID int, text string) *tgbotapi.Update {
+	update := testutils.NewTestUpdate(messageID, 123456789, text)
+	update.UpdateID = updateID
+	return update
+}
+
+func newTestDispatcher(ctx context.Context, app *App, watermark int) *updateDispatcher {
+	return newUpdateDispatcher(2, watermark, func(update *tgbotapi.Update) error {
+		return app.handleUpdate(ctx, update)
+	})
+}
+
+func TestFetchUpdatesOnce(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot}
+
+	dispatcher := newTestDispatcher(ctx, app, 0)
+	defer dispatcher.Stop()
+
+	mockBot.QueueUpdates(
+		newTestUpdateWithId(10, 1, "Lunch 15.50"),
+		newTestUpdateWithId(11, 2, "Dinner 25.75"),
+	)
+
+	if err := app.fetchUpdatesOnce(ctx, dispatcher); err != nil {
+		t.Fatalf("Failed to fetch updates: %v", err)
+	}
+	dispatcher.Wait()
+
+	if len(mockDB.GetSpendings()) != 2 {
+		t.Errorf("Expected 2 spendings, got %d", len(mockDB.GetSpendings()))
+	}
+
+	// Handled updates are acknowledged by the next poll
+	mockBot.QueueUpdates(newTestUpdateWithId(12, 3, "Coffee 3"))
+
+	if err := app.fetchUpdatesOnce(ctx, dispatcher); err != nil {
+		t.Fatalf("Failed to fetch updates: %v", err)
+	}
+	dispatcher.Wait()
+
+	if len(mockDB.GetSpendings()) != 3 {
+		t.Errorf("Expected 3 spendings, got %d", len(mockDB.GetSpendings()))
+	}
+
+	if err := app.fetchUpdatesOnce(ctx, dispatcher); err != nil {
+		t.Fatalf("Failed to fetch updates: %v", err)
+	}
+
+	if updates, _ := mockBot.GetUpdates(ctx, 13); len(updates) != 0 {
+		t.Errorf("Expected all updates to be acknowledged, got %d pending", len(updates))
+	}
+	if lastUpdateID, _ := mockDB.GetLastUpdateId(ctx); lastUpdateID != 12 {
+		t.Errorf("Expected last update ID 12, got %d", lastUpdateID)
+	}
+}
+
+func TestFetchUpdatesOnceResumesFromStoredOffset(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot}
+
+	// Update 10 was handled before a restart, but never acknowledged
+	mockDB.SaveLastUpdateId(ctx, 10)
+	dispatcher := newTestDispatcher(ctx, app, 10)
+	defer dispatcher.Stop()
+
+	mockBot.QueueUpdates(
+		newTestUpdateWithId(10, 1, "Lunch 15.50"),
+		newTestUpdateWithId(11, 2, "Dinner 25.75"),
+	)
+
+	if err := app.fetchUpdatesOnce(ctx, dispatcher); err != nil {
+		t.Fatalf("Failed to fetch updates: %v", err)
+	}
+	dispatcher.Wait()
+
+	if spending, _ := mockDB.FindSpendingByMessageId(ctx, 123456789, 1); spending != nil {
+		t.Errorf("Expected already handled update not to be handled again")
+	}
+	if spending, _ := mockDB.FindSpendingByMessageId(ctx, 123456789, 2); spending == nil {
+		t.Errorf("Expected pending update to be handled")
+	}
+}
+
+func TestFetchUpdatesStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot}
+
+	mockBot.QueueUpdates(newTestUpdateWithId(10, 1, "Lunch 15.50"))
+
+	app.FetchUpdates(ctx)
+
+	if len(mockDB.GetSpendings()) != 0 {
+		t.Errorf("Expected no updates to be handled after cancellation")
+	}
+	if lastUpdateID, _ := mockDB.GetLastUpdateId(context.Background()); lastUpdateID != 0 {
+		t.Errorf("Expected unhandled update not to be acknowledged, got last update ID %d", lastUpdateID)
+	}
+}
+
+func TestFetchUpdatesHandlesFailedUpdateAfterRestart(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot}
+
+	// The database fails, and the restart comes before the retry
+	mockDB.SetErrorOnCreate(true)
+	failed := make(chan struct{}, 1)
+	dispatcher := newUpdateDispatcher(2, 0, func(update *tgbotapi.Update) error {
+		err := app.handleUpdate(ctx, update)
+		if err != nil {
+			failed <- struct{}{}
+		}
+		return err
+	})
+	dispatcher.retryDelay = time.Hour
+
+	mockBot.QueueUpdates(
+		newTestUpdateWithId(10, 1, "Lunch 15.50"),
+		newTestUpdateWithId(11, 2, "Dinner 25.75"),
+	)
+	if err := app.fetchUpdatesOnce(ctx, dispatcher); err != nil {
+		t.Fatalf("Failed to fetch updates: %v", err)
+	}
+	<-failed
+	dispatcher.Stop()
+
+	if err := app.saveWatermark(ctx, dispatcher); err != nil {
+		t.Fatalf("Failed to save watermark: %v", err)
+	}
+	if lastUpdateID, _ := mockDB.GetLastUpdateId(ctx); lastUpdateID != 0 {
+		t.Errorf("Expected failed update not to be acknowledged, got last update ID %d", lastUpdateID)
+	}
+	if len(mockDB.GetSpendings()) != 0 {
+		t.Fatalf("Expected no spendings, got %d", len(mockDB.GetSpendings()))
+	}
+
+	// After the restart the failed update is fetched and handled again
+	mockDB.SetErrorOnCreate(false)
+	lastUpdateID, _ := mockDB.GetLastUpdateId(ctx)
+	dispatcher = newTestDispatcher(ctx, app, lastUpdateID)
+	defer dispatcher.Stop()
+
+	if err := app.fetchUpdatesOnce(ctx, dispatcher); err != nil {
+		t.Fatalf("Failed to fetch updates: %v", err)
+	}
+	dispatcher.Wait()
+
+	if spending, _ := mockDB.FindSpendingByMessageId(ctx, 123456789, 1); spending == nil || spending.Cost != 15.50 {
+		t.Errorf("Expected failed update to be handled after the restart, got %+v", spending)
+	}
+	if len(mockDB.GetSpendings()) != 2 {
+		t.Errorf("Expected 2 spendings, got %d", len(mockDB.GetSpendings()))
+	}
+	if err := app.saveWatermark(ctx, dispatcher); err != nil {
+		t.Fatalf("Failed to save watermark: %v", err)
+	}
+	if lastUpdateID, _ := mockDB.GetLastUpdateId(ctx); lastUpdateID != 11 {
+		t.Errorf("Expected last update ID 11, got %d", lastUpdateID)
+	}
+}
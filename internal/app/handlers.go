@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -11,101 +12,348 @@ import (
 	"github.com/kiasaty/spendings-tracker/pkg/extractors"
 )
 
-func (app *App) FetchUpdates() {
+// FetchUpdates polls Telegram for updates and handles them until the context
+// is cancelled. Updates already being handled when that happens are finished
+// first.
+func (app *App) FetchUpdates(ctx context.Context) {
 	fmt.Println("Starting to fetch updates...")
-	updates := app.Bot.GetUpdates()
 
-	for update := range updates {
-		fmt.Printf("Received update ID: %d, Message: %s\n", update.UpdateID, update.Message.Text)
-		app.handleUpdate(&update)
+	lastUpdateID, err := app.DB.GetLastUpdateId(ctx)
+	if err != nil {
+		fmt.Printf("Error getting last update id: %v\n", err)
+		return
+	}
+
+	// Updates in progress must not be interrupted by the cancellation
+	handleCtx := context.WithoutCancel(ctx)
+	dispatcher := newUpdateDispatcher(app.Workers, lastUpdateID, func(update *tgbotapi.Update) error {
+		return app.handleUpdate(handleCtx, update)
+	})
+
+	for ctx.Err() == nil {
+		if err := app.fetchUpdatesOnce(ctx, dispatcher); err != nil && ctx.Err() == nil {
+			fmt.Printf("Error fetching updates: %v\n", err)
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(3 * time.Second):
+			}
+		}
+	}
+
+	fmt.Println("Waiting for updates in progress...")
+	dispatcher.Stop()
+
+	if err := app.saveWatermark(handleCtx, dispatcher); err != nil {
+		fmt.Printf("Error saving last update id: %v\n", err)
 	}
+
+	fmt.Println("Stopped fetching updates.")
 }
 
-func (app *App) handleUpdate(update *tgbotapi.Update) {
-	if update.Message == nil {
-		return
+// fetchUpdatesOnce polls Telegram once and dispatches the returned updates.
+//
+// Telegram is only asked for updates after the dispatcher's watermark, which
+// also acknowledges everything up to it. The watermark is stored as well, so
+// after a crash fetching resumes with the first update that wasn't fully
+// handled. Updates may be handled twice in that case, which is safe since
+// spendings are looked up by chat and message ID.
+func (app *App) fetchUpdatesOnce(ctx context.Context, dispatcher *updateDispatcher) error {
+	watermark := dispatcher.Watermark()
+
+	offset := 0
+	if watermark > 0 {
+		offset = watermark + 1
+	}
+
+	updates, err := app.Bot.GetUpdates(ctx, offset)
+	if err != nil {
+		return err
+	}
+
+	dispatched := 0
+	for _, update := range updates {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if !dispatcher.Dispatch(update) {
+			continue
+		}
+		dispatched++
+
+		if update.Message != nil {
+			fmt.Printf("Received update ID: %d, Message: %s\n", update.UpdateID, update.Message.Text)
+		} else {
+			fmt.Printf("Received update ID: %d\n", update.UpdateID)
+		}
+	}
+
+	// Telegram returns unacknowledged updates right away, so when only
+	// updates that are still in progress came back, wait a moment instead
+	// of polling in a tight loop
+	if dispatched == 0 && dispatcher.Pending() > 0 {
+		select {
+		case <-ctx.Done():
+		case <-dispatcher.Completed():
+		case <-time.After(time.Second):
+		}
+	}
+
+	return app.saveWatermark(context.WithoutCancel(ctx), dispatcher)
+}
+
+// saveWatermark stores the dispatcher's watermark as the last handled update
+// if it moved since it was last stored.
+func (app *App) saveWatermark(ctx context.Context, dispatcher *updateDispatcher) error {
+	lastUpdateID, err := app.DB.GetLastUpdateId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get last update id: %w", err)
+	}
+
+	if watermark := dispatcher.Watermark(); watermark > lastUpdateID {
+		if err := app.DB.SaveLastUpdateId(ctx, watermark); err != nil {
+			return fmt.Errorf("failed to save last update id: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// handleUpdate handles an update from Telegram. It returns an error when the
+// update couldn't be stored and should be handled again.
+func (app *App) handleUpdate(ctx context.Context, update *tgbotapi.Update) error {
+	if !app.isUpdateAllowed(ctx, update) {
+		app.rejectUpdate(ctx, update)
+		return nil
+	}
+
+	// Buttons pressed below messages of the bot
+	if update.CallbackQuery != nil {
+		app.handleCallbackQuery(ctx, update.CallbackQuery)
+		return nil
+	}
+
+	// Edits of earlier messages update the spending they created
+	message := update.Message
+	isEdit := false
+	if message == nil {
+		message = update.EditedMessage
+		isEdit = true
+	}
+	if message == nil {
+		return nil
+	}
+
+	// Remember group members so spendings can be split between them
+	member, err := app.rememberChatMember(ctx, message)
+	if err != nil {
+		return fmt.Errorf("failed to remember chat member: %w", err)
 	}
 
 	// Handle commands
-	if update.Message.IsCommand() {
-		switch update.Message.Command() {
+	if message.IsCommand() {
+		if isEdit {
+			return nil
+		}
+
+		switch message.Command() {
 		case "report":
-			app.handleReportCommand(update.Message, false)
-			return
+			app.handleReportCommand(ctx, message, false)
+			return nil
 		case "report_last_month":
-			app.handleReportCommand(update.Message, true)
-			return
+			app.handleReportCommand(ctx, message, true)
+			return nil
+		case "allow":
+			app.handleAllowCommand(ctx, message, true)
+			return nil
+		case "deny":
+			app.handleAllowCommand(ctx, message, false)
+			return nil
+		case "balances":
+			app.handleBalancesCommand(ctx, message)
+			return nil
+		case "settle":
+			if err := app.handleSettleCommand(ctx, message, member); err != nil {
+				return fmt.Errorf("failed to settle: %w", err)
+			}
+			return nil
+		case "budget":
+			app.handleBudgetCommand(ctx, message)
+			return nil
+		case "budgets":
+			app.handleBudgetsCommand(ctx, message)
+			return nil
+		case "recurring":
+			app.handleRecurringCommand(ctx, message)
+			return nil
+		case "list":
+			app.handleListCommand(ctx, message)
+			return nil
+		case "search":
+			app.handleSearchCommand(ctx, message)
+			return nil
+		case "export":
+			app.handleExportCommand(ctx, message)
+			return nil
+		case "chart":
+			app.handleChartCommand(ctx, message)
+			return nil
+		case "api_token":
+			app.handleAPITokenCommand(ctx, message)
+			return nil
+		case "dashboard":
+			app.handleDashboardCommand(ctx, message)
+			return nil
+		}
+	}
+
+	spending, result, err := app.storeSpendingMessage(ctx, message, member)
+	if err != nil {
+		return fmt.Errorf("failed to store spending: %w", err)
+	}
+
+	// Warn about budgets crossed by the new spending
+	if result == spendingCreated {
+		err = app.checkBudgets(ctx, spending, spending.Tags)
+		if err != nil {
+			fmt.Printf("Error checking budgets: %v\n", err)
 		}
 	}
+	return nil
+}
 
+// spendingResult is what storing the spending of a message did.
+type spendingResult int
+
+const (
+	// spendingSkipped means the message has no price, so it isn't a spending
+	spendingSkipped spendingResult = iota
+	spendingCreated
+	spendingUpdated
+	// spendingUnchanged means the spending already matched the message
+	spendingUnchanged
+)
+
+// storeSpendingMessage extracts the spending of a message and creates it, or
+// updates the one created from the same message before. The spending's date
+// is the one in the text, or else when the message was sent.
+func (app *App) storeSpendingMessage(ctx context.Context, message *tgbotapi.Message, member *models.ChatMember) (*models.Spending, spendingResult, error) {
 	// Extract price, skip if not found
-	price, err := extractors.ExtractPrice(update.Message.Text)
+	price, err := extractors.ExtractPrice(message.Text)
 	if err != nil {
-		return
+		return nil, spendingSkipped, nil
 	}
 
-	// Extract date or use current time
-	date, err := extractors.ExtractDate(update.Message.Text)
+	// Extract date or use the time the message was sent
+	date, err := extractors.ExtractDate(message.Text)
 	if err != nil {
-		date = time.Now()
+		date = messageTime(message)
 	}
 
 	// Extract tags
-	tags := extractors.ExtractHashtags(update.Message.Text)
+	tags := extractors.ExtractHashtags(message.Text)
+
+	// Store the spending with its tags and shares atomically, so a failure
+	// halfway doesn't leave orphan tags or a spending with stale tags
+	var spending *models.Spending
 	var tagModels []models.Tag
-	for _, tagName := range tags {
-		tag, err := app.FindTagByName(tagName)
+	var result spendingResult
+
+	err = app.withTransaction(ctx, func(tx *App) error {
+		tagModels, err = tx.findOrCreateTags(ctx, tags)
 		if err != nil {
-			continue
-		}
-		if tag == nil {
-			tag, err = app.StoreTag(&models.Tag{
-				Name: tagName,
-			})
-			if err != nil {
-				continue
-			}
+			return err
 		}
-		tagModels = append(tagModels, *tag)
-	}
 
-	// Check if spending already exists
-	spending, err := app.FindSpendingByMessageId(update.Message.MessageID)
-	if err != nil {
-		return
-	}
+		// Check if spending already exists
+		spending, err = tx.FindSpendingByMessageId(ctx, message.Chat.ID, message.MessageID)
+		if err != nil {
+			return err
+		}
 
-	if spending == nil {
-		// Create new spending
-		spending, err = app.StoreSpending(&models.Spending{
-			ChatId:      update.Message.Chat.ID,
-			MessageId:   update.Message.MessageID,
-			Cost:        price,
-			Description: update.Message.Text,
-			SpentAt:     date,
-		})
+		if spending == nil {
+			// Create new spending
+			result = spendingCreated
+			spending, err = tx.StoreSpending(ctx, &models.Spending{
+				ChatId:      message.Chat.ID,
+				MessageId:   message.MessageID,
+				SenderId:    senderId(message.From),
+				SenderName:  senderName(message.From),
+				Cost:        price,
+				Description: message.Text,
+				SpentAt:     date,
+			})
+		} else if spending.Cost == price && spending.Description == message.Text && spending.SpentAt.Equal(date) {
+			result = spendingUnchanged
+			return nil
+		} else {
+			// Update existing spending
+			result = spendingUpdated
+			spending.Cost = price
+			spending.Description = message.Text
+			spending.SpentAt = date
+			spending, err = tx.UpdateSpending(ctx, spending)
+		}
 		if err != nil {
-			return
+			return err
 		}
-	} else {
-		// Update existing spending
-		spending.Cost = price
-		spending.Description = update.Message.Text
-		spending.SpentAt = date
-		spending, err = app.UpdateSpending(spending)
+
+		// Sync tags
+		err = tx.SyncSpendingTags(ctx, spending, &tagModels)
 		if err != nil {
-			return
+			return err
+		}
+
+		// Split group spendings between the members paid for
+		if isGroupChat(message.Chat) && member != nil {
+			return tx.splitSpending(ctx, spending, extractors.ExtractMentions(message.Text))
 		}
-	}
 
-	// Sync tags
-	err = app.SyncSpendingTags(spending, &tagModels)
+		return nil
+	})
 	if err != nil {
-		fmt.Printf("Error syncing tags: %v\n", err)
+		return nil, spendingSkipped, err
+	}
+
+	spending.Tags = tagModels
+	return spending, result, nil
+}
+
+// messageTime returns when a message was sent, or the current time for
+// messages without a date.
+func messageTime(message *tgbotapi.Message) time.Time {
+	if message.Date == 0 {
+		return time.Now()
 	}
+	return message.Time()
 }
 
-func (app *App) handleReportCommand(message *tgbotapi.Message, isLastMonth bool) {
+// senderId returns the Telegram ID of the message sender, or 0 when Telegram
+// did not include one (e.g. channel posts).
+func senderId(user *tgbotapi.User) int64 {
+	if user == nil {
+		return 0
+	}
+	return user.ID
+}
+
+// senderName returns a human readable name for the message sender, preferring
+// the full name over the username.
+func senderName(user *tgbotapi.User) string {
+	if user == nil {
+		return ""
+	}
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		name = user.UserName
+	}
+	return name
+}
+
+func (app *App) handleReportCommand(ctx context.Context, message *tgbotapi.Message, isLastMonth bool) {
+	byPerson := strings.TrimSpace(message.CommandArguments()) == "by_person"
+
 	var startDate, endDate time.Time
 	now := time.Now()
 
@@ -119,61 +367,115 @@ func (app *App) handleReportCommand(message *tgbotapi.Message, isLastMonth bool)
 		endDate = now
 	}
 
-	// Get spendings for the period
-	spendings, err := app.DB.GetSpendingsByDateRange(startDate, endDate)
+	// Get the chat's spendings for the period
+	spendings, err := app.DB.GetChatSpendingsByDateRange(ctx, message.Chat.ID, startDate, endDate)
 	if err != nil {
-		app.Bot.SendMessage(message.Chat.ID, "Failed to generate report")
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to generate report")
 		return
 	}
 
-	// Calculate totals by tag
-	tagTotals := make(map[string]float64)
-	var total float64
+	summary := newSpendingReport(spendings)
+
+	// Format the report
+	var report strings.Builder
+	period := "current month"
+	if isLastMonth {
+		period = "last month"
+	}
+	report.WriteString(fmt.Sprintf("Spending report for %s:\n\n", period))
+
+	// Add tag totals, "other" last for untagged spendings
+	tags := summary.tagNames()
+	for _, tag := range tags {
+		report.WriteString(fmt.Sprintf("%s: %.2f\n", tag, summary.Tags[tag]))
+	}
+
+	// Add totals by person
+	if byPerson && len(summary.People) > 0 {
+		if len(tags) > 0 {
+			report.WriteString("\n")
+		}
+		report.WriteString("By person:\n")
+
+		for _, person := range summary.personNames() {
+			report.WriteString(fmt.Sprintf("%s: %.2f\n", person, summary.People[person]))
+		}
+	}
+
+	// Add total
+	if len(tags) > 0 {
+		report.WriteString("\n")
+	}
+	report.WriteString(fmt.Sprintf("Total: %.2f", summary.Total))
+
+	// Send the report
+	app.Bot.SendMessage(ctx, message.Chat.ID, report.String())
+}
+
+// spendingReport sums up spendings by tag and by person.
+type spendingReport struct {
+	Total float64
+	// Tags are the totals by tag name. Spendings count for each of their
+	// tags, and for "other" when they have none
+	Tags   map[string]float64
+	People map[string]float64
+}
+
+func newSpendingReport(spendings []models.Spending) spendingReport {
+	report := spendingReport{
+		Tags:   make(map[string]float64),
+		People: make(map[string]float64),
+	}
 
 	for _, spending := range spendings {
-		total += spending.Cost
+		report.Total += spending.Cost
+		report.People[reportPersonName(spending)] += spending.Cost
 		if len(spending.Tags) == 0 {
-			tagTotals["other"] += spending.Cost
+			report.Tags["other"] += spending.Cost
 			continue
 		}
 		for _, tag := range spending.Tags {
-			tagTotals[tag.Name] += spending.Cost
+			report.Tags[tag.Name] += spending.Cost
 		}
 	}
 
-	// Format the report
-	var report strings.Builder
-	period := "current month"
-	if isLastMonth {
-		period = "last month"
-	}
-	report.WriteString(fmt.Sprintf("Spending report for %s:\n\n", period))
+	return report
+}
 
-	// Sort tags for consistent output
+// tagNames returns the names of the report's tags sorted for consistent
+// output, with "other" last.
+func (r spendingReport) tagNames() []string {
 	var tags []string
-	for tag := range tagTotals {
+	for tag := range r.Tags {
 		if tag != "other" {
 			tags = append(tags, tag)
 		}
 	}
 	sort.Strings(tags)
 
-	// Add tag totals
-	for _, tag := range tags {
-		report.WriteString(fmt.Sprintf("%s: %.2f\n", tag, tagTotals[tag]))
+	if r.Tags["other"] > 0 {
+		tags = append(tags, "other")
 	}
+	return tags
+}
 
-	// Add "other" category if there are untagged spendings
-	if tagTotals["other"] > 0 {
-		report.WriteString(fmt.Sprintf("other: %.2f\n", tagTotals["other"]))
+func (r spendingReport) personNames() []string {
+	var people []string
+	for person := range r.People {
+		people = append(people, person)
 	}
+	sort.Strings(people)
+	return people
+}
 
-	// Add total
-	if len(tags) > 0 || tagTotals["other"] > 0 {
-		report.WriteString("\n")
+// reportPersonName returns the name a spending is grouped under in the
+// per-person report.
+func reportPersonName(spending models.Spending) string {
+	if spending.SenderName != "" {
+		return spending.SenderName
 	}
-	report.WriteString(fmt.Sprintf("Total: %.2f", total))
-
-	// Send the report
-	app.Bot.SendMessage(message.Chat.ID, report.String())
+	if spending.SenderId != 0 {
+		return fmt.Sprintf("user %d", spending.SenderId)
+	}
+	return "unknown"
 }
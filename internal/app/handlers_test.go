@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -10,6 +11,8 @@ import (
 )
 
 func TestHandleUpdate(t *testing.T) {
+	ctx := context.Background()
+
 	tests := []struct {
 		name         string
 		update       *tgbotapi.Update
@@ -67,11 +70,11 @@ func TestHandleUpdate(t *testing.T) {
 				t.Fatalf("Failed to create app: %v", err)
 			}
 
-			app.handleUpdate(tt.update)
+			app.handleUpdate(ctx, tt.update)
 
 			if tt.expectError {
 				// Verify no spending was created
-				spending, _ := mockDB.FindSpendingByMessageId(tt.update.Message.MessageID)
+				spending, _ := mockDB.FindSpendingByMessageId(ctx, tt.update.Message.Chat.ID, tt.update.Message.MessageID)
 				if spending != nil {
 					t.Errorf("Expected no spending to be created for invalid message")
 				}
@@ -79,7 +82,7 @@ func TestHandleUpdate(t *testing.T) {
 			}
 
 			// Get the spending once
-			spending, _ := mockDB.FindSpendingByMessageId(tt.update.Message.MessageID)
+			spending, _ := mockDB.FindSpendingByMessageId(ctx, tt.update.Message.Chat.ID, tt.update.Message.MessageID)
 
 			// Verify spending cost and date
 			mockDB.VerifySpending(t, spending, tt.expectedCost, tt.expectedDate)
@@ -95,6 +98,8 @@ func TestHandleUpdate(t *testing.T) {
 }
 
 func TestHandleUpdateWithError(t *testing.T) {
+	ctx := context.Background()
+
 	// Test error handling when database operations fail
 	db := testutils.NewMockDatabaseClient()
 	bot := testutils.NewMockTelegramBot()
@@ -108,15 +113,90 @@ func TestHandleUpdateWithError(t *testing.T) {
 
 	// Try to create a spending
 	update := testutils.NewTestUpdate(1, 123456789, "Lunch 15.50")
-	app.handleUpdate(update)
+	app.handleUpdate(ctx, update)
 
 	// Verify no spending was created due to error
-	if spending, _ := db.FindSpendingByMessageId(1); spending != nil {
+	if spending, _ := db.FindSpendingByMessageId(ctx, 123456789, 1); spending != nil {
 		t.Errorf("Expected no spending to be created when database returns error")
 	}
 }
 
+func TestHandleUpdateRollsBackOnError(t *testing.T) {
+	ctx := context.Background()
+
+	db := testutils.NewMockDatabaseClient()
+	bot := testutils.NewMockTelegramBot()
+	app := &App{
+		DB:  db,
+		Bot: bot,
+	}
+
+	// Tags are created before the spending, so they must be rolled back
+	// when storing the spending fails
+	db.SetErrorOnCreate(true)
+	app.handleUpdate(ctx, testutils.NewTestUpdate(1, 123456789, "Lunch 15.50 #food #work"))
+
+	if len(db.GetTags()) != 0 {
+		t.Errorf("Expected no orphan tags, got %d tags", len(db.GetTags()))
+	}
+	if len(db.GetSpendings()) != 0 {
+		t.Errorf("Expected no spending to be created, got %d spendings", len(db.GetSpendings()))
+	}
+}
+
+func TestHandleEditedMessage(t *testing.T) {
+	ctx := context.Background()
+
+	db := testutils.NewMockDatabaseClient()
+	bot := testutils.NewMockTelegramBot()
+	app := &App{
+		DB:  db,
+		Bot: bot,
+	}
+
+	app.handleUpdate(ctx, testutils.NewTestUpdate(1, 123456789, "Lunch 15.50 #food"))
+
+	edit := testutils.NewTestUpdate(1, 123456789, "Lunch 17.50 #food #work")
+	edit.EditedMessage, edit.Message = edit.Message, nil
+	app.handleUpdate(ctx, edit)
+
+	spending, _ := db.FindSpendingByMessageId(ctx, 123456789, 1)
+	if spending == nil {
+		t.Fatalf("Expected spending to exist")
+	}
+	db.VerifySpending(t, spending, 17.50, spending.SpentAt)
+	db.VerifySpendingTags(t, spending, []string{"food", "work"})
+
+	if len(db.GetSpendings()) != 1 {
+		t.Errorf("Expected the edit to update the spending, got %d spendings", len(db.GetSpendings()))
+	}
+}
+
+func TestHandleSameMessageIdInTwoChats(t *testing.T) {
+	ctx := context.Background()
+
+	db := testutils.NewMockDatabaseClient()
+	app := &App{DB: db, Bot: testutils.NewMockTelegramBot()}
+
+	app.handleUpdate(ctx, testutils.NewTestUpdate(1, 100, "Lunch 15.50"))
+	app.handleUpdate(ctx, testutils.NewTestUpdate(1, 200, "Taxi 12"))
+
+	edit := testutils.NewTestUpdate(1, 200, "Taxi 14")
+	edit.EditedMessage, edit.Message = edit.Message, nil
+	app.handleUpdate(ctx, edit)
+
+	lunch, _ := db.FindSpendingByMessageId(ctx, 100, 1)
+	taxi, _ := db.FindSpendingByMessageId(ctx, 200, 1)
+	if lunch == nil || taxi == nil || len(db.GetSpendings()) != 2 {
+		t.Fatalf("Expected a spending in each chat, got %v", db.GetSpendings())
+	}
+	db.VerifySpending(t, lunch, 15.50, lunch.SpentAt)
+	db.VerifySpending(t, taxi, 14, taxi.SpentAt)
+}
+
 func TestHandleReportCommand(t *testing.T) {
+	ctx := context.Background()
+
 	now := time.Now()
 	currentMonthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
 	lastMonthStart := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, now.Location())
@@ -190,6 +270,57 @@ func TestHandleReportCommand(t *testing.T) {
 			},
 			expectedReport: "Spending report for current month:\n\nother: 33.00\n\nTotal: 33.00",
 		},
+		{
+			name:    "Current month report by person",
+			command: "/report by_person",
+			spendings: []*models.Spending{
+				{
+					MessageId:  7,
+					SenderId:   1,
+					SenderName: "Ali",
+					Cost:       40.00,
+					SpentAt:    currentMonthStart.AddDate(0, 0, 1),
+					Tags:       []models.Tag{{Name: "food"}},
+				},
+				{
+					MessageId:  8,
+					SenderId:   2,
+					SenderName: "Sara",
+					Cost:       12.50,
+					SpentAt:    currentMonthStart.AddDate(0, 0, 2),
+					Tags:       []models.Tag{{Name: "food"}},
+				},
+				{
+					MessageId:  9,
+					SenderId:   1,
+					SenderName: "Ali",
+					Cost:       7.50,
+					SpentAt:    currentMonthStart.AddDate(0, 0, 3),
+					Tags:       []models.Tag{},
+				},
+			},
+			expectedReport: "Spending report for current month:\n\nfood: 52.50\nother: 7.50\n\nBy person:\nAli: 47.50\nSara: 12.50\n\nTotal: 60.00",
+		},
+		{
+			name:    "Spendings of other chats are left out",
+			command: "/report",
+			spendings: []*models.Spending{
+				{
+					MessageId: 10,
+					Cost:      12.00,
+					SpentAt:   currentMonthStart.AddDate(0, 0, 1),
+					Tags:      []models.Tag{{Name: "food"}},
+				},
+				{
+					ChatId:    987654321,
+					MessageId: 10,
+					Cost:      99.00,
+					SpentAt:   currentMonthStart.AddDate(0, 0, 1),
+					Tags:      []models.Tag{{Name: "food"}, {Name: "rent"}},
+				},
+			},
+			expectedReport: "Spending report for current month:\n\nfood: 12.00\n\nTotal: 12.00",
+		},
 	}
 
 	for _, tt := range tests {
@@ -201,26 +332,23 @@ func TestHandleReportCommand(t *testing.T) {
 				t.Fatalf("Failed to create app: %v", err)
 			}
 
-			// Add test spendings to mock DB
+			// Add test spendings to mock DB, in the reporting chat unless
+			// another chat is given
 			for _, spending := range tt.spendings {
-				mockDB.CreateSpending(spending)
+				if spending.ChatId == 0 {
+					spending.ChatId = 123456789
+				}
+				mockDB.CreateSpending(ctx, spending)
 			}
 
 			// Create update with command
-			update := &tgbotapi.Update{
-				Message: &tgbotapi.Message{
-					Text: tt.command,
-					Chat: &tgbotapi.Chat{
-						ID: 123456789,
-					},
-				},
-			}
+			update := testutils.NewTestCommandUpdate(100, 123456789, 1, tt.command)
 
 			// Handle the command
-			if tt.command == "/report" {
-				app.handleReportCommand(update.Message, false)
+			if update.Message.Command() == "report" {
+				app.handleReportCommand(ctx, update.Message, false)
 			} else {
-				app.handleReportCommand(update.Message, true)
+				app.handleReportCommand(ctx, update.Message, true)
 			}
 
 			// Verify the report message
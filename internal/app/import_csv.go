@@ -0,0 +1,341 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+// csvImportOptions describes the layout of a CSV file of spendings. Columns
+// are found by their header; the description and tags columns are optional
+// and not read when their name is empty.
+type csvImportOptions struct {
+	ChatID            int64
+	DateColumn        string
+	AmountColumn      string
+	DescriptionColumn string
+	TagsColumn        string
+	// DateLayout is a time.Parse layout, dates are in the local time zone
+	DateLayout string
+	// DecimalSeparator is "." or ","; the other one is taken for a
+	// thousands separator
+	DecimalSeparator string
+	Delimiter        rune
+}
+
+// importedSpending is a spending read from a file along with the names of
+// its tags.
+type importedSpending struct {
+	Line     int
+	Spending models.Spending
+	Tags     []string
+}
+
+// ImportCSV runs the import-csv subcommand, which creates the spendings of a
+// CSV file in a chat. Rows imported before are skipped, so a file can be
+// imported again after adding rows to it.
+func (app *App) ImportCSV(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import-csv", flag.ContinueOnError)
+	flags.SetOutput(out)
+	chatID := flags.Int64("chat", 0, "chat to add the spendings to (required)")
+	dateColumn := flags.String("date", "date", "header of the date column")
+	amountColumn := flags.String("amount", "amount", "header of the amount column")
+	descriptionColumn := flags.String("description", "description", "header of the description column, empty for none")
+	tagsColumn := flags.String("tags", "tags", "header of the column of tags separated by commas, semicolons or spaces, empty for none")
+	dateLayout := flags.String("date-layout", "2006-01-02", "layout of dates, see https://pkg.go.dev/time#pkg-constants")
+	decimalSeparator := flags.String("decimal", ".", "decimal separator of amounts, . or ,")
+	delimiter := flags.String("delimiter", ",", "field delimiter")
+	dryRun := flags.Bool("dry-run", false, "only print the spendings that would be created")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("expected the CSV file to import")
+	}
+	if *chatID == 0 {
+		return errors.New("the -chat to add the spendings to is required")
+	}
+	if *decimalSeparator != "." && *decimalSeparator != "," {
+		return fmt.Errorf("invalid decimal separator %q, expected . or ,", *decimalSeparator)
+	}
+	if utf8.RuneCountInString(*delimiter) != 1 {
+		return fmt.Errorf("invalid delimiter %q, expected a single character", *delimiter)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer file.Close()
+
+	delimiterRune, _ := utf8.DecodeRuneInString(*delimiter)
+	spendings, err := parseCSVSpendings(file, csvImportOptions{
+		ChatID:            *chatID,
+		DateColumn:        *dateColumn,
+		AmountColumn:      *amountColumn,
+		DescriptionColumn: *descriptionColumn,
+		TagsColumn:        *tagsColumn,
+		DateLayout:        *dateLayout,
+		DecimalSeparator:  *decimalSeparator,
+		Delimiter:         delimiterRune,
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.importSpendings(ctx, spendings, *dryRun, out)
+}
+
+// importSpendings creates the imported spendings that don't exist yet, all
+// or none of them. In a dry run it only prints the ones it would create.
+func (app *App) importSpendings(ctx context.Context, spendings []importedSpending, dryRun bool, out io.Writer) error {
+	if dryRun {
+		var created, skipped int
+		for _, imported := range spendings {
+			existing, err := app.FindSpendingByImportHash(ctx, *imported.Spending.ImportHash)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				skipped++
+				continue
+			}
+			created++
+			fmt.Fprintf(out, "Would create: %s\n", importedSpendingLine(imported))
+		}
+		fmt.Fprintf(out, "Would create %d spendings, skip %d already imported\n", created, skipped)
+		return nil
+	}
+
+	var created, skipped int
+	err := app.withTransaction(ctx, func(tx *App) error {
+		created, skipped = 0, 0
+		for _, imported := range spendings {
+			existing, err := tx.FindSpendingByImportHash(ctx, *imported.Spending.ImportHash)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				skipped++
+				continue
+			}
+
+			tags, err := tx.findOrCreateTags(ctx, imported.Tags)
+			if err != nil {
+				return err
+			}
+			spending := imported.Spending
+			if _, err := tx.StoreSpending(ctx, &spending); err != nil {
+				return fmt.Errorf("line %d: %w", imported.Line, err)
+			}
+			if err := tx.SyncSpendingTags(ctx, &spending, &tags); err != nil {
+				return fmt.Errorf("line %d: %w", imported.Line, err)
+			}
+			created++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Created %d spendings, skipped %d already imported\n", created, skipped)
+	return nil
+}
+
+// parseCSVSpendings reads the spendings of a CSV file with a header row. All
+// rows are checked before any spending is created, so errors are reported
+// with their line and nothing is imported from a broken file.
+func parseCSVSpendings(r io.Reader, options csvImportOptions) ([]importedSpending, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = options.Delimiter
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheets may start files with a byte order mark
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	column := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		i, ok := columns[strings.ToLower(name)]
+		if !ok {
+			return 0, fmt.Errorf("the CSV file has no %q column, its columns are: %s", name, strings.Join(header, ", "))
+		}
+		return i, nil
+	}
+	dateIndex, err := column(options.DateColumn)
+	if err != nil {
+		return nil, err
+	}
+	amountIndex, err := column(options.AmountColumn)
+	if err != nil {
+		return nil, err
+	}
+	if dateIndex < 0 || amountIndex < 0 {
+		return nil, errors.New("the date and amount columns are required")
+	}
+	descriptionIndex, err := column(options.DescriptionColumn)
+	if err != nil {
+		return nil, err
+	}
+	tagsIndex, err := column(options.TagsColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	var spendings []importedSpending
+	occurrences := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		date, err := time.ParseInLocation(options.DateLayout, field(dateIndex), time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q, expected the layout %s", line, field(dateIndex), options.DateLayout)
+		}
+		amount, err := parseAmount(field(amountIndex), options.DecimalSeparator)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		imported := importedSpending{
+			Line: line,
+			Spending: models.Spending{
+				ChatId:      options.ChatID,
+				Cost:        amount,
+				Description: field(descriptionIndex),
+				SpentAt:     date,
+			},
+			Tags: parseImportedTags(field(tagsIndex)),
+		}
+
+		// Identical rows are told apart by how many came before them, so
+		// they are all imported once
+		content := importContent(imported)
+		hash := importHash(content, occurrences[content])
+		occurrences[content]++
+		imported.Spending.ImportHash = &hash
+
+		spendings = append(spendings, imported)
+	}
+
+	return spendings, nil
+}
+
+// parseAmount parses an amount written with the given decimal separator,
+// ignoring thousands separators, spaces and currency symbols.
+func parseAmount(value, decimalSeparator string) (float64, error) {
+	var number strings.Builder
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9', r == '-', r == '+':
+			number.WriteRune(r)
+		case string(r) == decimalSeparator:
+			number.WriteRune('.')
+		}
+		// Anything else, like thousands separators, is left out
+	}
+
+	amount, err := strconv.ParseFloat(number.String(), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	return amount, nil
+}
+
+// parseImportedTags returns the tags in a field, separated by commas,
+// semicolons or spaces and optionally written as #hashtags.
+func parseImportedTags(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' '
+	})
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		tag := strings.TrimPrefix(field, "#")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// importContent returns what identifies an imported spending: its chat,
+// date, amount, description and tags. The date is the one written in the
+// file, read as UTC, so the hash doesn't change with the local time zone of
+// the machine importing it.
+func importContent(imported importedSpending) string {
+	tags := append([]string(nil), imported.Tags...)
+	sort.Strings(tags)
+
+	spending := imported.Spending
+	date := time.Date(spending.SpentAt.Year(), spending.SpentAt.Month(), spending.SpentAt.Day(),
+		spending.SpentAt.Hour(), spending.SpentAt.Minute(), spending.SpentAt.Second(), 0, time.UTC)
+	return strings.Join([]string{
+		strconv.FormatInt(spending.ChatId, 10),
+		date.Format(time.RFC3339),
+		strconv.FormatFloat(spending.Cost, 'f', 2, 64),
+		spending.Description,
+		strings.Join(tags, ","),
+	}, "\x00")
+}
+
+// importHash returns the import hash of the occurrence-th row with the given
+// content.
+func importHash(content string, occurrence int) string {
+	sum := sha256.Sum256([]byte(content + "\x00" + strconv.Itoa(occurrence)))
+	return hex.EncodeToString(sum[:])
+}
+
+func importedSpendingLine(imported importedSpending) string {
+	line := fmt.Sprintf("%s  %.2f  %s", imported.Spending.SpentAt.Format("2006-01-02"), imported.Spending.Cost, imported.Spending.Description)
+	for _, tag := range imported.Tags {
+		line += " #" + tag
+	}
+	return line
+}
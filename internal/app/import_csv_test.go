@@ -0,0 +1,156 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/internal/database"
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+func writeCSVFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "spendings.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write CSV file: %v", err)
+	}
+	return path
+}
+
+func TestImportCSV(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	app := &App{DB: mockDB, Bot: testutils.NewMockTelegramBot()}
+
+	path := writeCSVFile(t, "\ufeffDay;Betrag;Notes;Category\n"+
+		"10.05.2024;1.234,50;Rent;#home\n"+
+		"11.05.2024;3,20;Coffee;food, drinks\n"+
+		"11.05.2024;3,20;Coffee;food, drinks\n"+
+		";;;\n")
+	args := []string{"-chat", "123", "-date", "day", "-amount", "betrag", "-description", "notes", "-tags", "category",
+		"-date-layout", "02.01.2006", "-decimal", ",", "-delimiter", ";"}
+
+	var out bytes.Buffer
+	if err := app.ImportCSV(ctx, append([]string{"-dry-run"}, append(args, path)...), &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "Would create: 2024-05-10  1234.50  Rent #home\n" +
+		"Would create: 2024-05-11  3.20  Coffee #food #drinks\n" +
+		"Would create: 2024-05-11  3.20  Coffee #food #drinks\n" +
+		"Would create 3 spendings, skip 0 already imported\n"
+	if out.String() != expected {
+		t.Errorf("Expected output:\n%s\nGot:\n%s", expected, out.String())
+	}
+	if spendings, total, _ := app.FindSpendings(ctx, database.SpendingFilter{}); total != 0 {
+		t.Fatalf("Expected a dry run to create nothing, got %v", spendings)
+	}
+
+	out.Reset()
+	if err := app.ImportCSV(ctx, append(args, path), &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out.String() != "Created 3 spendings, skipped 0 already imported\n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	spendings, _, err := app.FindSpendings(ctx, database.SpendingFilter{ChatId: 123, Tags: []string{"home"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(spendings) != 1 || spendings[0].Cost != 1234.5 || spendings[0].Description != "Rent" || spendings[0].MessageId != 0 ||
+		!spendings[0].SpentAt.Equal(time.Date(2024, 5, 10, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("Unexpected imported spendings %+v", spendings)
+	}
+
+	// Importing the file again after adding a row only creates that row
+	appended := writeCSVFile(t, "Day;Betrag;Notes;Category\n"+
+		"10.05.2024;1.234,50;Rent;#home\n"+
+		"11.05.2024;3,20;Coffee;drinks food\n"+
+		"11.05.2024;3,20;Coffee;food, drinks\n"+
+		"11.05.2024;3,20;Coffee;food, drinks\n")
+	out.Reset()
+	if err := app.ImportCSV(ctx, append(args, appended), &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out.String() != "Created 1 spendings, skipped 3 already imported\n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+	if _, total, _ := app.FindSpendings(ctx, database.SpendingFilter{ChatId: 123, Text: "coffee"}); total != 3 {
+		t.Errorf("Expected 3 coffees, got %d", total)
+	}
+}
+
+func TestImportCSVErrors(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		args     []string
+		content  string
+		expected string
+	}{
+		{"missing chat", nil, "date,amount\n2024-05-10,3\n", "-chat"},
+		{"missing column", []string{"-chat", "1"}, "date,cost\n2024-05-10,3\n", `no "amount" column`},
+		{"invalid date", []string{"-chat", "1", "-tags", ""}, "date,amount,description\n2024-05-10,3,Tea\n10/05/2024,4,Tea\n", "line 3: invalid date"},
+		{"invalid amount", []string{"-chat", "1", "-description", "", "-tags", ""}, "date,amount\n2024-05-10,free\n", `line 2: invalid amount "free"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := testutils.NewMockDatabaseClient()
+			app := &App{DB: mockDB, Bot: testutils.NewMockTelegramBot()}
+
+			err := app.ImportCSV(ctx, append(tt.args, writeCSVFile(t, tt.content)), &bytes.Buffer{})
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Fatalf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+			if _, total, _ := app.FindSpendings(ctx, database.SpendingFilter{}); total != 0 {
+				t.Errorf("Expected nothing to be imported, got %d spendings", total)
+			}
+		})
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value            string
+		decimalSeparator string
+		expected         float64
+	}{
+		{"12.50", ".", 12.5},
+		{"1,234.50", ".", 1234.5},
+		{"1.234,50", ",", 1234.5},
+		{"€ 3,20", ",", 3.2},
+		{"-15", ".", -15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			amount, err := parseAmount(tt.value, tt.decimalSeparator)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if amount != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, amount)
+			}
+		})
+	}
+}
+
+func TestImportContentIgnoresTimeZone(t *testing.T) {
+	// The same row imported on machines in different time zones
+	berlin := importedSpending{Spending: models.Spending{ChatId: 1, Cost: 3, SpentAt: time.Date(2024, 5, 11, 0, 0, 0, 0, time.FixedZone("CEST", 2*60*60))}}
+	utc := importedSpending{Spending: models.Spending{ChatId: 1, Cost: 3, SpentAt: time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC)}}
+
+	if importHash(importContent(berlin), 0) != importHash(importContent(utc), 0) {
+		t.Errorf("Expected the import hash not to depend on the time zone")
+	}
+}
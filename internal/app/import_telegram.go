@@ -0,0 +1,257 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+// telegramExport is the result.json of a chat exported with Telegram
+// Desktop in the machine-readable JSON format.
+type telegramExport struct {
+	Name     string                  `json:"name"`
+	Type     string                  `json:"type"`
+	ID       int64                   `json:"id"`
+	Messages []telegramExportMessage `json:"messages"`
+}
+
+type telegramExportMessage struct {
+	ID            int                    `json:"id"`
+	Type          string                 `json:"type"`
+	Date          string                 `json:"date"`
+	DateUnixtime  string                 `json:"date_unixtime"`
+	From          string                 `json:"from"`
+	FromID        string                 `json:"from_id"`
+	ViaBot        string                 `json:"via_bot"`
+	ForwardedFrom string                 `json:"forwarded_from"`
+	Text          telegramExportText     `json:"text"`
+	TextEntities  []telegramExportEntity `json:"text_entities"`
+}
+
+type telegramExportEntity struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// telegramExportText is the text of an exported message, which is either a
+// string or a list of strings and entities like hashtags.
+type telegramExportText string
+
+func (t *telegramExportText) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*t = telegramExportText(text)
+		return nil
+	}
+
+	var parts []json.RawMessage
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return errors.New("message text is neither a string nor a list")
+	}
+
+	var builder strings.Builder
+	for _, part := range parts {
+		var entity telegramExportEntity
+		if err := json.Unmarshal(part, &text); err == nil {
+			builder.WriteString(text)
+		} else if err := json.Unmarshal(part, &entity); err == nil {
+			builder.WriteString(entity.Text)
+		} else {
+			return errors.New("message text contains an invalid part")
+		}
+	}
+	*t = telegramExportText(builder.String())
+	return nil
+}
+
+// telegramImportCounts counts what importing the messages of an export did.
+type telegramImportCounts struct {
+	Created   int
+	Updated   int
+	Unchanged int
+	Skipped   int
+}
+
+// ImportTelegramExport runs the import-telegram-export subcommand, which
+// backfills the spendings of a chat from a Telegram Desktop export. Messages
+// are handled like the bot handles new ones, with their original dates and
+// message IDs, so spendings the bot already knows are updated instead of
+// duplicated. Commands in the export aren't run and no replies are sent.
+// Replies of the bot, messages sent through bots and forwarded messages are
+// skipped, since they weren't written as spendings of the chat.
+func (app *App) ImportTelegramExport(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import-telegram-export", flag.ContinueOnError)
+	flags.SetOutput(out)
+	chatID := flags.Int64("chat", 0, "Bot API ID of the chat, when the one derived from the export is wrong")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected the result.json file to import")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open export: %w", err)
+	}
+	defer file.Close()
+
+	var export telegramExport
+	if err := json.NewDecoder(file).Decode(&export); err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+
+	chat := telegramExportChat(export)
+	if *chatID != 0 {
+		chat.ID = *chatID
+	}
+
+	counts, err := app.importTelegramMessages(ctx, chat, export.Messages)
+	fmt.Fprintf(out, "Created %d spendings, updated %d, %d unchanged, skipped %d messages without a spending\n",
+		counts.Created, counts.Updated, counts.Unchanged, counts.Skipped)
+	return err
+}
+
+func (app *App) importTelegramMessages(ctx context.Context, chat *tgbotapi.Chat, messages []telegramExportMessage) (telegramImportCounts, error) {
+	var counts telegramImportCounts
+
+	for _, exported := range messages {
+		if err := ctx.Err(); err != nil {
+			return counts, err
+		}
+
+		if !exported.writtenInChat(app.Bot.SelfID()) {
+			counts.Skipped++
+			continue
+		}
+
+		message, err := telegramExportToMessage(chat, exported)
+		if err != nil {
+			return counts, err
+		}
+		if message == nil || message.IsCommand() {
+			counts.Skipped++
+			continue
+		}
+
+		member, err := app.rememberExportedMember(ctx, message)
+		if err != nil {
+			return counts, fmt.Errorf("message %d: %w", exported.ID, err)
+		}
+
+		_, result, err := app.storeSpendingMessage(ctx, message, member)
+		if err != nil {
+			return counts, fmt.Errorf("message %d: %w", exported.ID, err)
+		}
+
+		switch result {
+		case spendingCreated:
+			counts.Created++
+		case spendingUpdated:
+			counts.Updated++
+		case spendingUnchanged:
+			counts.Unchanged++
+		default:
+			counts.Skipped++
+		}
+	}
+
+	return counts, nil
+}
+
+// writtenInChat reports whether an exported message was written in the chat
+// by one of its members, rather than being a reply of the bot, sent through
+// an inline bot or forwarded from elsewhere.
+func (m telegramExportMessage) writtenInChat(botID int64) bool {
+	return m.FromID != "user"+strconv.FormatInt(botID, 10) && m.ViaBot == "" && m.ForwardedFrom == ""
+}
+
+// rememberExportedMember records the sender of an exported group message as
+// a member. Exports don't contain usernames, so members the bot knows
+// already are kept as they are instead of losing theirs.
+func (app *App) rememberExportedMember(ctx context.Context, message *tgbotapi.Message) (*models.ChatMember, error) {
+	if isGroupChat(message.Chat) && message.From != nil {
+		member, err := app.FindChatMemberByUserId(ctx, message.Chat.ID, message.From.ID)
+		if err != nil || member != nil {
+			return member, err
+		}
+	}
+	return app.rememberChatMember(ctx, message)
+}
+
+// telegramExportChat returns the chat of an export. Exports contain chat IDs
+// without the prefixes the Bot API uses for groups, so they are added back.
+func telegramExportChat(export telegramExport) *tgbotapi.Chat {
+	chat := &tgbotapi.Chat{ID: export.ID, Title: export.Name, Type: "private"}
+
+	switch {
+	case strings.HasSuffix(export.Type, "supergroup"):
+		chat.ID = -1000000000000 - export.ID
+		chat.Type = "supergroup"
+	case strings.HasSuffix(export.Type, "channel"):
+		chat.ID = -1000000000000 - export.ID
+		chat.Type = "channel"
+	case export.Type == "private_group":
+		chat.ID = -export.ID
+		chat.Type = "group"
+	}
+
+	return chat
+}
+
+// telegramExportToMessage converts an exported message into the message the
+// Bot API would have delivered, or nil for service messages like members
+// joining.
+func telegramExportToMessage(chat *tgbotapi.Chat, exported telegramExportMessage) (*tgbotapi.Message, error) {
+	if exported.Type != "message" {
+		return nil, nil
+	}
+
+	var sentAt time.Time
+	if unixtime, err := strconv.ParseInt(exported.DateUnixtime, 10, 64); err == nil {
+		sentAt = time.Unix(unixtime, 0)
+	} else if date, err := time.ParseInLocation("2006-01-02T15:04:05", exported.Date, time.Local); err == nil {
+		sentAt = date
+	} else {
+		return nil, fmt.Errorf("message %d has an invalid date %q", exported.ID, exported.Date)
+	}
+
+	message := &tgbotapi.Message{
+		MessageID: exported.ID,
+		Date:      int(sentAt.Unix()),
+		Chat:      chat,
+		Text:      string(exported.Text),
+	}
+
+	// Senders are exported as IDs like user123456; channels posting in a
+	// group have no user to remember
+	if userID, ok := strings.CutPrefix(exported.FromID, "user"); ok {
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("message %d has an invalid sender %q", exported.ID, exported.FromID)
+		}
+		message.From = &tgbotapi.User{ID: id, FirstName: exported.From}
+	}
+
+	// Commands only need their entity to be recognized
+	if len(exported.TextEntities) > 0 && exported.TextEntities[0].Type == "bot_command" {
+		message.Entities = []tgbotapi.MessageEntity{{
+			Type:   "bot_command",
+			Offset: 0,
+			Length: len(utf16.Encode([]rune(exported.TextEntities[0].Text))),
+		}}
+	}
+
+	return message, nil
+}
@@ -0,0 +1,237 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+)
+
+const testTelegramExport = `{
+ "name": "Flat",
+ "type": "private_supergroup",
+ "id": 1234567890,
+ "messages": [
+  {
+   "id": 1,
+   "type": "service",
+   "date": "2024-03-01T09:00:00",
+   "date_unixtime": "1709283600",
+   "actor": "Sara",
+   "actor_id": "user7",
+   "action": "invite_members",
+   "text": "",
+   "text_entities": []
+  },
+  {
+   "id": 2,
+   "type": "message",
+   "date": "2024-03-01T19:30:00",
+   "date_unixtime": "1709321400",
+   "from": "Sara",
+   "from_id": "user7",
+   "text": [
+    "Pizza 24 ",
+    {
+     "type": "hashtag",
+     "text": "#food"
+    }
+   ],
+   "text_entities": [
+    {
+     "type": "plain",
+     "text": "Pizza 24 "
+    },
+    {
+     "type": "hashtag",
+     "text": "#food"
+    }
+   ]
+  },
+  {
+   "id": 3,
+   "type": "message",
+   "date": "2024-03-02T10:00:00",
+   "date_unixtime": "1709373600",
+   "from": "Ali",
+   "from_id": "user8",
+   "text": [
+    {
+     "type": "bot_command",
+     "text": "/report"
+    },
+    " 10"
+   ],
+   "text_entities": [
+    {
+     "type": "bot_command",
+     "text": "/report"
+    },
+    {
+     "type": "plain",
+     "text": " 10"
+    }
+   ]
+  },
+  {
+   "id": 4,
+   "type": "message",
+   "date": "2024-03-03T08:15:00",
+   "date_unixtime": "1709453700",
+   "from": "Ali",
+   "from_id": "user8",
+   "text": "Cleaning supplies 12.40 2024-03-02",
+   "text_entities": [
+    {
+     "type": "plain",
+     "text": "Cleaning supplies 12.40 2024-03-02"
+    }
+   ]
+  },
+  {
+   "id": 5,
+   "type": "message",
+   "date": "2024-03-03T08:20:00",
+   "date_unixtime": "1709453800",
+   "from": "Ali",
+   "from_id": "user8",
+   "text": "Thanks!",
+   "text_entities": [
+    {
+     "type": "plain",
+     "text": "Thanks!"
+    }
+   ]
+  }
+ ]
+}`
+
+func TestImportTelegramExport(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot}
+
+	path := filepath.Join(t.TempDir(), "result.json")
+	if err := os.WriteFile(path, []byte(testTelegramExport), 0o644); err != nil {
+		t.Fatalf("Failed to write export: %v", err)
+	}
+
+	// The bot already saw the pizza, but with a different price
+	chatID := int64(-1001234567890)
+	update := testutils.NewTestGroupUpdate(2, chatID, tgbotapi.User{ID: 7, FirstName: "Sara", UserName: "sara"}, "Pizza 20 #food")
+	update.Message.Date = 1709321400
+	app.handleUpdate(ctx, update)
+
+	var out bytes.Buffer
+	if err := app.ImportTelegramExport(ctx, []string{path}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "Created 1 spendings, updated 1, 0 unchanged, skipped 3 messages without a spending\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+
+	pizza, _ := mockDB.FindSpendingByMessageId(ctx, chatID, 2)
+	mockDB.VerifySpending(t, pizza, 24, time.Unix(1709321400, 0))
+	mockDB.VerifySpendingTags(t, pizza, []string{"food"})
+
+	cleaning, _ := mockDB.FindSpendingByMessageId(ctx, chatID, 4)
+	if cleaning == nil || cleaning.ChatId != chatID || cleaning.SenderId != 8 || cleaning.SenderName != "Ali" {
+		t.Fatalf("Expected the spending to be created in the group, got %+v", cleaning)
+	}
+	mockDB.VerifySpending(t, cleaning, 12.40, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+
+	if spending, _ := mockDB.FindSpendingByMessageId(ctx, chatID, 3); spending != nil {
+		t.Errorf("Expected the command not to create a spending, got %+v", spending)
+	}
+	if member, _ := mockDB.FindChatMemberByUserId(ctx, chatID, 8); member == nil || member.DisplayName != "Ali" {
+		t.Errorf("Expected the sender to be remembered as a member, got %+v", member)
+	}
+	if member, _ := mockDB.FindChatMemberByUserId(ctx, chatID, 7); member == nil || member.UserName != "sara" {
+		t.Errorf("Expected the known member to keep their username, got %+v", member)
+	}
+
+	// Importing again changes nothing
+	out.Reset()
+	if err := app.ImportTelegramExport(ctx, []string{path}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected = "Created 0 spendings, updated 0, 2 unchanged, skipped 3 messages without a spending\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+	if len(mockDB.GetSpendings()) != 2 {
+		t.Errorf("Expected 2 spendings, got %d", len(mockDB.GetSpendings()))
+	}
+}
+
+const testTelegramExportWithBot = `{
+ "name": "Kia",
+ "type": "personal_chat",
+ "id": 7,
+ "messages": [
+  {"id": 1, "type": "message", "date_unixtime": "1709321400", "from": "Kia", "from_id": "user7", "text": "Taxi 12"},
+  {"id": 2, "type": "message", "date_unixtime": "1709321401", "from": "Spendings", "from_id": "user4242", "text": "Total: 12.00"},
+  {"id": 3, "type": "message", "date_unixtime": "1709321402", "from": "Kia", "from_id": "user7", "text": "/budget food 400", "text_entities": [{"type": "bot_command", "text": "/budget"}, {"type": "plain", "text": " food 400"}]},
+  {"id": 4, "type": "message", "date_unixtime": "1709321403", "from": "Spendings", "from_id": "user4242", "text": "Budget for food set to 400.00"},
+  {"id": 5, "type": "message", "date_unixtime": "1709321404", "from": "Kia", "from_id": "user7", "via_bot": "@pricebot", "text": "Coffee 3"},
+  {"id": 6, "type": "message", "date_unixtime": "1709321405", "from": "Kia", "from_id": "user7", "forwarded_from": "Sara", "text": "Rent 900"}
+ ]
+}`
+
+func TestImportTelegramExportSkipsBotMessages(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	app := &App{DB: mockDB, Bot: testutils.NewMockTelegramBot()}
+
+	path := filepath.Join(t.TempDir(), "result.json")
+	if err := os.WriteFile(path, []byte(testTelegramExportWithBot), 0o644); err != nil {
+		t.Fatalf("Failed to write export: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := app.ImportTelegramExport(ctx, []string{path}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "Created 1 spendings, updated 0, 0 unchanged, skipped 5 messages without a spending\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+
+	spendings := mockDB.GetSpendings()
+	if len(spendings) != 1 {
+		t.Fatalf("Expected only the spending written in the chat, got %d spendings", len(spendings))
+	}
+	if taxi, _ := mockDB.FindSpendingByMessageId(ctx, 7, 1); taxi == nil || taxi.Cost != 12 {
+		t.Errorf("Expected the taxi to be imported, got %+v", taxi)
+	}
+}
+
+func TestTelegramExportChat(t *testing.T) {
+	tests := []struct {
+		exportType string
+		expectedID int64
+	}{
+		{"personal_chat", 1234567890},
+		{"private_group", -1234567890},
+		{"private_supergroup", -1001234567890},
+		{"public_supergroup", -1001234567890},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.exportType, func(t *testing.T) {
+			chat := telegramExportChat(telegramExport{Type: tt.exportType, ID: 1234567890})
+			if chat.ID != tt.expectedID {
+				t.Errorf("Expected chat ID %d, got %d", tt.expectedID, chat.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/internal/database"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+const listPageSize = 10
+
+// Telegram limits the data of inline buttons to 64 bytes
+const maxCallbackDataLength = 64
+
+const listUsage = "Usage: /list [today|week|month|last_month|year|all]"
+
+const searchUsage = "Usage: /search <text|#tag|>amount|<amount>...>\n\n" +
+	"Example: /search taxi #transport >20"
+
+var costConditionPattern = regexp.MustCompile(`^(>=|<=|>|<)(\d+(?:[.,]\d+)?)$`)
+
+// spendingPage is a page of spendings shown by /list or /search. Its buttons
+// show other pages with callback data of the form kind:offset:argument.
+type spendingPage struct {
+	title  string
+	empty  string
+	filter database.SpendingFilter
+	// search ranks spendings by how well they match the filter's text
+	// instead of listing the newest first
+	search           bool
+	callbackKind     string
+	callbackArgument string
+}
+
+func (app *App) handleListCommand(ctx context.Context, message *tgbotapi.Message) {
+	period := strings.TrimSpace(message.CommandArguments())
+
+	page, ok := listPage(message.Chat.ID, period, time.Now())
+	if !ok {
+		app.Bot.SendMessage(ctx, message.Chat.ID, listUsage)
+		return
+	}
+
+	app.sendSpendingPage(ctx, message.Chat.ID, page)
+}
+
+func (app *App) handleSearchCommand(ctx context.Context, message *tgbotapi.Message) {
+	query := strings.TrimSpace(message.CommandArguments())
+
+	page, ok := searchPage(message.Chat.ID, query)
+	if !ok {
+		app.Bot.SendMessage(ctx, message.Chat.ID, searchUsage)
+		return
+	}
+
+	app.sendSpendingPage(ctx, message.Chat.ID, page)
+}
+
+// handleCallbackQuery handles presses of the buttons paging through /list
+// and /search results by editing the message with the requested page.
+func (app *App) handleCallbackQuery(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	defer func() {
+		if err := app.Bot.AnswerCallbackQuery(ctx, query.ID, ""); err != nil {
+			fmt.Printf("Error answering callback query: %v\n", err)
+		}
+	}()
+
+	if query.Message == nil || query.Message.Chat == nil {
+		return
+	}
+	chatID := query.Message.Chat.ID
+
+	kind, rest, _ := strings.Cut(query.Data, ":")
+	offsetValue, argument, _ := strings.Cut(rest, ":")
+	offset, err := strconv.Atoi(offsetValue)
+	if err != nil || offset < 0 {
+		return
+	}
+
+	var page *spendingPage
+	var ok bool
+	switch kind {
+	case "list":
+		page, ok = listPage(chatID, argument, time.Now())
+	case "search":
+		page, ok = searchPage(chatID, argument)
+	}
+	if !ok {
+		return
+	}
+	page.filter.Offset = offset
+
+	text, keyboard, err := app.renderSpendingPage(ctx, page)
+	if err != nil {
+		fmt.Printf("Error finding spendings: %v\n", err)
+		return
+	}
+
+	if err := app.Bot.EditMessage(ctx, chatID, query.Message.MessageID, text, keyboard); err != nil {
+		fmt.Printf("Error editing message: %v\n", err)
+	}
+}
+
+// listPage returns the first page of /list for period, which is one of
+// today, week, month (the default), last_month, year and all.
+func listPage(chatID int64, period string, now time.Time) (*spendingPage, bool) {
+	var title string
+	filter := database.SpendingFilter{ChatId: chatID, Limit: listPageSize}
+
+	switch period {
+	case "today":
+		title = "today"
+		filter.StartDate = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		filter.EndDate = filter.StartDate.AddDate(0, 0, 1).Add(-time.Second)
+	case "week":
+		title = "current week"
+		filter.StartDate, filter.EndDate = budgetPeriodRange(models.BudgetPeriodWeekly, now)
+	case "", "month":
+		period = "month"
+		title = "current month"
+		filter.StartDate, filter.EndDate = budgetPeriodRange(models.BudgetPeriodMonthly, now)
+	case "last_month":
+		title = "last month"
+		filter.StartDate, filter.EndDate = budgetPeriodRange(models.BudgetPeriodMonthly, now.AddDate(0, 0, -now.Day()))
+	case "year":
+		title = "current year"
+		filter.StartDate, filter.EndDate = budgetPeriodRange(models.BudgetPeriodYearly, now)
+	case "all":
+		title = "all time"
+	default:
+		return nil, false
+	}
+
+	return &spendingPage{
+		title:            "Spendings for " + title,
+		empty:            "No spendings for " + title + ".",
+		filter:           filter,
+		callbackKind:     "list",
+		callbackArgument: period,
+	}, true
+}
+
+// searchPage returns the first page of /search results for query. Words
+// starting with # match tags, amounts prefixed with >, >=, < or <= match
+// costs and all other words match descriptions, best matches first.
+func searchPage(chatID int64, query string) (*spendingPage, bool) {
+	filter := database.SpendingFilter{ChatId: chatID, Limit: listPageSize}
+
+	var words []string
+	for _, word := range strings.Fields(query) {
+		if strings.HasPrefix(word, "#") && len(word) > 1 {
+			filter.Tags = append(filter.Tags, word[1:])
+			continue
+		}
+		if match := costConditionPattern.FindStringSubmatch(word); match != nil {
+			amount, err := strconv.ParseFloat(strings.Replace(match[2], ",", ".", 1), 64)
+			if err != nil {
+				return nil, false
+			}
+			filter.Costs = append(filter.Costs, database.CostCondition{Operator: match[1], Amount: amount})
+			continue
+		}
+		words = append(words, word)
+	}
+	filter.Text = strings.Join(words, " ")
+
+	if filter.Text == "" && len(filter.Tags) == 0 && len(filter.Costs) == 0 {
+		return nil, false
+	}
+
+	query = strings.Join(strings.Fields(query), " ")
+
+	return &spendingPage{
+		title:            fmt.Sprintf("Spendings matching %q", query),
+		empty:            fmt.Sprintf("No spendings match %q.", query),
+		filter:           filter,
+		search:           true,
+		callbackKind:     "search",
+		callbackArgument: query,
+	}, true
+}
+
+func (app *App) sendSpendingPage(ctx context.Context, chatID int64, page *spendingPage) {
+	text, keyboard, err := app.renderSpendingPage(ctx, page)
+	if err != nil {
+		fmt.Printf("Error finding spendings: %v\n", err)
+		app.Bot.SendMessage(ctx, chatID, "Failed to find spendings")
+		return
+	}
+
+	if keyboard == nil {
+		app.Bot.SendMessage(ctx, chatID, text)
+		return
+	}
+	app.Bot.SendMessageWithKeyboard(ctx, chatID, text, *keyboard)
+}
+
+// renderSpendingPage returns the text of a page and the buttons to move to
+// the previous and next pages, if there are any.
+func (app *App) renderSpendingPage(ctx context.Context, page *spendingPage) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	find := app.FindSpendings
+	if page.search {
+		find = app.SearchSpendings
+	}
+
+	spendings, total, err := find(ctx, page.filter)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(spendings) == 0 {
+		return page.empty, nil, nil
+	}
+
+	offset := page.filter.Offset
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("%s (%d-%d of %d):\n\n", page.title, offset+1, offset+len(spendings), total))
+	for _, spending := range spendings {
+		text.WriteString(fmt.Sprintf("%s  %.2f  %s\n", spending.SpentAt.Local().Format("2006-01-02"), spending.Cost, listDescription(spending.Description)))
+	}
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	if offset > 0 {
+		previous := max(offset-listPageSize, 0)
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("« Prev", page.callbackData(previous)))
+	}
+	if next := offset + len(spendings); int64(next) < total {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("Next »", page.callbackData(next)))
+	}
+
+	for _, button := range buttons {
+		if len(*button.CallbackData) > maxCallbackDataLength {
+			text.WriteString("\nThe search is too long to page through, shorten it to see the other results.")
+			return strings.TrimSpace(text.String()), nil, nil
+		}
+	}
+	if len(buttons) == 0 {
+		return strings.TrimSpace(text.String()), nil, nil
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons)
+	return strings.TrimSpace(text.String()), &keyboard, nil
+}
+
+func (page *spendingPage) callbackData(offset int) string {
+	return fmt.Sprintf("%s:%d:%s", page.callbackKind, offset, page.callbackArgument)
+}
+
+// listDescription returns the first line of a spending's description,
+// shortened to fit a list.
+func listDescription(description string) string {
+	const maxLength = 40
+
+	description, _, _ = strings.Cut(strings.TrimSpace(description), "\n")
+	if utf8.RuneCountInString(description) <= maxLength {
+		return description
+	}
+	return string([]rune(description)[:maxLength-1]) + "…"
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/internal/database"
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+)
+
+func TestListCommand(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot}
+
+	for i := 1; i <= 12; i++ {
+		app.handleUpdate(ctx, testutils.NewTestUpdate(i, 123456789, fmt.Sprintf("Coffee %d", i)))
+	}
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(13, 123456789, 1, "/list"))
+
+	today := time.Now().Format("2006-01-02")
+	var expected strings.Builder
+	expected.WriteString("Spendings for current month (1-10 of 12):\n\n")
+	for i := 12; i > 2; i-- {
+		expected.WriteString(fmt.Sprintf("%s  %d.00  Coffee %d\n", today, i, i))
+	}
+	mockBot.VerifyMessage(t, strings.TrimSpace(expected.String()))
+	verifyKeyboard(t, mockBot.LastKeyboard(), "Next »", "list:10:month")
+
+	app.handleUpdate(ctx, &tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "callback-1",
+		From:    &tgbotapi.User{ID: 1},
+		Message: &tgbotapi.Message{MessageID: 100, Chat: &tgbotapi.Chat{ID: 123456789}},
+		Data:    "list:10:month",
+	}})
+
+	expectedPage := fmt.Sprintf("Spendings for current month (11-12 of 12):\n\n%s  2.00  Coffee 2\n%s  1.00  Coffee 1", today, today)
+	if edits := mockBot.EditedMessages(); len(edits) != 1 || edits[0] != expectedPage {
+		t.Errorf("Expected the message to be edited to %q, got %q", expectedPage, edits)
+	}
+	verifyKeyboard(t, mockBot.LastKeyboard(), "« Prev", "list:0:month")
+
+	if answers := mockBot.CallbackAnswers(); len(answers) != 1 || answers[0] != "callback-1" {
+		t.Errorf("Expected the callback query to be answered, got %v", answers)
+	}
+
+	mockBot.Reset()
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(14, 123456789, 1, "/list last_month"))
+	mockBot.VerifyMessage(t, "No spendings for last month.")
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(15, 123456789, 1, "/list fortnight"))
+	mockBot.VerifyMessage(t, listUsage)
+}
+
+func TestSearchCommand(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot}
+
+	app.handleUpdate(ctx, testutils.NewTestUpdate(1, 123456789, "Taxi to the airport 60 #transport"))
+	app.handleUpdate(ctx, testutils.NewTestUpdate(2, 123456789, "Taxi home 15 #transport"))
+	app.handleUpdate(ctx, testutils.NewTestUpdate(3, 123456789, "Lunch 25 #food"))
+
+	today := time.Now().Format("2006-01-02")
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(4, 123456789, 1, "/search taxi >50"))
+	mockBot.VerifyMessage(t, fmt.Sprintf("Spendings matching \"taxi >50\" (1-1 of 1):\n\n%s  60.00  Taxi to the airport 60 #transport", today))
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(5, 123456789, 1, "/search #food"))
+	mockBot.VerifyMessage(t, fmt.Sprintf("Spendings matching \"#food\" (1-1 of 1):\n\n%s  25.00  Lunch 25 #food", today))
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(6, 123456789, 1, "/search bus"))
+	mockBot.VerifyMessage(t, "No spendings match \"bus\".")
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(7, 123456789, 1, "/search"))
+	mockBot.VerifyMessage(t, searchUsage)
+
+	if keyboard := mockBot.LastKeyboard(); keyboard != nil {
+		t.Errorf("Expected no buttons for single pages, got %+v", keyboard)
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+	page, ok := searchPage(1, "  taxi   airport #transport >=20 <100,5 ")
+	if !ok {
+		t.Fatal("Expected the query to be valid")
+	}
+
+	filter := page.filter
+	if filter.Text != "taxi airport" {
+		t.Errorf("Expected text %q, got %q", "taxi airport", filter.Text)
+	}
+	if len(filter.Tags) != 1 || filter.Tags[0] != "transport" {
+		t.Errorf("Expected tag transport, got %v", filter.Tags)
+	}
+	expectedCosts := []database.CostCondition{{Operator: ">=", Amount: 20}, {Operator: "<", Amount: 100.5}}
+	if len(filter.Costs) != 2 || filter.Costs[0] != expectedCosts[0] || filter.Costs[1] != expectedCosts[1] {
+		t.Errorf("Expected costs %v, got %v", expectedCosts, filter.Costs)
+	}
+	if page.callbackData(10) != "search:10:taxi airport #transport >=20 <100,5" {
+		t.Errorf("Unexpected callback data %q", page.callbackData(10))
+	}
+}
+
+func verifyKeyboard(t *testing.T, keyboard *tgbotapi.InlineKeyboardMarkup, expectedText, expectedData string) {
+	t.Helper()
+
+	if keyboard == nil || len(keyboard.InlineKeyboard) != 1 || len(keyboard.InlineKeyboard[0]) != 1 {
+		t.Fatalf("Expected a single button, got %+v", keyboard)
+	}
+	button := keyboard.InlineKeyboard[0][0]
+	if button.Text != expectedText || button.CallbackData == nil || *button.CallbackData != expectedData {
+		t.Errorf("Expected button %q with data %q, got %+v", expectedText, expectedData, button)
+	}
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"fmt"
+)
+
+// MigrateDatabase runs the migrate-database subcommand: "up" applies pending
+// migrations, "down" reverts the latest one and "status" lists them all.
+func (app *App) MigrateDatabase(ctx context.Context, action string) error {
+	switch action {
+	case "up":
+		applied, err := app.DB.MigrateUp(ctx)
+		for _, migration := range applied {
+			fmt.Printf("Applied migration %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database schema is up to date")
+		}
+	case "down":
+		reverted, err := app.DB.MigrateDown(ctx)
+		if err != nil {
+			return err
+		}
+		if reverted == nil {
+			fmt.Println("No migrations to revert")
+			return nil
+		}
+		fmt.Printf("Reverted migration %d_%s\n", reverted.Version, reverted.Name)
+	case "status":
+		statuses, err := app.DB.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%d_%s: %s\n", status.Version, status.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate-database action %q, expected up, down or status", action)
+	}
+
+	return nil
+}
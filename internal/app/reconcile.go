@@ -0,0 +1,242 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/internal/database"
+	"github.com/kiasaty/spendings-tracker/models"
+	"github.com/kiasaty/spendings-tracker/pkg/statement"
+)
+
+func (app *App) StoreStatementMatch(ctx context.Context, match *models.StatementMatch) (*models.StatementMatch, error) {
+	match, err := app.DB.CreateStatementMatch(ctx, match)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store statement match: %w", err)
+	}
+	return match, nil
+}
+
+func (app *App) GetStatementMatchesByChat(ctx context.Context, chatID int64) ([]models.StatementMatch, error) {
+	matches, err := app.DB.GetStatementMatchesByChat(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statement matches: %w", err)
+	}
+	return matches, nil
+}
+
+// reconciliation is the result of matching the lines of a statement with
+// the spendings of a chat.
+type reconciliation struct {
+	matches            []models.StatementMatch
+	matchedBefore      int
+	unmatchedLines     []statement.Line
+	unmatchedSpendings []models.Spending
+	incoming           int
+}
+
+// Reconcile runs the reconcile subcommand, which compares a bank statement
+// with the spendings logged in a chat. Payments on the statement are matched
+// with spendings of the same amount within a few days of them, and the
+// payments and spendings without a match are printed. Matches are saved, so
+// reconciling a statement again, or the next one overlapping it, only looks
+// at what wasn't matched before.
+func (app *App) Reconcile(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	flags.SetOutput(out)
+	chatID := flags.Int64("chat", 0, "chat whose spendings to reconcile the statement with (required)")
+	format := flags.String("format", "", "ofx, qif or camt053, by default taken from the file extension")
+	window := flags.Int("window", 3, "maximum number of days between a payment and its spending")
+	dryRun := flags.Bool("dry-run", false, "only print the matches instead of saving them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("expected the statement file to reconcile")
+	}
+	if *chatID == 0 {
+		return errors.New("the -chat to reconcile is required")
+	}
+	if *window < 0 {
+		return fmt.Errorf("invalid window %d, expected a number of days", *window)
+	}
+	if *format == "" {
+		*format = statement.FormatOf(flags.Arg(0))
+		if *format == "" {
+			return errors.New("unknown statement format, pass -format ofx, qif or camt053")
+		}
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open statement: %w", err)
+	}
+	defer file.Close()
+
+	lines, err := statement.Parse(file, *format)
+	if err != nil {
+		return fmt.Errorf("failed to read statement: %w", err)
+	}
+
+	result, err := app.reconcileStatement(ctx, *chatID, lines, *window)
+	if err != nil {
+		return err
+	}
+
+	if !*dryRun && len(result.matches) > 0 {
+		err := app.withTransaction(ctx, func(tx *App) error {
+			for i := range result.matches {
+				if _, err := tx.StoreStatementMatch(ctx, &result.matches[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	printReconciliation(out, result, *dryRun)
+	return nil
+}
+
+// reconcileStatement matches the payments of a statement that weren't
+// matched before with the chat's spendings. Payments are matched in the
+// order they were booked, each with the closest spending of the same amount
+// within window days.
+func (app *App) reconcileStatement(ctx context.Context, chatID int64, lines []statement.Line, window int) (*reconciliation, error) {
+	result := &reconciliation{}
+
+	matches, err := app.GetStatementMatchesByChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	matchedLines := make(map[string]bool, len(matches))
+	matchedSpendings := make(map[uint]bool, len(matches))
+	for _, match := range matches {
+		matchedLines[match.LineId] = true
+		matchedSpendings[match.SpendingId] = true
+	}
+
+	var payments []statement.Line
+	for _, line := range lines {
+		if line.Amount >= 0 {
+			result.incoming++
+			continue
+		}
+		payments = append(payments, line)
+	}
+	if len(payments) == 0 {
+		return result, nil
+	}
+	sort.SliceStable(payments, func(i, j int) bool {
+		return payments[i].Date.Before(payments[j].Date)
+	})
+
+	first, last := payments[0].Date, payments[len(payments)-1].Date
+	spendings, _, err := app.FindSpendings(ctx, database.SpendingFilter{
+		ChatId:    chatID,
+		StartDate: first.AddDate(0, 0, -window),
+		EndDate:   last.AddDate(0, 0, window+1).Add(-time.Second),
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(spendings, func(i, j int) bool {
+		if !spendings[i].SpentAt.Equal(spendings[j].SpentAt) {
+			return spendings[i].SpentAt.Before(spendings[j].SpentAt)
+		}
+		return spendings[i].ID < spendings[j].ID
+	})
+
+	for _, line := range payments {
+		if matchedLines[line.ID] {
+			result.matchedBefore++
+			continue
+		}
+
+		best, bestDays := -1, 0
+		for i, spending := range spendings {
+			if matchedSpendings[spending.ID] || math.Round(spending.Cost*100) != math.Round(-line.Amount*100) {
+				continue
+			}
+			days := daysBetween(line.Date, spending.SpentAt)
+			if days <= window && (best < 0 || days < bestDays) {
+				best, bestDays = i, days
+			}
+		}
+		if best < 0 {
+			result.unmatchedLines = append(result.unmatchedLines, line)
+			continue
+		}
+
+		matchedLines[line.ID] = true
+		matchedSpendings[spendings[best].ID] = true
+		result.matches = append(result.matches, models.StatementMatch{
+			ChatId:      chatID,
+			SpendingId:  spendings[best].ID,
+			LineId:      line.ID,
+			Amount:      line.Amount,
+			BookedAt:    line.Date,
+			Description: line.Description,
+		})
+	}
+
+	// Spendings outside the statement's period may be on the previous or
+	// next one, so they aren't reported
+	periodEnd := last.AddDate(0, 0, 1)
+	for _, spending := range spendings {
+		if !matchedSpendings[spending.ID] && !spending.SpentAt.Before(first) && spending.SpentAt.Before(periodEnd) {
+			result.unmatchedSpendings = append(result.unmatchedSpendings, spending)
+		}
+	}
+
+	return result, nil
+}
+
+func printReconciliation(out io.Writer, result *reconciliation, dryRun bool) {
+	verb := "Matched"
+	if dryRun {
+		verb = "Would match"
+	}
+	fmt.Fprintf(out, "%s %d statement payments with spendings, %d were matched before\n", verb, len(result.matches), result.matchedBefore)
+
+	if len(result.unmatchedLines) > 0 {
+		fmt.Fprintf(out, "\nPayments without a spending (%d):\n", len(result.unmatchedLines))
+		for _, line := range result.unmatchedLines {
+			fmt.Fprintf(out, "  %s  %.2f  %s\n", line.Date.Format("2006-01-02"), -line.Amount, listDescription(line.Description))
+		}
+	}
+
+	if len(result.unmatchedSpendings) > 0 {
+		fmt.Fprintf(out, "\nSpendings without a payment (%d):\n", len(result.unmatchedSpendings))
+		for _, spending := range result.unmatchedSpendings {
+			fmt.Fprintf(out, "  %s  %.2f  spending %d: %s\n", spending.SpentAt.Local().Format("2006-01-02"), spending.Cost, spending.ID, listDescription(spending.Description))
+		}
+	}
+
+	if result.incoming > 0 {
+		fmt.Fprintf(out, "\nIgnored %d incoming payments\n", result.incoming)
+	}
+}
+
+// daysBetween returns the number of calendar days between a statement date
+// and the local date of a spending.
+func daysBetween(date, spentAt time.Time) int {
+	local := spentAt.Local()
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+	days := int(math.Round(day.Sub(date).Hours() / 24))
+	if days < 0 {
+		return -days
+	}
+	return days
+}
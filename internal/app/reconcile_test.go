@@ -0,0 +1,109 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+const testStatement = "!Type:Bank\n" +
+	"D05/11/2024\nT-25.50\nPLunch\n^\n" +
+	"D05/13/2024\nT-12.00\nPTaxi\n^\n" +
+	"D05/15/2024\nT-99.00\nPHardware store\n^\n" +
+	"D05/15/2024\nT100.00\nPRefund\n^\n"
+
+func newReconcileTestApp(t *testing.T) *App {
+	t.Helper()
+	ctx := context.Background()
+
+	app := &App{DB: testutils.NewMockDatabaseClient(), Bot: testutils.NewMockTelegramBot()}
+	for _, spending := range []models.Spending{
+		{ChatId: 123, Cost: 25.5, Description: "Lunch 25.50", SpentAt: time.Date(2024, 5, 10, 13, 0, 0, 0, time.Local)},
+		{ChatId: 123, Cost: 12, Description: "Taxi 12", SpentAt: time.Date(2024, 5, 12, 22, 0, 0, 0, time.Local)},
+		{ChatId: 123, Cost: 40, Description: "Books 40", SpentAt: time.Date(2024, 5, 14, 10, 0, 0, 0, time.Local)},
+		{ChatId: 123, Cost: 25.5, Description: "Lunch again 25.50", SpentAt: time.Date(2024, 5, 20, 13, 0, 0, 0, time.Local)},
+		{ChatId: 456, Cost: 99, Description: "Hardware 99", SpentAt: time.Date(2024, 5, 15, 10, 0, 0, 0, time.Local)},
+	} {
+		if _, err := app.StoreSpending(ctx, &spending); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	return app
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	app := newReconcileTestApp(t)
+
+	file := filepath.Join(t.TempDir(), "statement.qif")
+	if err := os.WriteFile(file, []byte(testStatement), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	unmatched := "\nPayments without a spending (1):\n" +
+		"  2024-05-15  99.00  Hardware store\n" +
+		"\nSpendings without a payment (1):\n" +
+		"  2024-05-14  40.00  spending 3: Books 40\n" +
+		"\nIgnored 1 incoming payments\n"
+
+	var out bytes.Buffer
+	if err := app.Reconcile(ctx, []string{"-chat", "123", "-dry-run", file}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := "Would match 2 statement payments with spendings, 0 were matched before\n" + unmatched; out.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, out.String())
+	}
+	if matches, _ := app.GetStatementMatchesByChat(ctx, 123); len(matches) != 0 {
+		t.Errorf("Expected a dry run not to save matches, got %+v", matches)
+	}
+
+	out.Reset()
+	if err := app.Reconcile(ctx, []string{"-chat", "123", file}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := "Matched 2 statement payments with spendings, 0 were matched before\n" + unmatched; out.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, out.String())
+	}
+
+	matches, _ := app.GetStatementMatchesByChat(ctx, 123)
+	if len(matches) != 2 || matches[0].SpendingId != 1 || matches[0].Description != "Lunch" || matches[1].SpendingId != 2 {
+		t.Errorf("Expected lunch and taxi to be matched, got %+v", matches)
+	}
+
+	out.Reset()
+	if err := app.Reconcile(ctx, []string{"-chat", "123", file}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := "Matched 0 statement payments with spendings, 2 were matched before\n" + unmatched; out.String() != expected {
+		t.Errorf("Expected a rerun to keep the matches, got:\n%s", out.String())
+	}
+}
+
+func TestReconcileWindow(t *testing.T) {
+	ctx := context.Background()
+	app := newReconcileTestApp(t)
+
+	file := filepath.Join(t.TempDir(), "statement.txt")
+	if err := os.WriteFile(file, []byte(testStatement), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := app.Reconcile(ctx, []string{"-chat", "123", file}, &out); err == nil {
+		t.Error("Expected an error for a file of unknown format")
+	}
+
+	if err := app.Reconcile(ctx, []string{"-chat", "123", "-format", "qif", "-window", "0", file}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	matches, _ := app.GetStatementMatchesByChat(ctx, 123)
+	if len(matches) != 0 {
+		t.Errorf("Expected no payment to match a spending on another day, got %+v", matches)
+	}
+}
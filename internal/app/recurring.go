@@ -0,0 +1,342 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/models"
+	"github.com/kiasaty/spendings-tracker/pkg/extractors"
+)
+
+var weekdays = map[string]int{
+	"monday":    1,
+	"tuesday":   2,
+	"wednesday": 3,
+	"thursday":  4,
+	"friday":    5,
+	"saturday":  6,
+	"sunday":    7,
+}
+
+func (app *App) StoreRecurringSpending(ctx context.Context, recurringSpending *models.RecurringSpending) (*models.RecurringSpending, error) {
+	recurringSpending, err := app.DB.CreateRecurringSpending(ctx, recurringSpending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store recurring spending: %w", err)
+	}
+	return recurringSpending, nil
+}
+
+func (app *App) FindRecurringSpending(ctx context.Context, id uint) (*models.RecurringSpending, error) {
+	recurringSpending, err := app.DB.FindRecurringSpending(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find recurring spending: %w", err)
+	}
+	return recurringSpending, nil
+}
+
+func (app *App) DeleteRecurringSpending(ctx context.Context, recurringSpending *models.RecurringSpending) error {
+	err := app.DB.DeleteRecurringSpending(ctx, recurringSpending)
+	if err != nil {
+		return fmt.Errorf("failed to delete recurring spending: %w", err)
+	}
+	return nil
+}
+
+func (app *App) SyncRecurringSpendingTags(ctx context.Context, recurringSpending *models.RecurringSpending, tags *[]models.Tag) error {
+	err := app.DB.SyncRecurringSpendingTags(ctx, recurringSpending, tags)
+	if err != nil {
+		return fmt.Errorf("failed to sync recurring spending tags: %w", err)
+	}
+	return nil
+}
+
+// RunRecurring creates the spendings of all recurring spendings that are due
+// by now. Occurrences that already have a spending are skipped, so it is
+// safe to run repeatedly, e.g. from cron.
+func (app *App) RunRecurring(ctx context.Context, now time.Time) (int, error) {
+	recurringSpendings, err := app.DB.GetRecurringSpendings(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get recurring spendings: %w", err)
+	}
+
+	created := 0
+	for _, recurringSpending := range recurringSpendings {
+		for _, date := range recurrenceDates(recurringSpending, now) {
+			existing, err := app.DB.FindSpendingByRecurrence(ctx, recurringSpending.ID, date, date.AddDate(0, 0, 1).Add(-time.Second))
+			if err != nil {
+				return created, fmt.Errorf("failed to find spending by recurrence: %w", err)
+			}
+			if existing != nil {
+				continue
+			}
+
+			recurringSpendingID := recurringSpending.ID
+			err = app.withTransaction(ctx, func(tx *App) error {
+				spending, err := tx.StoreSpending(ctx, &models.Spending{
+					ChatId:              recurringSpending.ChatId,
+					SenderId:            recurringSpending.SenderId,
+					SenderName:          recurringSpending.SenderName,
+					Cost:                recurringSpending.Cost,
+					Description:         recurringSpending.Name,
+					SpentAt:             date,
+					RecurringSpendingId: &recurringSpendingID,
+				})
+				if err != nil {
+					return err
+				}
+
+				tags := recurringSpending.Tags
+				return tx.SyncSpendingTags(ctx, spending, &tags)
+			})
+			if err != nil {
+				return created, err
+			}
+
+			created++
+		}
+	}
+
+	return created, nil
+}
+
+// recurrenceDates returns every occurrence of a recurring spending from its
+// start up to and including now.
+func recurrenceDates(recurringSpending models.RecurringSpending, now time.Time) []time.Time {
+	start := recurringSpending.StartsAt.In(now.Location())
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, now.Location())
+
+	var dates []time.Time
+	add := func(date time.Time) bool {
+		if date.After(now) {
+			return false
+		}
+		if !date.Before(start) {
+			dates = append(dates, date)
+		}
+		return true
+	}
+
+	switch recurringSpending.Frequency {
+	case models.RecurrenceWeekly:
+		offset := (recurringSpending.Day - isoWeekday(start) + 7) % 7
+		for date := start.AddDate(0, 0, offset); add(date); date = date.AddDate(0, 0, 7) {
+		}
+	case models.RecurrenceYearly:
+		for year := start.Year(); add(dayOfMonth(year, start.Month(), recurringSpending.Day, now.Location())); year++ {
+		}
+	default:
+		for month := 0; add(dayOfMonth(start.Year(), start.Month()+time.Month(month), recurringSpending.Day, now.Location())); month++ {
+		}
+	}
+
+	return dates
+}
+
+// dayOfMonth returns the given day of a month, clamped to the month's last
+// day so that e.g. "on 31" also recurs in February.
+func dayOfMonth(year int, month time.Month, day int, location *time.Location) time.Time {
+	firstOfMonth := time.Date(year, month, 1, 0, 0, 0, 0, location)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
+
+// isoWeekday returns the weekday of a date with Monday as 1 and Sunday as 7.
+func isoWeekday(date time.Time) int {
+	return (int(date.Weekday())+6)%7 + 1
+}
+
+func (app *App) handleRecurringCommand(ctx context.Context, message *tgbotapi.Message) {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Usage: /recurring add|list|stop")
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		app.handleRecurringAddCommand(ctx, message, args[1:])
+	case "list":
+		app.handleRecurringListCommand(ctx, message)
+	case "stop":
+		app.handleRecurringStopCommand(ctx, message, args[1:])
+	default:
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Usage: /recurring add|list|stop")
+	}
+}
+
+// handleRecurringAddCommand handles e.g. "/recurring add rent 800 monthly on 1 #housing".
+func (app *App) handleRecurringAddCommand(ctx context.Context, message *tgbotapi.Message, args []string) {
+	usage := "Usage: /recurring add <name> <amount> <weekly|monthly|yearly> [on <day>] [#tags]"
+
+	var words []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "#") {
+			words = append(words, arg)
+		}
+	}
+
+	frequencyIndex := -1
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if word == models.RecurrenceWeekly || word == models.RecurrenceMonthly || word == models.RecurrenceYearly {
+			frequencyIndex = i
+			break
+		}
+	}
+	if frequencyIndex < 2 {
+		app.Bot.SendMessage(ctx, message.Chat.ID, usage)
+		return
+	}
+
+	cost, err := strconv.ParseFloat(words[frequencyIndex-1], 64)
+	if err != nil || cost <= 0 {
+		app.Bot.SendMessage(ctx, message.Chat.ID, usage)
+		return
+	}
+
+	now := time.Now()
+	startsAt := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	frequency := strings.ToLower(words[frequencyIndex])
+
+	day := startsAt.Day()
+	if frequency == models.RecurrenceWeekly {
+		day = isoWeekday(startsAt)
+	}
+
+	rest := words[frequencyIndex+1:]
+	if len(rest) > 0 {
+		if len(rest) != 2 || rest[0] != "on" {
+			app.Bot.SendMessage(ctx, message.Chat.ID, usage)
+			return
+		}
+		day, err = parseRecurrenceDay(frequency, rest[1])
+		if err != nil {
+			app.Bot.SendMessage(ctx, message.Chat.ID, err.Error())
+			return
+		}
+	}
+
+	var recurringSpending *models.RecurringSpending
+	err = app.withTransaction(ctx, func(tx *App) error {
+		recurringSpending, err = tx.StoreRecurringSpending(ctx, &models.RecurringSpending{
+			ChatId:     message.Chat.ID,
+			SenderId:   senderId(message.From),
+			SenderName: senderName(message.From),
+			Name:       strings.Join(words[:frequencyIndex-1], " "),
+			Cost:       cost,
+			Frequency:  frequency,
+			Day:        day,
+			StartsAt:   startsAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		tags, err := tx.findOrCreateTags(ctx, extractors.ExtractHashtags(strings.Join(args, " ")))
+		if err != nil {
+			return err
+		}
+		return tx.SyncRecurringSpendingTags(ctx, recurringSpending, &tags)
+	})
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to add the recurring spending")
+		return
+	}
+
+	app.Bot.SendMessage(ctx, message.Chat.ID, "Added recurring spending "+describeRecurringSpending(*recurringSpending))
+}
+
+func parseRecurrenceDay(frequency, value string) (int, error) {
+	if frequency == models.RecurrenceWeekly {
+		if day, ok := weekdays[strings.ToLower(value)]; ok {
+			return day, nil
+		}
+		day, err := strconv.Atoi(value)
+		if err != nil || day < 1 || day > 7 {
+			return 0, fmt.Errorf("Weekly spendings recur on a weekday, e.g. \"on monday\".")
+		}
+		return day, nil
+	}
+
+	day, err := strconv.Atoi(value)
+	if err != nil || day < 1 || day > 31 {
+		return 0, fmt.Errorf("The day must be a day of the month between 1 and 31.")
+	}
+	return day, nil
+}
+
+func (app *App) handleRecurringListCommand(ctx context.Context, message *tgbotapi.Message) {
+	recurringSpendings, err := app.DB.GetRecurringSpendingsByChat(ctx, message.Chat.ID)
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to list recurring spendings")
+		return
+	}
+
+	if len(recurringSpendings) == 0 {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "There are no recurring spendings.")
+		return
+	}
+
+	var list strings.Builder
+	list.WriteString("Recurring spendings:\n")
+	for _, recurringSpending := range recurringSpendings {
+		list.WriteString("\n" + describeRecurringSpending(recurringSpending))
+	}
+
+	app.Bot.SendMessage(ctx, message.Chat.ID, list.String())
+}
+
+func (app *App) handleRecurringStopCommand(ctx context.Context, message *tgbotapi.Message, args []string) {
+	if len(args) != 1 {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Usage: /recurring stop <id>")
+		return
+	}
+
+	id, err := strconv.ParseUint(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Usage: /recurring stop <id>")
+		return
+	}
+
+	recurringSpending, err := app.FindRecurringSpending(ctx, uint(id))
+	if err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to stop the recurring spending")
+		return
+	}
+	if recurringSpending == nil || recurringSpending.ChatId != message.Chat.ID {
+		app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("There is no recurring spending #%d.", id))
+		return
+	}
+
+	if err := app.DeleteRecurringSpending(ctx, recurringSpending); err != nil {
+		app.Bot.SendMessage(ctx, message.Chat.ID, "Failed to stop the recurring spending")
+		return
+	}
+
+	app.Bot.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("Stopped recurring spending #%d (%s).", recurringSpending.ID, recurringSpending.Name))
+}
+
+func describeRecurringSpending(recurringSpending models.RecurringSpending) string {
+	var schedule string
+	switch recurringSpending.Frequency {
+	case models.RecurrenceWeekly:
+		schedule = "weekly on " + time.Weekday(recurringSpending.Day%7).String()
+	case models.RecurrenceYearly:
+		schedule = fmt.Sprintf("yearly on %s %d", recurringSpending.StartsAt.Month(), recurringSpending.Day)
+	default:
+		schedule = fmt.Sprintf("monthly on day %d", recurringSpending.Day)
+	}
+
+	description := fmt.Sprintf("#%d %s: %.2f %s", recurringSpending.ID, recurringSpending.Name, recurringSpending.Cost, schedule)
+	for _, tag := range recurringSpending.Tags {
+		description += " #" + tag.Name
+	}
+	return description
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+func TestRecurrenceDates(t *testing.T) {
+	now := time.Date(2024, 5, 9, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name              string
+		recurringSpending models.RecurringSpending
+		expected          []time.Time
+	}{
+		{
+			name: "Monthly spendings recur on the given day",
+			recurringSpending: models.RecurringSpending{
+				Frequency: models.RecurrenceMonthly,
+				Day:       1,
+				StartsAt:  time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC),
+			},
+			expected: []time.Time{
+				time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "Monthly spendings recur on the last day of shorter months",
+			recurringSpending: models.RecurringSpending{
+				Frequency: models.RecurrenceMonthly,
+				Day:       31,
+				StartsAt:  time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+			},
+			expected: []time.Time{
+				time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "Weekly spendings recur on the given weekday",
+			recurringSpending: models.RecurringSpending{
+				Frequency: models.RecurrenceWeekly,
+				Day:       1,
+				StartsAt:  time.Date(2024, 4, 24, 0, 0, 0, 0, time.UTC),
+			},
+			expected: []time.Time{
+				time.Date(2024, 4, 29, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "Yearly spendings recur in their starting month",
+			recurringSpending: models.RecurringSpending{
+				Frequency: models.RecurrenceYearly,
+				Day:       3,
+				StartsAt:  time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC),
+			},
+			expected: []time.Time{
+				time.Date(2022, 5, 3, 0, 0, 0, 0, time.UTC),
+				time.Date(2023, 5, 3, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dates := recurrenceDates(tt.recurringSpending, now)
+
+			if !reflect.DeepEqual(dates, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, dates)
+			}
+		})
+	}
+}
+
+func TestRunRecurring(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot}
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(1, 123456789, 1, "/recurring add rent 800 monthly on 1 #housing"))
+
+	mockBot.VerifyMessage(t, "Added recurring spending #1 rent: 800.00 monthly on day 1 #housing")
+
+	recurringSpending, _ := mockDB.FindRecurringSpending(ctx, 1)
+	if recurringSpending == nil {
+		t.Fatalf("Expected recurring spending to be stored")
+	}
+	recurringSpending.StartsAt = time.Date(2024, 2, 15, 0, 0, 0, 0, time.Local)
+	now := time.Date(2024, 5, 9, 12, 0, 0, 0, time.Local)
+
+	created, err := app.RunRecurring(ctx, now)
+	if err != nil {
+		t.Fatalf("Failed to run recurring spendings: %v", err)
+	}
+	if created != 3 {
+		t.Errorf("Expected 3 spendings to be created, got %d", created)
+	}
+
+	created, _ = app.RunRecurring(ctx, now)
+	if created != 0 {
+		t.Errorf("Expected rerun to create no spendings, got %d", created)
+	}
+
+	for _, spending := range mockDB.GetSpendings() {
+		if spending.Cost != 800 || spending.Description != "rent" || spending.SpentAt.Day() != 1 {
+			t.Errorf("Unexpected recurring spending: %+v", spending)
+		}
+		mockDB.VerifySpendingTags(t, spending, []string{"housing"})
+	}
+
+	app.handleUpdate(ctx, testutils.NewTestCommandUpdate(2, 123456789, 1, "/recurring stop 1"))
+	mockBot.VerifyMessage(t, "Stopped recurring spending #1 (rent).")
+
+	created, _ = app.RunRecurring(ctx, now.AddDate(0, 1, 0))
+	if created != 0 {
+		t.Errorf("Expected stopped recurring spending to create no spendings, got %d", created)
+	}
+}
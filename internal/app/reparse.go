@@ -0,0 +1,231 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kiasaty/spendings-tracker/internal/database"
+	"github.com/kiasaty/spendings-tracker/models"
+	"github.com/kiasaty/spendings-tracker/pkg/extractors"
+)
+
+// reparsedSpending is a spending along with what the extractors make of its
+// description now.
+type reparsedSpending struct {
+	spending models.Spending
+	updated  models.Spending
+	tags     []string
+}
+
+// Reparse runs the reparse subcommand, which extracts the cost, date and tags
+// of spendings from their descriptions again, so improvements of the
+// extractors reach spendings created before. It prints what would change
+// and only changes it with -apply.
+//
+// Spendings that weren't created from a message, like imported and recurring
+// ones, and spendings corrected by hand are left alone. -keep marks spendings
+// as corrected by hand, so the ones whose stored values are right can be
+// excluded after looking at the changes. Like the changes, they are only
+// marked with -apply.
+func (app *App) Reparse(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("reparse", flag.ContinueOnError)
+	flags.SetOutput(out)
+	chatID := flags.Int64("chat", 0, "only reparse the spendings of this chat")
+	apply := flags.Bool("apply", false, "update the spendings instead of only printing the changes")
+	keep := flags.String("keep", "", "IDs of spendings to mark as corrected by hand, separated by commas")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	keepIDs, err := parseSpendingIDs(*keep)
+	if err != nil {
+		return err
+	}
+
+	spendings, _, err := app.FindSpendings(ctx, database.SpendingFilter{ChatId: *chatID})
+	if err != nil {
+		return err
+	}
+
+	var changes []reparsedSpending
+	var kept []models.Spending
+	var corrected, notFromMessages, withoutPrice int
+	for _, spending := range spendings {
+		if slices.Contains(keepIDs, spending.ID) && !spending.ManuallyCorrected {
+			spending.ManuallyCorrected = true
+			kept = append(kept, spending)
+		}
+
+		switch {
+		case spending.MessageId == 0:
+			notFromMessages++
+			continue
+		case spending.ManuallyCorrected:
+			corrected++
+			continue
+		}
+
+		change, ok := reparseSpending(spending)
+		if !ok {
+			withoutPrice++
+			fmt.Fprintf(out, "Spending %d has no price any more and is left alone: %s\n", spending.ID, listDescription(spending.Description))
+			continue
+		}
+		if change != nil {
+			changes = append(changes, *change)
+			printReparsedSpending(out, *change)
+		}
+	}
+
+	skipped := fmt.Sprintf("skipped %d corrected by hand, %d not created from messages and %d without a price", corrected, notFromMessages, withoutPrice)
+	if !*apply {
+		if len(kept) > 0 {
+			var ids []string
+			for _, spending := range kept {
+				ids = append(ids, strconv.FormatUint(uint64(spending.ID), 10))
+			}
+			fmt.Fprintf(out, "Would mark %d spendings as corrected by hand: %s\n", len(kept), strings.Join(ids, ", "))
+		}
+		fmt.Fprintf(out, "%d of %d spendings would change, %s\n", len(changes), len(spendings), skipped)
+		if len(changes) > 0 || len(kept) > 0 {
+			fmt.Fprintln(out, "Run with -apply to update them")
+		}
+		return nil
+	}
+
+	for _, spending := range kept {
+		if _, err := app.UpdateSpending(ctx, &spending); err != nil {
+			return fmt.Errorf("failed to mark spending %d as corrected by hand: %w", spending.ID, err)
+		}
+	}
+	if len(kept) > 0 {
+		fmt.Fprintf(out, "Marked %d spendings as corrected by hand\n", len(kept))
+	}
+
+	for _, change := range changes {
+		if err := app.applyReparsedSpending(ctx, change); err != nil {
+			return fmt.Errorf("failed to update spending %d: %w", change.spending.ID, err)
+		}
+	}
+	fmt.Fprintf(out, "Updated %d of %d spendings, %s\n", len(changes), len(spendings), skipped)
+	return nil
+}
+
+// reparseSpending extracts a spending from its description again and returns
+// it when it differs from the stored one. ok is false when the description
+// has no price any more.
+func reparseSpending(spending models.Spending) (change *reparsedSpending, ok bool) {
+	price, err := extractors.ExtractPrice(spending.Description)
+	if err != nil {
+		return nil, false
+	}
+
+	updated := spending
+	updated.Cost = price
+	// Without a date in the text the spending keeps the time its message
+	// was sent
+	if date, err := extractors.ExtractDate(spending.Description); err == nil {
+		updated.SpentAt = date
+	}
+
+	tags := extractors.ExtractHashtags(spending.Description)
+
+	if updated.Cost == spending.Cost && updated.SpentAt.Equal(spending.SpentAt) && sameTagNames(spendingTagNames(spending), tags) {
+		return nil, true
+	}
+	return &reparsedSpending{spending: spending, updated: updated, tags: tags}, true
+}
+
+// applyReparsedSpending stores a reparsed spending with its tags, splitting
+// group spendings again when their cost changed.
+func (app *App) applyReparsedSpending(ctx context.Context, change reparsedSpending) error {
+	// Telegram is asked before the transaction, so the database isn't locked
+	// while waiting for it
+	split := false
+	if change.updated.Cost != change.spending.Cost {
+		isGroup, err := app.isGroupChatID(ctx, change.spending.ChatId)
+		if err != nil {
+			return err
+		}
+		split = isGroup
+	}
+
+	return app.withTransaction(ctx, func(tx *App) error {
+		tags, err := tx.findOrCreateTags(ctx, change.tags)
+		if err != nil {
+			return err
+		}
+
+		spending := change.updated
+		spending.Tags = nil
+		if _, err := tx.UpdateSpending(ctx, &spending); err != nil {
+			return err
+		}
+		if err := tx.SyncSpendingTags(ctx, &spending, &tags); err != nil {
+			return err
+		}
+
+		if !split {
+			return nil
+		}
+		return tx.splitSpending(ctx, &spending, extractors.ExtractMentions(spending.Description))
+	})
+}
+
+func printReparsedSpending(out io.Writer, change reparsedSpending) {
+	before, after := change.spending, change.updated
+
+	fmt.Fprintf(out, "Spending %d in chat %d: %s\n", before.ID, before.ChatId, listDescription(before.Description))
+	if after.Cost != before.Cost {
+		fmt.Fprintf(out, "  cost: %.2f -> %.2f\n", before.Cost, after.Cost)
+	}
+	if !after.SpentAt.Equal(before.SpentAt) {
+		fmt.Fprintf(out, "  date: %s -> %s\n", before.SpentAt.Local().Format("2006-01-02"), after.SpentAt.Local().Format("2006-01-02"))
+	}
+	if tags := spendingTagNames(before); !sameTagNames(tags, change.tags) {
+		fmt.Fprintf(out, "  tags: %s -> %s\n", formatTagNames(tags), formatTagNames(change.tags))
+	}
+}
+
+// sameTagNames reports whether two lists contain the same tag names, in any
+// order.
+func sameTagNames(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+func formatTagNames(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	names = slices.Clone(names)
+	slices.Sort(names)
+	return strings.Join(slices.Compact(names), ", ")
+}
+
+// parseSpendingIDs parses a list of spending IDs separated by commas.
+func parseSpendingIDs(value string) ([]uint, error) {
+	var ids []uint
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spending ID %q", field)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+func TestReparse(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	app := &App{DB: mockDB, Bot: testutils.NewMockTelegramBot()}
+
+	// Spendings as older extractors might have stored them
+	stored := func(messageID int, text string, update func(spending *models.Spending)) {
+		t.Helper()
+
+		app.handleUpdate(ctx, testutils.NewTestUpdate(messageID, 123, text))
+		spending, _ := mockDB.FindSpendingByMessageId(ctx, 123, messageID)
+		if spending == nil {
+			t.Fatalf("Expected a spending for %q", text)
+		}
+		update(spending)
+		if _, err := app.UpdateSpending(ctx, spending); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	stored(1, "Taxi 12.50 #transport 2024-05-10", func(spending *models.Spending) {
+		spending.Cost = 12
+		spending.Tags = nil
+	})
+	stored(2, "Dinner 30 2024-05-11", func(spending *models.Spending) {})
+	stored(3, "Rent 900 2024-05-01", func(spending *models.Spending) {
+		spending.Cost = 950
+		spending.ManuallyCorrected = true
+	})
+	stored(4, "Books 15 2024-05-12", func(spending *models.Spending) {
+		spending.Cost = 16
+	})
+	if _, err := app.StoreSpending(ctx, &models.Spending{ChatId: 123, Cost: 3, Description: "Coffee", SpentAt: time.Date(2024, 5, 9, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stored(6, "Lunch 20 2024-05-13", func(spending *models.Spending) {
+		spending.Description = "Lunch"
+	})
+
+	var out bytes.Buffer
+	if err := app.Reparse(ctx, []string{"-keep", "4"}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "Spending 6 has no price any more and is left alone: Lunch\n" +
+		"Spending 1 in chat 123: Taxi 12.50 #transport 2024-05-10\n" +
+		"  cost: 12.00 -> 12.50\n" +
+		"  tags: none -> transport\n" +
+		"Would mark 1 spendings as corrected by hand: 4\n" +
+		"1 of 6 spendings would change, skipped 2 corrected by hand, 1 not created from messages and 1 without a price\n" +
+		"Run with -apply to update them\n"
+	if out.String() != expected {
+		t.Errorf("Expected output:\n%s\nGot:\n%s", expected, out.String())
+	}
+
+	taxi, _ := mockDB.FindSpendingByMessageId(ctx, 123, 1)
+	if taxi.Cost != 12 {
+		t.Errorf("Expected no changes without -apply, got cost %v", taxi.Cost)
+	}
+	if books, _ := mockDB.FindSpendingByMessageId(ctx, 123, 4); books.ManuallyCorrected {
+		t.Errorf("Expected the kept spending not to be marked without -apply")
+	}
+
+	out.Reset()
+	if err := app.Reparse(ctx, []string{"-apply", "-keep", "4"}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.HasSuffix(out.Bytes(), []byte("Marked 1 spendings as corrected by hand\nUpdated 1 of 6 spendings, skipped 2 corrected by hand, 1 not created from messages and 1 without a price\n")) {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+
+	taxi, _ = mockDB.FindSpendingByMessageId(ctx, 123, 1)
+	mockDB.VerifySpending(t, taxi, 12.50, time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC))
+	mockDB.VerifySpendingTags(t, taxi, []string{"transport"})
+
+	if rent, _ := mockDB.FindSpendingByMessageId(ctx, 123, 3); rent.Cost != 950 {
+		t.Errorf("Expected the corrected spending to be left alone, got cost %v", rent.Cost)
+	}
+	if books, _ := mockDB.FindSpendingByMessageId(ctx, 123, 4); !books.ManuallyCorrected || books.Cost != 16 {
+		t.Errorf("Expected the kept spending to be marked as corrected by hand, got %+v", books)
+	}
+
+	out.Reset()
+	if err := app.Reparse(ctx, nil, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected = "Spending 6 has no price any more and is left alone: Lunch\n" +
+		"0 of 6 spendings would change, skipped 2 corrected by hand, 1 not created from messages and 1 without a price\n"
+	if out.String() != expected {
+		t.Errorf("Expected output:\n%s\nGot:\n%s", expected, out.String())
+	}
+}
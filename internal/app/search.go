@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const defaultSearchLimit = 50
+
+// Search runs the search subcommand, which prints the spendings matching a
+// query written like the one of /search, best matches first.
+func (app *App) Search(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	flags.SetOutput(out)
+	chatID := flags.Int64("chat", 0, "only search the spendings of this chat")
+	limit := flags.Int("limit", defaultSearchLimit, "maximum number of spendings to print")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	page, ok := searchPage(*chatID, strings.Join(flags.Args(), " "))
+	if !ok {
+		return errors.New("expected a query of words, #tags or >, >=, < and <= amounts")
+	}
+	page.filter.Limit = *limit
+
+	spendings, total, err := app.SearchSpendings(ctx, page.filter)
+	if err != nil {
+		return err
+	}
+
+	var sum float64
+	for _, spending := range spendings {
+		sum += spending.Cost
+		fmt.Fprintf(out, "%s  %d  %.2f  %s\n", spending.SpentAt.Local().Format("2006-01-02"), spending.ChatId, spending.Cost, listDescription(spending.Description))
+	}
+
+	if int64(len(spendings)) < total {
+		fmt.Fprintf(out, "Showing %d of %d matching spendings, total %.2f\n", len(spendings), total, sum)
+	} else {
+		fmt.Fprintf(out, "%d matching spendings, total %.2f\n", total, sum)
+	}
+
+	return nil
+}
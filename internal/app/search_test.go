@@ -0,0 +1,64 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+)
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB := testutils.NewMockDatabaseClient()
+	mockBot := testutils.NewMockTelegramBot()
+	app := &App{DB: mockDB, Bot: mockBot}
+
+	app.handleUpdate(ctx, testutils.NewTestUpdate(1, 123456789, "Taxi to the airport 60 #transport"))
+	app.handleUpdate(ctx, testutils.NewTestUpdate(2, 123456789, "Taxi home 15 #transport"))
+	app.handleUpdate(ctx, testutils.NewTestUpdate(3, 987654321, "Taxi 20"))
+
+	today := time.Now().Format("2006-01-02")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name: "all chats",
+			args: []string{"taxi", ">=20"},
+			expected: fmt.Sprintf("%s  987654321  20.00  Taxi 20\n%s  123456789  60.00  Taxi to the airport 60 #transport\n", today, today) +
+				"2 matching spendings, total 80.00\n",
+		},
+		{
+			name:     "one chat",
+			args:     []string{"-chat", "123456789", "-limit", "1", "#transport"},
+			expected: fmt.Sprintf("%s  123456789  15.00  Taxi home 15 #transport\nShowing 1 of 2 matching spendings, total 15.00\n", today),
+		},
+		{
+			name:     "no matches",
+			args:     []string{"bus"},
+			expected: "0 matching spendings, total 0.00\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := app.Search(ctx, tt.args, &out); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("Expected output:\n%s\nGot:\n%s", tt.expected, out.String())
+			}
+		})
+	}
+
+	if err := app.Search(ctx, nil, &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error for an empty query")
+	}
+}
@@ -0,0 +1,24 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+func (app *App) StoreSettlement(ctx context.Context, settlement *models.Settlement) (*models.Settlement, error) {
+	settlement, err := app.DB.CreateSettlement(ctx, settlement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store settlement: %w", err)
+	}
+	return settlement, nil
+}
+
+func (app *App) FindSettlementByMessageId(ctx context.Context, chatID int64, messageID int) (*models.Settlement, error) {
+	settlement, err := app.DB.FindSettlementByMessageId(ctx, chatID, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find settlement: %w", err)
+	}
+	return settlement, nil
+}
@@ -1,39 +1,89 @@
 package app
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/kiasaty/spendings-tracker/internal/database"
 	"github.com/kiasaty/spendings-tracker/models"
 )
 
-func (app *App) StoreSpending(spending *models.Spending) (*models.Spending, error) {
-	spending, err := app.DB.CreateSpending(spending)
+func (app *App) StoreSpending(ctx context.Context, spending *models.Spending) (*models.Spending, error) {
+	spending, err := app.DB.CreateSpending(ctx, spending)
 	if err != nil {
 		return nil, fmt.Errorf("failed to store spending: %w", err)
 	}
 	return spending, nil
 }
 
-func (app *App) FindSpendingByMessageId(messageID int) (*models.Spending, error) {
-	spending, err := app.DB.FindSpendingByMessageId(messageID)
+func (app *App) FindSpending(ctx context.Context, id uint) (*models.Spending, error) {
+	spending, err := app.DB.FindSpending(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find spending: %w", err)
 	}
 	return spending, nil
 }
 
-func (app *App) UpdateSpending(spending *models.Spending) (*models.Spending, error) {
-	err := app.DB.UpdateSpending(spending)
+func (app *App) FindSpendingByMessageId(ctx context.Context, chatID int64, messageID int) (*models.Spending, error) {
+	spending, err := app.DB.FindSpendingByMessageId(ctx, chatID, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find spending: %w", err)
+	}
+	return spending, nil
+}
+
+func (app *App) FindSpendingByImportHash(ctx context.Context, importHash string) (*models.Spending, error) {
+	spending, err := app.DB.FindSpendingByImportHash(ctx, importHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find spending: %w", err)
+	}
+	return spending, nil
+}
+
+func (app *App) UpdateSpending(ctx context.Context, spending *models.Spending) (*models.Spending, error) {
+	err := app.DB.UpdateSpending(ctx, spending)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update spending: %w", err)
 	}
 	return spending, nil
 }
 
-func (app *App) SyncSpendingTags(spending *models.Spending, tags *[]models.Tag) error {
-	err := app.DB.SyncSpendingTags(spending, tags)
+func (app *App) DeleteSpending(ctx context.Context, spending *models.Spending) error {
+	err := app.DB.DeleteSpending(ctx, spending)
+	if err != nil {
+		return fmt.Errorf("failed to delete spending: %w", err)
+	}
+	return nil
+}
+
+func (app *App) SyncSpendingTags(ctx context.Context, spending *models.Spending, tags *[]models.Tag) error {
+	err := app.DB.SyncSpendingTags(ctx, spending, tags)
 	if err != nil {
 		return fmt.Errorf("failed to sync spending tags: %w", err)
 	}
 	return nil
 }
+
+func (app *App) SyncSpendingShares(ctx context.Context, spending *models.Spending, shares *[]models.SpendingShare) error {
+	err := app.DB.SyncSpendingShares(ctx, spending, shares)
+	if err != nil {
+		return fmt.Errorf("failed to sync spending shares: %w", err)
+	}
+	return nil
+}
+
+func (app *App) FindSpendings(ctx context.Context, filter database.SpendingFilter) ([]models.Spending, int64, error) {
+	spendings, total, err := app.DB.FindSpendings(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find spendings: %w", err)
+	}
+	return spendings, total, nil
+}
+
+func (app *App) SearchSpendings(ctx context.Context, filter database.SpendingFilter) ([]models.Spending, int64, error) {
+	spendings, total, err := app.DB.SearchSpendings(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search spendings: %w", err)
+	}
+	return spendings, total, nil
+}
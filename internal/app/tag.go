@@ -1,23 +1,54 @@
 package app
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/kiasaty/spendings-tracker/models"
 )
 
-func (app *App) StoreTag(tag *models.Tag) (*models.Tag, error) {
-	tag, err := app.DB.CreateTag(tag)
+func (app *App) StoreTag(ctx context.Context, tag *models.Tag) (*models.Tag, error) {
+	tag, err := app.DB.CreateTag(ctx, tag)
 	if err != nil {
 		return nil, fmt.Errorf("failed to store tag: %w", err)
 	}
 	return tag, nil
 }
 
-func (app *App) FindTagByName(name string) (*models.Tag, error) {
-	tag, err := app.DB.FindTagByName(name)
+func (app *App) FindTagByName(ctx context.Context, name string) (*models.Tag, error) {
+	tag, err := app.DB.FindTagByName(ctx, name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find tag: %w", err)
 	}
 	return tag, nil
 }
+
+func (app *App) FindTags(ctx context.Context, chatID int64) ([]models.Tag, error) {
+	tags, err := app.DB.FindTags(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tags: %w", err)
+	}
+	return tags, nil
+}
+
+// findOrCreateTags returns the tags with the given names, creating the ones
+// that don't exist yet.
+func (app *App) findOrCreateTags(ctx context.Context, names []string) ([]models.Tag, error) {
+	var tags []models.Tag
+	for _, name := range names {
+		tag, err := app.FindTagByName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if tag == nil {
+			tag, err = app.StoreTag(ctx, &models.Tag{
+				Name: name,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		tags = append(tags, *tag)
+	}
+	return tags, nil
+}
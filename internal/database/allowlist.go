@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kiasaty/spendings-tracker/models"
+	"gorm.io/gorm"
+)
+
+func (c *Client) CreateAllowlistEntry(ctx context.Context, entry *models.AllowlistEntry) (*models.AllowlistEntry, error) {
+	result := c.DB.WithContext(ctx).Create(&entry)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return entry, nil
+}
+
+func (c *Client) FindAllowlistEntry(ctx context.Context, kind string, entityID int64) (*models.AllowlistEntry, error) {
+	var entry models.AllowlistEntry
+	err := c.DB.WithContext(ctx).Where("kind = ? AND entity_id = ?", kind, entityID).First(&entry).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find allowlist entry: %w", err)
+	}
+	return &entry, nil
+}
+
+func (c *Client) DeleteAllowlistEntry(ctx context.Context, entry *models.AllowlistEntry) error {
+	return c.DB.WithContext(ctx).Unscoped().Delete(entry).Error
+}
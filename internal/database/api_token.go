@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kiasaty/spendings-tracker/models"
+	"gorm.io/gorm"
+)
+
+func (c *Client) CreateAPIToken(ctx context.Context, token *models.APIToken) (*models.APIToken, error) {
+	result := c.DB.WithContext(ctx).Create(&token)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return token, nil
+}
+
+func (c *Client) DeleteAPIToken(ctx context.Context, token *models.APIToken) error {
+	return c.DB.WithContext(ctx).Delete(token).Error
+}
+
+func (c *Client) FindAPIToken(ctx context.Context, id uint) (*models.APIToken, error) {
+	var token models.APIToken
+	err := c.DB.WithContext(ctx).First(&token, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find API token: %w", err)
+	}
+	return &token, nil
+}
+
+func (c *Client) FindAPITokenByHash(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	var token models.APIToken
+	err := c.DB.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find API token by hash: %w", err)
+	}
+	return &token, nil
+}
+
+func (c *Client) GetAPITokensByChat(ctx context.Context, chatID int64) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	err := c.DB.WithContext(ctx).Where("chat_id = ?", chatID).Order("id").Find(&tokens).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API tokens by chat: %w", err)
+	}
+	return tokens, nil
+}
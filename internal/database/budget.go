@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kiasaty/spendings-tracker/models"
+	"gorm.io/gorm"
+)
+
+func (c *Client) CreateBudget(ctx context.Context, budget *models.Budget) (*models.Budget, error) {
+	result := c.DB.WithContext(ctx).Create(&budget)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return budget, nil
+}
+
+func (c *Client) UpdateBudget(ctx context.Context, budget *models.Budget) error {
+	result := c.DB.WithContext(ctx).Save(&budget)
+
+	return result.Error
+}
+
+func (c *Client) DeleteBudget(ctx context.Context, budget *models.Budget) error {
+	return c.DB.WithContext(ctx).Delete(budget).Error
+}
+
+func (c *Client) FindBudget(ctx context.Context, chatID int64, tagName string) (*models.Budget, error) {
+	var budget models.Budget
+	err := c.DB.WithContext(ctx).Where("chat_id = ? AND tag_name = ?", chatID, tagName).First(&budget).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find budget: %w", err)
+	}
+	return &budget, nil
+}
+
+func (c *Client) GetBudgetsByChat(ctx context.Context, chatID int64) ([]models.Budget, error) {
+	var budgets []models.Budget
+	err := c.DB.WithContext(ctx).Where("chat_id = ?", chatID).Order("tag_name").Find(&budgets).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budgets by chat: %w", err)
+	}
+	return budgets, nil
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kiasaty/spendings-tracker/models"
+	"gorm.io/gorm"
+)
+
+func (c *Client) CreateChatMember(ctx context.Context, member *models.ChatMember) (*models.ChatMember, error) {
+	result := c.DB.WithContext(ctx).Create(&member)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return member, nil
+}
+
+func (c *Client) UpdateChatMember(ctx context.Context, member *models.ChatMember) error {
+	result := c.DB.WithContext(ctx).Save(&member)
+
+	return result.Error
+}
+
+func (c *Client) FindChatMemberByUserId(ctx context.Context, chatID, userID int64) (*models.ChatMember, error) {
+	var member models.ChatMember
+	err := c.DB.WithContext(ctx).Where("chat_id = ? AND user_id = ?", chatID, userID).First(&member).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find chat member: %w", err)
+	}
+	return &member, nil
+}
+
+func (c *Client) FindChatMemberByUserName(ctx context.Context, chatID int64, userName string) (*models.ChatMember, error) {
+	var member models.ChatMember
+	err := c.DB.WithContext(ctx).Where("chat_id = ? AND LOWER(user_name) = LOWER(?)", chatID, userName).First(&member).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find chat member: %w", err)
+	}
+	return &member, nil
+}
+
+func (c *Client) GetChatMembers(ctx context.Context, chatID int64) ([]models.ChatMember, error) {
+	var members []models.ChatMember
+	err := c.DB.WithContext(ctx).Where("chat_id = ?", chatID).Order("id").Find(&members).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat members: %w", err)
+	}
+	return members, nil
+}
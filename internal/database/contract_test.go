@@ -0,0 +1,1045 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/internal/database"
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+// The contract tests run the same cases against the real client and the mock
+// the app tests use, so the mock can't silently behave differently.
+
+func TestClientContract(t *testing.T) {
+	runContractTests(t, func(t *testing.T) database.DatabaseClient {
+		client := database.NewTestClient(t)
+		if _, err := client.MigrateUp(context.Background()); err != nil {
+			t.Fatalf("Failed to migrate database: %v", err)
+		}
+		return client
+	})
+}
+
+func TestMockContract(t *testing.T) {
+	runContractTests(t, func(t *testing.T) database.DatabaseClient {
+		return testutils.NewMockDatabaseClient()
+	})
+}
+
+func runContractTests(t *testing.T, newClient func(t *testing.T) database.DatabaseClient) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, db database.DatabaseClient)
+	}{
+		{"Tags", testTags},
+		{"FindTags", testFindTags},
+		{"FindAndUpdateSpending", testFindAndUpdateSpending},
+		{"SpendingsByMessageInChats", testSpendingsByMessageInChats},
+		{"DeleteSpending", testDeleteSpending},
+		{"SpendingsWithoutMessage", testSpendingsWithoutMessage},
+		{"ImportedSpendings", testImportedSpendings},
+		{"SyncSpendingTags", testSyncSpendingTags},
+		{"SpendingsByDateRange", testSpendingsByDateRange},
+		{"SpendingsByDateRangeInOtherTimeZone", testSpendingsByDateRangeInOtherTimeZone},
+		{"FindSpendings", testFindSpendings},
+		{"SearchSpendings", testSearchSpendings},
+		{"Transaction", testTransaction},
+		{"SpendingShares", testSpendingShares},
+		{"ChatMembers", testChatMembers},
+		{"Settlements", testSettlements},
+		{"StatementMatches", testStatementMatches},
+		{"APITokens", testAPITokens},
+		{"DashboardSessions", testDashboardSessions},
+		{"Budgets", testBudgets},
+		{"RecurringSpendings", testRecurringSpendings},
+		{"LastUpdateId", testLastUpdateId},
+		{"Allowlist", testAllowlist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newClient(t))
+		})
+	}
+}
+
+func testTags(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+
+	tag, err := db.FindTagByName(ctx, "food")
+	if err != nil || tag != nil {
+		t.Fatalf("Expected no tag, got %v (%v)", tag, err)
+	}
+
+	created, err := db.CreateTag(ctx, &models.Tag{Name: "food"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if created.ID == 0 {
+		t.Error("Expected the created tag to have an ID")
+	}
+
+	tag, err = db.FindTagByName(ctx, "food")
+	if err != nil || tag == nil {
+		t.Fatalf("Expected tag to be found, got %v (%v)", tag, err)
+	}
+	if tag.ID != created.ID || tag.Name != "food" {
+		t.Errorf("Expected tag %d food, got %d %s", created.ID, tag.ID, tag.Name)
+	}
+}
+
+func testFindTags(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	spentAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	food, transport := createTag(t, db, "food"), createTag(t, db, "transport")
+	createTag(t, db, "unused")
+
+	lunch := createSpending(t, db, &models.Spending{ChatId: 1, MessageId: 1, Cost: 10, SpentAt: spentAt})
+	if err := db.SyncSpendingTags(ctx, lunch, &[]models.Tag{*food}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	taxi := createSpending(t, db, &models.Spending{ChatId: 2, MessageId: 2, Cost: 20, SpentAt: spentAt})
+	if err := db.SyncSpendingTags(ctx, taxi, &[]models.Tag{*transport, *food}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		chatID   int64
+		expected []string
+	}{
+		{0, []string{"food", "transport", "unused"}},
+		{1, []string{"food"}},
+		{2, []string{"food", "transport"}},
+		{3, nil},
+	}
+	for _, tt := range tests {
+		tags, err := db.FindTags(ctx, tt.chatID)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var names []string
+		for _, tag := range tags {
+			names = append(names, tag.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("Chat %d: expected tags %v, got %v", tt.chatID, tt.expected, names)
+		}
+	}
+}
+
+func testFindAndUpdateSpending(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	spentAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	created := createSpending(t, db, &models.Spending{ChatId: 1, MessageId: 10, Cost: 25, Description: "Lunch 25", SpentAt: spentAt})
+	if created.ID == 0 {
+		t.Error("Expected the created spending to have an ID")
+	}
+
+	spending := findSpending(t, db, 1, 10)
+	if spending == nil {
+		t.Fatal("Expected spending to be found")
+	}
+	if spending.ID != created.ID || spending.Cost != 25 || !spending.SpentAt.Equal(spentAt) {
+		t.Errorf("Unexpected spending %+v", spending)
+	}
+
+	if other := findSpending(t, db, 1, 11); other != nil {
+		t.Errorf("Expected no spending for another message, got %+v", other)
+	}
+
+	spending.Cost = 30
+	spending.Description = "Lunch 30"
+	spending.ManuallyCorrected = true
+	if err := db.UpdateSpending(ctx, spending); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	spending = findSpending(t, db, 1, 10)
+	if spending.Cost != 30 || spending.Description != "Lunch 30" || !spending.ManuallyCorrected {
+		t.Errorf("Expected updated spending, got %+v", spending)
+	}
+}
+
+func testSpendingsByMessageInChats(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	spentAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	// Message IDs are only unique within a chat
+	first := createSpending(t, db, &models.Spending{ChatId: 1, MessageId: 1, Cost: 10, SpentAt: spentAt})
+	second := createSpending(t, db, &models.Spending{ChatId: 2, MessageId: 1, Cost: 20, SpentAt: spentAt})
+
+	if spending := findSpending(t, db, 1, 1); spending == nil || spending.ID != first.ID || spending.Cost != 10 {
+		t.Errorf("Expected the spending of chat 1, got %+v", spending)
+	}
+	if spending := findSpending(t, db, 2, 1); spending == nil || spending.ID != second.ID || spending.Cost != 20 {
+		t.Errorf("Expected the spending of chat 2, got %+v", spending)
+	}
+	if spending := findSpending(t, db, 3, 1); spending != nil {
+		t.Errorf("Expected no spending in chat 3, got %+v", spending)
+	}
+
+	if _, err := db.CreateSpending(ctx, &models.Spending{ChatId: 1, MessageId: 1, Cost: 30, SpentAt: spentAt}); err == nil {
+		t.Error("Expected a second spending for the same message in the same chat to be rejected")
+	}
+
+	spendings, err := db.GetSpendingsByDateRange(ctx, spentAt, spentAt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	verifyCosts(t, spendings, []float64{10, 20})
+}
+
+func testDeleteSpending(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	spentAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	food := createTag(t, db, "food")
+	hash := "imported-hash"
+	spending := createSpending(t, db, &models.Spending{ChatId: -1, Cost: 30, SpentAt: spentAt, ImportHash: &hash})
+	if err := db.SyncSpendingTags(ctx, spending, &[]models.Tag{*food}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := db.SyncSpendingShares(ctx, spending, &[]models.SpendingShare{{MemberId: 1, Amount: 15}, {MemberId: 2, Amount: 15}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := db.CreateStatementMatch(ctx, &models.StatementMatch{ChatId: -1, SpendingId: spending.ID, LineId: "line-1", BookedAt: spentAt}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	found, err := db.FindSpending(ctx, spending.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if found == nil || found.Cost != 30 || len(found.Tags) != 1 || len(found.Shares) != 2 {
+		t.Fatalf("Expected the spending with its tag and shares, got %+v", found)
+	}
+
+	if err := db.DeleteSpending(ctx, found); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if found, _ := db.FindSpending(ctx, spending.ID); found != nil {
+		t.Errorf("Expected the spending to be deleted, got %+v", found)
+	}
+	if shared, _ := db.GetSharedSpendingsByChat(ctx, -1); len(shared) != 0 {
+		t.Errorf("Expected no shared spendings left, got %+v", shared)
+	}
+	if matches, _ := db.GetStatementMatchesByChat(ctx, -1); len(matches) != 0 {
+		t.Errorf("Expected the statement match to be deleted, got %+v", matches)
+	}
+
+	// The import hash is free again
+	createSpending(t, db, &models.Spending{ChatId: -1, Cost: 30, SpentAt: spentAt, ImportHash: &hash})
+}
+
+func testImportedSpendings(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	spentAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	first, second := "first-hash", "second-hash"
+	created := createSpending(t, db, &models.Spending{ChatId: 1, Cost: 10, SpentAt: spentAt, ImportHash: &first})
+	createSpending(t, db, &models.Spending{ChatId: 1, Cost: 20, SpentAt: spentAt, ImportHash: &second})
+	createSpending(t, db, &models.Spending{ChatId: 1, MessageId: 5, Cost: 30, SpentAt: spentAt})
+
+	spending, err := db.FindSpendingByImportHash(ctx, first)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if spending == nil || spending.ID != created.ID || spending.Cost != 10 {
+		t.Errorf("Expected the spending imported with the hash, got %+v", spending)
+	}
+
+	spending, err = db.FindSpendingByImportHash(ctx, "unknown-hash")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if spending != nil {
+		t.Errorf("Expected no spending for an unknown hash, got %+v", spending)
+	}
+}
+
+func testSpendingsWithoutMessage(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	spentAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	createSpending(t, db, &models.Spending{ChatId: 1, Cost: 10, SpentAt: spentAt})
+	createSpending(t, db, &models.Spending{ChatId: 1, Cost: 20, SpentAt: spentAt})
+
+	spendings, err := db.GetSpendingsByDateRange(ctx, spentAt, spentAt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(spendings) != 2 {
+		t.Errorf("Expected 2 spendings, got %d", len(spendings))
+	}
+}
+
+func testSyncSpendingTags(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	spentAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	food := createTag(t, db, "food")
+	groceries := createTag(t, db, "groceries")
+	weekly := createTag(t, db, "weekly")
+
+	spending := createSpending(t, db, &models.Spending{ChatId: 1, MessageId: 10, Cost: 25, SpentAt: spentAt})
+
+	tags := []models.Tag{*food, *groceries}
+	if err := db.SyncSpendingTags(ctx, spending, &tags); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	verifyTagNames(t, db, spentAt, []string{"food", "groceries"})
+
+	tags = []models.Tag{*groceries, *weekly}
+	if err := db.SyncSpendingTags(ctx, spending, &tags); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	verifyTagNames(t, db, spentAt, []string{"groceries", "weekly"})
+
+	tags = []models.Tag{}
+	if err := db.SyncSpendingTags(ctx, spending, &tags); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	verifyTagNames(t, db, spentAt, nil)
+}
+
+func testSpendingsByDateRange(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	startDate := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2024, 5, 31, 23, 59, 59, 0, time.UTC)
+
+	createSpending(t, db, &models.Spending{ChatId: 1, MessageId: 1, Cost: 1, SpentAt: startDate.Add(-time.Second)})
+	createSpending(t, db, &models.Spending{ChatId: 1, MessageId: 2, Cost: 2, SpentAt: startDate})
+	createSpending(t, db, &models.Spending{ChatId: 1, MessageId: 3, Cost: 3, SpentAt: endDate})
+	createSpending(t, db, &models.Spending{ChatId: 1, MessageId: 4, Cost: 4, SpentAt: endDate.Add(time.Second)})
+	createSpending(t, db, &models.Spending{ChatId: 2, MessageId: 5, Cost: 5, SpentAt: startDate.AddDate(0, 0, 14)})
+
+	spendings, err := db.GetSpendingsByDateRange(ctx, startDate, endDate)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	verifyCosts(t, spendings, []float64{2, 3, 5})
+
+	spendings, err = db.GetChatSpendingsByDateRange(ctx, 1, startDate, endDate)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	verifyCosts(t, spendings, []float64{2, 3})
+}
+
+func testSpendingsByDateRangeInOtherTimeZone(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	berlin := time.FixedZone("CEST", 2*60*60)
+	startDate := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2024, 5, 31, 23, 59, 59, 0, time.UTC)
+
+	// April 30th 23:00 UTC, although the local date is already in May
+	createSpending(t, db, &models.Spending{ChatId: 1, MessageId: 1, Cost: 1, SpentAt: time.Date(2024, 5, 1, 1, 0, 0, 0, berlin)})
+	// June 1st 00:30 local time, but still May 31st 22:30 UTC
+	createSpending(t, db, &models.Spending{ChatId: 1, MessageId: 2, Cost: 2, SpentAt: time.Date(2024, 6, 1, 0, 30, 0, 0, berlin)})
+
+	spendings, err := db.GetSpendingsByDateRange(ctx, startDate, endDate)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	verifyCosts(t, spendings, []float64{2})
+}
+
+func testFindSpendings(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	day := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	food := createTag(t, db, "food")
+	transport := createTag(t, db, "transport")
+
+	for i, spending := range []struct {
+		description string
+		cost        float64
+		tags        []models.Tag
+	}{
+		{"Groceries 40 #food", 40, []models.Tag{*food}},
+		{"Taxi home 60 #transport", 60, []models.Tag{*transport}},
+		{"Dinner and taxi 90 #food #transport", 90, []models.Tag{*food, *transport}},
+		{"Books 15", 15, nil},
+	} {
+		created := createSpending(t, db, &models.Spending{
+			ChatId: 1, MessageId: i + 1, Cost: spending.cost, Description: spending.description, SpentAt: day.AddDate(0, 0, i),
+		})
+		tags := spending.tags
+		if err := db.SyncSpendingTags(ctx, created, &tags); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	createSpending(t, db, &models.Spending{ChatId: 2, MessageId: 10, Cost: 100, Description: "Taxi 100", SpentAt: day})
+
+	tests := []struct {
+		name          string
+		filter        database.SpendingFilter
+		expectedCosts []float64
+		expectedTotal int64
+	}{
+		{
+			name:          "all of the chat, newest first",
+			filter:        database.SpendingFilter{ChatId: 1},
+			expectedCosts: []float64{15, 90, 60, 40},
+			expectedTotal: 4,
+		},
+		{
+			name:          "page",
+			filter:        database.SpendingFilter{ChatId: 1, Offset: 1, Limit: 2},
+			expectedCosts: []float64{90, 60},
+			expectedTotal: 4,
+		},
+		{
+			name:          "past the last page",
+			filter:        database.SpendingFilter{ChatId: 1, Offset: 4, Limit: 2},
+			expectedTotal: 4,
+		},
+		{
+			name:          "date range",
+			filter:        database.SpendingFilter{ChatId: 1, StartDate: day.AddDate(0, 0, 1), EndDate: day.AddDate(0, 0, 2)},
+			expectedCosts: []float64{90, 60},
+			expectedTotal: 2,
+		},
+		{
+			name:          "text ignoring case",
+			filter:        database.SpendingFilter{ChatId: 1, Text: "TAXI"},
+			expectedCosts: []float64{90, 60},
+			expectedTotal: 2,
+		},
+		{
+			name:          "text with wildcards",
+			filter:        database.SpendingFilter{ChatId: 1, Text: "T_xi"},
+			expectedTotal: 0,
+		},
+		{
+			name:          "text with a percent sign",
+			filter:        database.SpendingFilter{ChatId: 1, Text: "%"},
+			expectedTotal: 0,
+		},
+		{
+			name:          "all tags",
+			filter:        database.SpendingFilter{ChatId: 1, Tags: []string{"food", "transport"}},
+			expectedCosts: []float64{90},
+			expectedTotal: 1,
+		},
+		{
+			name: "cost range",
+			filter: database.SpendingFilter{ChatId: 1, Costs: []database.CostCondition{
+				{Operator: ">", Amount: 40},
+				{Operator: "<=", Amount: 90},
+			}},
+			expectedCosts: []float64{90, 60},
+			expectedTotal: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spendings, total, err := db.FindSpendings(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if total != tt.expectedTotal {
+				t.Errorf("Expected %d matching spendings, got %d", tt.expectedTotal, total)
+			}
+
+			var costs []float64
+			for _, spending := range spendings {
+				costs = append(costs, spending.Cost)
+			}
+			if len(costs) != len(tt.expectedCosts) {
+				t.Fatalf("Expected costs %v, got %v", tt.expectedCosts, costs)
+			}
+			for i := range costs {
+				if costs[i] != tt.expectedCosts[i] {
+					t.Errorf("Expected costs %v in order, got %v", tt.expectedCosts, costs)
+					break
+				}
+			}
+		})
+	}
+
+	spendings, _, _ := db.FindSpendings(ctx, database.SpendingFilter{ChatId: 1, Tags: []string{"food", "transport"}})
+	if len(spendings) == 1 && len(spendings[0].Tags) != 2 {
+		t.Errorf("Expected the tags to be loaded, got %+v", spendings[0].Tags)
+	}
+}
+
+func testSearchSpendings(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	day := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	transport := createTag(t, db, "transport")
+
+	for i, description := range []string{
+		"Taxi home 60",
+		"Dinner and taxi 90",
+		"Taxis to the airport 45",
+		"Books 15",
+	} {
+		created := createSpending(t, db, &models.Spending{ChatId: 1, MessageId: i + 1, Cost: float64(i + 1), Description: description, SpentAt: day})
+		if strings.Contains(description, "airport") {
+			tags := []models.Tag{*transport}
+			if err := db.SyncSpendingTags(ctx, created, &tags); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+	}
+	createSpending(t, db, &models.Spending{ChatId: 2, MessageId: 10, Cost: 10, Description: "Taxi 100", SpentAt: day})
+
+	tests := []struct {
+		name          string
+		filter        database.SpendingFilter
+		expectedCosts []float64
+	}{
+		{"word", database.SpendingFilter{ChatId: 1, Text: "taxi"}, []float64{1, 2, 3}},
+		{"start of a word ignoring case", database.SpendingFilter{ChatId: 1, Text: "AIR"}, []float64{3}},
+		{"all words in any order", database.SpendingFilter{ChatId: 1, Text: "taxi dinner"}, []float64{2}},
+		{"special characters", database.SpendingFilter{ChatId: 1, Text: `"books" OR`}, nil},
+		{"wildcards", database.SpendingFilter{ChatId: 1, Text: "t_xi"}, nil},
+		{"tags", database.SpendingFilter{ChatId: 1, Text: "taxi", Tags: []string{"transport"}}, []float64{3}},
+		{"all chats", database.SpendingFilter{Text: "taxi"}, []float64{1, 2, 3, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spendings, total, err := db.SearchSpendings(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if total != int64(len(tt.expectedCosts)) {
+				t.Errorf("Expected %d matching spendings, got %d", len(tt.expectedCosts), total)
+			}
+			verifyCosts(t, spendings, tt.expectedCosts)
+		})
+	}
+}
+
+func testTransaction(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	spentAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	errFailed := errors.New("failed")
+
+	err := db.Transaction(ctx, func(tx database.DatabaseClient) error {
+		if _, err := tx.CreateTag(ctx, &models.Tag{Name: "food"}); err != nil {
+			return err
+		}
+		createSpending(t, tx, &models.Spending{ChatId: 1, MessageId: 1, Cost: 10, SpentAt: spentAt})
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("Expected the transaction's error, got %v", err)
+	}
+
+	if spending := findSpending(t, db, 1, 1); spending != nil {
+		t.Error("Expected the spending to be rolled back")
+	}
+	if tag, _ := db.FindTagByName(ctx, "food"); tag != nil {
+		t.Error("Expected the tag to be rolled back")
+	}
+
+	err = db.Transaction(ctx, func(tx database.DatabaseClient) error {
+		createSpending(t, tx, &models.Spending{ChatId: 1, MessageId: 2, Cost: 20, SpentAt: spentAt})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if spending := findSpending(t, db, 1, 2); spending == nil {
+		t.Error("Expected the spending to be committed")
+	}
+}
+
+func testSpendingShares(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	spentAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	shared := createSpending(t, db, &models.Spending{ChatId: 1, MessageId: 1, Cost: 30, SpentAt: spentAt})
+	createSpending(t, db, &models.Spending{ChatId: 1, MessageId: 2, Cost: 10, SpentAt: spentAt})
+
+	shares := []models.SpendingShare{{MemberId: 1, Amount: 15}, {MemberId: 2, Amount: 15}}
+	if err := db.SyncSpendingShares(ctx, shared, &shares); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	spendings, err := db.GetSharedSpendingsByChat(ctx, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(spendings) != 1 || spendings[0].ID != shared.ID || len(spendings[0].Shares) != 2 {
+		t.Fatalf("Expected the shared spending with 2 shares, got %+v", spendings)
+	}
+	for _, share := range spendings[0].Shares {
+		if share.SpendingId != shared.ID || share.Amount != 15 {
+			t.Errorf("Unexpected share %+v", share)
+		}
+	}
+
+	if spendings, _ := db.GetSharedSpendingsByChat(ctx, 2); len(spendings) != 0 {
+		t.Errorf("Expected no shared spendings in another chat, got %d", len(spendings))
+	}
+
+	shares = []models.SpendingShare{}
+	if err := db.SyncSpendingShares(ctx, shared, &shares); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if spendings, _ := db.GetSharedSpendingsByChat(ctx, 1); len(spendings) != 0 {
+		t.Errorf("Expected no shared spendings after removing the shares, got %d", len(spendings))
+	}
+}
+
+func testChatMembers(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+
+	alice, err := db.CreateChatMember(ctx, &models.ChatMember{ChatId: 1, UserId: 10, UserName: "Alice"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := db.CreateChatMember(ctx, &models.ChatMember{ChatId: 1, UserId: 11, UserName: "bob"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := db.CreateChatMember(ctx, &models.ChatMember{ChatId: 2, UserId: 10, UserName: "Alice"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	member, err := db.FindChatMemberByUserName(ctx, 1, "alice")
+	if err != nil || member == nil || member.ID != alice.ID {
+		t.Fatalf("Expected to find alice case-insensitively, got %+v (%v)", member, err)
+	}
+
+	member, err = db.FindChatMemberByUserId(ctx, 1, 10)
+	if err != nil || member == nil || member.ID != alice.ID {
+		t.Fatalf("Expected to find alice by user ID, got %+v (%v)", member, err)
+	}
+
+	if member, _ := db.FindChatMemberByUserId(ctx, 1, 12); member != nil {
+		t.Errorf("Expected no member, got %+v", member)
+	}
+
+	member.DisplayName = "Alice Smith"
+	if err := db.UpdateChatMember(ctx, member); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if member, _ := db.FindChatMemberByUserId(ctx, 1, 10); member.DisplayName != "Alice Smith" {
+		t.Errorf("Expected updated display name, got %q", member.DisplayName)
+	}
+
+	members, err := db.GetChatMembers(ctx, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(members) != 2 || members[0].UserName != "Alice" || members[1].UserName != "bob" {
+		t.Errorf("Expected alice and bob in order, got %+v", members)
+	}
+}
+
+func testSettlements(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+
+	settlement, err := db.CreateSettlement(ctx, &models.Settlement{ChatId: 1, FromMemberId: 1, ToMemberId: 2, Amount: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if settlement.ID == 0 {
+		t.Error("Expected the created settlement to have an ID")
+	}
+
+	settlements, err := db.GetSettlementsByChat(ctx, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(settlements) != 1 || settlements[0].Amount != 10 {
+		t.Errorf("Expected one settlement of 10, got %+v", settlements)
+	}
+
+	if settlements, _ := db.GetSettlementsByChat(ctx, 2); len(settlements) != 0 {
+		t.Errorf("Expected no settlements in another chat, got %d", len(settlements))
+	}
+
+	// Settlements are found by the chat and message they were recorded from
+	if _, err := db.CreateSettlement(ctx, &models.Settlement{ChatId: 1, MessageId: 7, FromMemberId: 1, ToMemberId: 2, Amount: 20}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := db.CreateSettlement(ctx, &models.Settlement{ChatId: 2, MessageId: 7, FromMemberId: 3, ToMemberId: 4, Amount: 30}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	found, err := db.FindSettlementByMessageId(ctx, 2, 7)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if found == nil || found.Amount != 30 {
+		t.Errorf("Expected the settlement of 30 in chat 2, got %+v", found)
+	}
+	if found, _ := db.FindSettlementByMessageId(ctx, 3, 7); found != nil {
+		t.Errorf("Expected no settlement in chat 3, got %+v", found)
+	}
+	if _, err := db.CreateSettlement(ctx, &models.Settlement{ChatId: 1, MessageId: 7, FromMemberId: 1, ToMemberId: 2, Amount: 20}); err == nil {
+		t.Error("Expected a second settlement for the same message to be rejected")
+	}
+}
+
+func testStatementMatches(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	bookedAt := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	for _, match := range []*models.StatementMatch{
+		{ChatId: 1, SpendingId: 2, LineId: "line-2", Amount: -20, BookedAt: bookedAt.AddDate(0, 0, 1)},
+		{ChatId: 1, SpendingId: 1, LineId: "line-1", Amount: -10, BookedAt: bookedAt},
+		{ChatId: 2, SpendingId: 3, LineId: "line-1", Amount: -30, BookedAt: bookedAt},
+	} {
+		created, err := db.CreateStatementMatch(ctx, match)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if created.ID == 0 {
+			t.Error("Expected the created match to have an ID")
+		}
+	}
+
+	matches, err := db.GetStatementMatchesByChat(ctx, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(matches) != 2 || matches[0].LineId != "line-1" || matches[1].LineId != "line-2" {
+		t.Errorf("Expected the matches of lines 1 and 2 in order, got %+v", matches)
+	}
+
+	for _, duplicate := range []*models.StatementMatch{
+		{ChatId: 1, SpendingId: 4, LineId: "line-1", BookedAt: bookedAt},
+		{ChatId: 1, SpendingId: 1, LineId: "line-3", BookedAt: bookedAt},
+	} {
+		if _, err := db.CreateStatementMatch(ctx, duplicate); err == nil {
+			t.Errorf("Expected matching line %s or spending %d again to fail", duplicate.LineId, duplicate.SpendingId)
+		}
+	}
+}
+
+func testAPITokens(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+
+	for _, token := range []*models.APIToken{
+		{ChatId: 1, UserId: 10, Scope: models.APITokenScopeRead, TokenHash: "hash-1"},
+		{ChatId: 1, UserId: 10, Scope: models.APITokenScopeWrite, TokenHash: "hash-2"},
+		{ChatId: 2, UserId: 20, Scope: models.APITokenScopeRead, TokenHash: "hash-3"},
+	} {
+		created, err := db.CreateAPIToken(ctx, token)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if created.ID == 0 {
+			t.Error("Expected the created token to have an ID")
+		}
+	}
+	if _, err := db.CreateAPIToken(ctx, &models.APIToken{ChatId: 2, TokenHash: "hash-1"}); err == nil {
+		t.Error("Expected creating a token with the hash of another one to fail")
+	}
+
+	token, err := db.FindAPITokenByHash(ctx, "hash-2")
+	if err != nil || token == nil || token.ChatId != 1 || token.Scope != models.APITokenScopeWrite {
+		t.Fatalf("Expected the write token of chat 1, got %+v (%v)", token, err)
+	}
+	if found, err := db.FindAPIToken(ctx, token.ID); err != nil || found == nil || found.TokenHash != "hash-2" {
+		t.Errorf("Expected to find the token by its ID, got %+v (%v)", found, err)
+	}
+	if found, err := db.FindAPITokenByHash(ctx, "unknown"); err != nil || found != nil {
+		t.Errorf("Expected no token for an unknown hash, got %+v (%v)", found, err)
+	}
+
+	tokens, err := db.GetAPITokensByChat(ctx, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tokens) != 2 || tokens[0].TokenHash != "hash-1" || tokens[1].TokenHash != "hash-2" {
+		t.Errorf("Expected the two tokens of chat 1 in order, got %+v", tokens)
+	}
+
+	if err := db.DeleteAPIToken(ctx, token); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if found, _ := db.FindAPITokenByHash(ctx, "hash-2"); found != nil {
+		t.Errorf("Expected the deleted token not to be found, got %+v", found)
+	}
+	if found, _ := db.FindAPIToken(ctx, token.ID); found != nil {
+		t.Errorf("Expected the deleted token not to be found by its ID, got %+v", found)
+	}
+	if tokens, _ := db.GetAPITokensByChat(ctx, 1); len(tokens) != 1 || tokens[0].TokenHash != "hash-1" {
+		t.Errorf("Expected only the remaining token of chat 1, got %+v", tokens)
+	}
+}
+
+func testDashboardSessions(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	expiresAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	session, err := db.CreateDashboardSession(ctx, &models.DashboardSession{ChatId: 1, UserId: 10, TokenHash: "link-hash", ExpiresAt: expiresAt})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if session.ID == 0 {
+		t.Error("Expected the created session to have an ID")
+	}
+	if _, err := db.CreateDashboardSession(ctx, &models.DashboardSession{ChatId: 2, TokenHash: "link-hash"}); err == nil {
+		t.Error("Expected creating a session with the hash of another one to fail")
+	}
+
+	found, err := db.FindDashboardSessionByHash(ctx, "link-hash")
+	if err != nil || found == nil || found.ChatId != 1 || found.LoggedInAt != nil || !found.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("Expected the login link of chat 1, got %+v (%v)", found, err)
+	}
+
+	loggedInAt := expiresAt.Add(-time.Minute)
+	found.TokenHash = "session-hash"
+	found.LoggedInAt = &loggedInAt
+	found.ExpiresAt = expiresAt.AddDate(0, 0, 30)
+	if err := db.UpdateDashboardSession(ctx, found); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if link, _ := db.FindDashboardSessionByHash(ctx, "link-hash"); link != nil {
+		t.Errorf("Expected the used login link not to be found, got %+v", link)
+	}
+	found, err = db.FindDashboardSessionByHash(ctx, "session-hash")
+	if err != nil || found == nil || found.LoggedInAt == nil || !found.LoggedInAt.Equal(loggedInAt) || !found.ExpiresAt.Equal(expiresAt.AddDate(0, 0, 30)) {
+		t.Fatalf("Expected the session to be updated, got %+v (%v)", found, err)
+	}
+
+	if err := db.DeleteDashboardSession(ctx, found); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if deleted, _ := db.FindDashboardSessionByHash(ctx, "session-hash"); deleted != nil {
+		t.Errorf("Expected the deleted session not to be found, got %+v", deleted)
+	}
+}
+
+func testBudgets(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+
+	for _, budget := range []*models.Budget{
+		{ChatId: 1, TagName: "transport", Amount: 50, Period: models.BudgetPeriodWeekly},
+		{ChatId: 1, TagName: "food", Amount: 100, Period: models.BudgetPeriodMonthly},
+		{ChatId: 2, TagName: "food", Amount: 200, Period: models.BudgetPeriodMonthly},
+	} {
+		if _, err := db.CreateBudget(ctx, budget); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	budget, err := db.FindBudget(ctx, 1, "food")
+	if err != nil || budget == nil || budget.Amount != 100 {
+		t.Fatalf("Expected the food budget of chat 1, got %+v (%v)", budget, err)
+	}
+
+	budget.Amount = 120
+	if err := db.UpdateBudget(ctx, budget); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	budgets, err := db.GetBudgetsByChat(ctx, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(budgets) != 2 || budgets[0].TagName != "food" || budgets[0].Amount != 120 || budgets[1].TagName != "transport" {
+		t.Errorf("Expected food and transport budgets sorted by tag, got %+v", budgets)
+	}
+
+	if err := db.DeleteBudget(ctx, budget); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if budget, _ := db.FindBudget(ctx, 1, "food"); budget != nil {
+		t.Errorf("Expected the budget to be deleted, got %+v", budget)
+	}
+	if budget, _ := db.FindBudget(ctx, 2, "food"); budget == nil {
+		t.Error("Expected the budget of another chat to be kept")
+	}
+}
+
+func testRecurringSpendings(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+	startsAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	rent, err := db.CreateRecurringSpending(ctx, &models.RecurringSpending{
+		ChatId: 1, Name: "Rent", Cost: 900, Frequency: models.RecurrenceMonthly, Day: 1, StartsAt: startsAt,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := db.CreateRecurringSpending(ctx, &models.RecurringSpending{
+		ChatId: 2, Name: "Gym", Cost: 30, Frequency: models.RecurrenceMonthly, Day: 15, StartsAt: startsAt,
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tags := []models.Tag{*createTag(t, db, "housing")}
+	if err := db.SyncRecurringSpendingTags(ctx, rent, &tags); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	found, err := db.FindRecurringSpending(ctx, rent.ID)
+	if err != nil || found == nil || found.Name != "Rent" {
+		t.Fatalf("Expected to find the rent, got %+v (%v)", found, err)
+	}
+
+	recurringSpendings, err := db.GetRecurringSpendingsByChat(ctx, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(recurringSpendings) != 1 || len(recurringSpendings[0].Tags) != 1 || recurringSpendings[0].Tags[0].Name != "housing" {
+		t.Fatalf("Expected the rent with its tag, got %+v", recurringSpendings)
+	}
+
+	occurrence := createSpending(t, db, &models.Spending{ChatId: 1, Cost: 900, SpentAt: startsAt, RecurringSpendingId: &rent.ID})
+
+	spending, err := db.FindSpendingByRecurrence(ctx, rent.ID, startsAt, startsAt.AddDate(0, 1, 0).Add(-time.Second))
+	if err != nil || spending == nil || spending.ID != occurrence.ID {
+		t.Fatalf("Expected to find the occurrence, got %+v (%v)", spending, err)
+	}
+	if spending, _ := db.FindSpendingByRecurrence(ctx, rent.ID, startsAt.AddDate(0, 1, 0), startsAt.AddDate(0, 2, 0)); spending != nil {
+		t.Errorf("Expected no occurrence next month, got %+v", spending)
+	}
+
+	if err := db.DeleteRecurringSpending(ctx, found); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if found, _ := db.FindRecurringSpending(ctx, rent.ID); found != nil {
+		t.Errorf("Expected the rent to be deleted, got %+v", found)
+	}
+
+	recurringSpendings, err = db.GetRecurringSpendings(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(recurringSpendings) != 1 || recurringSpendings[0].Name != "Gym" {
+		t.Errorf("Expected only the gym to be left, got %+v", recurringSpendings)
+	}
+}
+
+func testLastUpdateId(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+
+	for _, expected := range []int{0, 5, 7} {
+		if expected > 0 {
+			if err := db.SaveLastUpdateId(ctx, expected); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		updateID, err := db.GetLastUpdateId(ctx)
+		if err != nil || updateID != expected {
+			t.Errorf("Expected last update ID %d, got %d (%v)", expected, updateID, err)
+		}
+	}
+}
+
+func testAllowlist(t *testing.T, db database.DatabaseClient) {
+	ctx := context.Background()
+
+	if _, err := db.CreateAllowlistEntry(ctx, &models.AllowlistEntry{Kind: models.AllowlistKindUser, EntityId: 10}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	entry, err := db.FindAllowlistEntry(ctx, models.AllowlistKindUser, 10)
+	if err != nil || entry == nil {
+		t.Fatalf("Expected to find the entry, got %+v (%v)", entry, err)
+	}
+	if entry, _ := db.FindAllowlistEntry(ctx, models.AllowlistKindChat, 10); entry != nil {
+		t.Errorf("Expected no chat entry, got %+v", entry)
+	}
+
+	if err := db.DeleteAllowlistEntry(ctx, entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if entry, _ := db.FindAllowlistEntry(ctx, models.AllowlistKindUser, 10); entry != nil {
+		t.Errorf("Expected the entry to be deleted, got %+v", entry)
+	}
+
+	// Deleted entries can be added again
+	if _, err := db.CreateAllowlistEntry(ctx, &models.AllowlistEntry{Kind: models.AllowlistKindUser, EntityId: 10}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func createTag(t *testing.T, db database.DatabaseClient, name string) *models.Tag {
+	t.Helper()
+
+	tag, err := db.CreateTag(context.Background(), &models.Tag{Name: name})
+	if err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	return tag
+}
+
+func createSpending(t *testing.T, db database.DatabaseClient, spending *models.Spending) *models.Spending {
+	t.Helper()
+
+	created, err := db.CreateSpending(context.Background(), spending)
+	if err != nil {
+		t.Fatalf("Failed to create spending: %v", err)
+	}
+	return created
+}
+
+func findSpending(t *testing.T, db database.DatabaseClient, chatID int64, messageID int) *models.Spending {
+	t.Helper()
+
+	spending, err := db.FindSpendingByMessageId(context.Background(), chatID, messageID)
+	if err != nil {
+		t.Fatalf("Failed to find spending: %v", err)
+	}
+	return spending
+}
+
+// verifyTagNames checks the tags of the only spending at spentAt.
+func verifyTagNames(t *testing.T, db database.DatabaseClient, spentAt time.Time, expected []string) {
+	t.Helper()
+
+	spendings, err := db.GetSpendingsByDateRange(context.Background(), spentAt, spentAt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(spendings) != 1 {
+		t.Fatalf("Expected 1 spending, got %d", len(spendings))
+	}
+
+	var names []string
+	for _, tag := range spendings[0].Tags {
+		names = append(names, tag.Name)
+	}
+	sort.Strings(names)
+
+	if len(names) != len(expected) {
+		t.Fatalf("Expected tags %v, got %v", expected, names)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Errorf("Expected tags %v, got %v", expected, names)
+		}
+	}
+}
+
+// verifyCosts checks the costs of spendings regardless of their order.
+func verifyCosts(t *testing.T, spendings []models.Spending, expected []float64) {
+	t.Helper()
+
+	var costs []float64
+	for _, spending := range spendings {
+		costs = append(costs, spending.Cost)
+	}
+	sort.Float64s(costs)
+
+	if len(costs) != len(expected) {
+		t.Fatalf("Expected costs %v, got %v", expected, costs)
+	}
+	for i := range costs {
+		if costs[i] != expected[i] {
+			t.Errorf("Expected costs %v, got %v", expected, costs)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kiasaty/spendings-tracker/models"
+	"gorm.io/gorm"
+)
+
+func (c *Client) CreateDashboardSession(ctx context.Context, session *models.DashboardSession) (*models.DashboardSession, error) {
+	result := c.DB.WithContext(ctx).Create(&session)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return session, nil
+}
+
+func (c *Client) UpdateDashboardSession(ctx context.Context, session *models.DashboardSession) error {
+	result := c.DB.WithContext(ctx).Save(&session)
+
+	return result.Error
+}
+
+func (c *Client) DeleteDashboardSession(ctx context.Context, session *models.DashboardSession) error {
+	return c.DB.WithContext(ctx).Delete(session).Error
+}
+
+func (c *Client) FindDashboardSessionByHash(ctx context.Context, tokenHash string) (*models.DashboardSession, error) {
+	var session models.DashboardSession
+	err := c.DB.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&session).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find dashboard session by hash: %w", err)
+	}
+	return &session, nil
+}
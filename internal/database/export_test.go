@@ -0,0 +1,4 @@
+package database
+
+// NewTestClient is newTestClient for the tests of the database_test package.
+var NewTestClient = newTestClient
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationInitialSchema creates the schema as it was set up with AutoMigrate
+// before versioned migrations were introduced. Databases created that way
+// already have all of it, in which case nothing changes.
+var migrationInitialSchema = migration{
+	Version: 1,
+	Name:    "initial_schema",
+	Up: func(tx *gorm.DB) error {
+		type Tag struct {
+			gorm.Model
+			Name string `gorm:"size:255"`
+		}
+
+		type SpendingShare struct {
+			gorm.Model
+			SpendingId uint
+			MemberId   uint
+			Amount     float64
+		}
+
+		type Spending struct {
+			gorm.Model
+			ChatId              int64
+			MessageId           int
+			SenderId            int64
+			SenderName          string
+			Cost                float64
+			Description         string
+			SpentAt             time.Time
+			RecurringSpendingId *uint
+			Tags                []Tag `gorm:"many2many:spending_tag;"`
+			Shares              []SpendingShare
+		}
+
+		type AllowlistEntry struct {
+			gorm.Model
+			Kind     string `gorm:"size:255"`
+			EntityId int64
+		}
+
+		type ChatMember struct {
+			gorm.Model
+			ChatId      int64
+			UserId      int64
+			UserName    string
+			DisplayName string
+		}
+
+		type Settlement struct {
+			gorm.Model
+			ChatId       int64
+			MessageId    int
+			FromMemberId uint
+			ToMemberId   uint
+			Amount       float64
+			SettledAt    time.Time
+		}
+
+		type Budget struct {
+			gorm.Model
+			ChatId  int64
+			TagName string
+			Amount  float64
+			Period  string
+		}
+
+		type RecurringSpending struct {
+			gorm.Model
+			ChatId     int64
+			SenderId   int64
+			SenderName string
+			Name       string
+			Cost       float64
+			Frequency  string
+			Day        int
+			StartsAt   time.Time
+			Tags       []Tag `gorm:"many2many:recurring_spending_tag;"`
+		}
+
+		type TelegramState struct {
+			gorm.Model
+			LastUpdateId int
+		}
+
+		return tx.AutoMigrate(
+			&Tag{},
+			&Spending{},
+			&AllowlistEntry{},
+			&ChatMember{},
+			&SpendingShare{},
+			&Settlement{},
+			&Budget{},
+			&RecurringSpending{},
+			&TelegramState{},
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(
+			"recurring_spending_tag",
+			"spending_tag",
+			"spending_shares",
+			"telegram_states",
+			"recurring_spendings",
+			"budgets",
+			"settlements",
+			"chat_members",
+			"allowlist_entries",
+			"spendings",
+			"tags",
+		)
+	},
+}
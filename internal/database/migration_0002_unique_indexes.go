@@ -0,0 +1,99 @@
+package database
+
+import "gorm.io/gorm"
+
+// migrationUniqueIndexes enforces uniqueness the code relied on so far:
+// one tag per name, one allowlist entry per user or chat, and one spending per
+// recurring spending occurrence. Duplicate tags and allowlist entries created
+// before are merged first.
+var migrationUniqueIndexes = migration{
+	Version: 2,
+	Name:    "unique_indexes",
+	Up: func(tx *gorm.DB) error {
+		if err := mergeDuplicateTags(tx); err != nil {
+			return err
+		}
+
+		err := tx.Exec(`DELETE FROM allowlist_entries WHERE id NOT IN (
+			SELECT id FROM (SELECT MIN(id) AS id FROM allowlist_entries GROUP BY kind, entity_id) AS kept
+		)`).Error
+		if err != nil {
+			return err
+		}
+
+		statements := []string{
+			"CREATE UNIQUE INDEX idx_tags_name ON tags (name)",
+			"CREATE UNIQUE INDEX idx_allowlist_entries_kind_entity_id ON allowlist_entries (kind, entity_id)",
+			"CREATE UNIQUE INDEX idx_spendings_recurrence ON spendings (recurring_spending_id, spent_at)",
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		indexes := map[string]string{
+			"idx_spendings_recurrence":             "spendings",
+			"idx_allowlist_entries_kind_entity_id": "allowlist_entries",
+			"idx_tags_name":                        "tags",
+		}
+		for name, table := range indexes {
+			if err := tx.Migrator().DropIndex(table, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// mergeDuplicateTags moves the spendings and recurring spendings of tags with
+// the same name to the oldest of them and deletes the others.
+func mergeDuplicateTags(tx *gorm.DB) error {
+	var duplicates []struct {
+		ID   uint
+		Name string
+	}
+	err := tx.Raw(`SELECT id, name FROM tags WHERE id NOT IN (
+		SELECT MIN(id) FROM tags GROUP BY name
+	)`).Scan(&duplicates).Error
+	if err != nil {
+		return err
+	}
+
+	joinTables := map[string]string{
+		"spending_tag":           "spending_id",
+		"recurring_spending_tag": "recurring_spending_id",
+	}
+
+	for _, duplicate := range duplicates {
+		var keptID uint
+		err := tx.Raw("SELECT MIN(id) FROM tags WHERE name = ?", duplicate.Name).Scan(&keptID).Error
+		if err != nil {
+			return err
+		}
+
+		for table, column := range joinTables {
+			// Drop links that would exist twice after moving them
+			err := tx.Exec(
+				"DELETE FROM "+table+" WHERE tag_id = ? AND "+column+" IN (SELECT "+column+" FROM (SELECT "+column+" FROM "+table+" WHERE tag_id = ?) AS kept)",
+				duplicate.ID, keptID,
+			).Error
+			if err != nil {
+				return err
+			}
+
+			err = tx.Exec("UPDATE "+table+" SET tag_id = ? WHERE tag_id = ?", keptID, duplicate.ID).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("DELETE FROM tags WHERE id = ?", duplicate.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationUTCSpendingTimes converts the spending times SQLite stored with
+// the offset of the server's time zone to UTC, the way spendings are stored
+// now. SQLite compares times as text, so date ranges only match correctly
+// when all of them use the same offset. Other databases store times
+// independently of the offset and are left alone.
+var migrationUTCSpendingTimes = migration{
+	Version: 3,
+	Name:    "utc_spending_times",
+	Up: func(tx *gorm.DB) error {
+		if tx.Dialector.Name() != "sqlite" {
+			return nil
+		}
+
+		var rows []struct {
+			ID      uint
+			SpentAt time.Time
+		}
+		err := tx.Table("spendings").Select("id, spent_at").Where("spent_at NOT LIKE ?", "%+00:00").Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			err := tx.Table("spendings").Where("id = ?", row.ID).Update("spent_at", row.SpentAt.UTC()).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		// The times still describe the same instants, so there is nothing to
+		// revert
+		return nil
+	},
+}
@@ -0,0 +1,61 @@
+package database
+
+import "gorm.io/gorm"
+
+// migrationSpendingsSearch adds the FTS5 index of spending descriptions
+// searched by SearchSpendings, kept in sync by triggers.
+//
+// Nothing changes when the SQLite driver is built without FTS5 or another
+// database is used; searches then match descriptions with LIKE. To add the
+// index later, revert this migration and apply it again with a build that
+// supports FTS5. Once the index exists, every build using the database needs
+// FTS5 support, since the triggers fail without it.
+var migrationSpendingsSearch = migration{
+	Version: 4,
+	Name:    "spendings_search",
+	Up: func(tx *gorm.DB) error {
+		if !fullTextSearchSupported(tx) {
+			return nil
+		}
+
+		statements := []string{
+			`CREATE VIRTUAL TABLE spendings_fts USING fts5(description, content='spendings', content_rowid='id')`,
+			`CREATE TRIGGER spendings_fts_insert AFTER INSERT ON spendings BEGIN
+				INSERT INTO spendings_fts (rowid, description) VALUES (new.id, new.description);
+			END`,
+			`CREATE TRIGGER spendings_fts_delete AFTER DELETE ON spendings BEGIN
+				INSERT INTO spendings_fts (spendings_fts, rowid, description) VALUES ('delete', old.id, old.description);
+			END`,
+			`CREATE TRIGGER spendings_fts_update AFTER UPDATE OF description ON spendings BEGIN
+				INSERT INTO spendings_fts (spendings_fts, rowid, description) VALUES ('delete', old.id, old.description);
+				INSERT INTO spendings_fts (rowid, description) VALUES (new.id, new.description);
+			END`,
+			// Index the existing spendings
+			`INSERT INTO spendings_fts (spendings_fts) VALUES ('rebuild')`,
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		if !tx.Migrator().HasTable(spendingsSearchTable) {
+			return nil
+		}
+
+		statements := []string{
+			"DROP TRIGGER IF EXISTS spendings_fts_update",
+			"DROP TRIGGER IF EXISTS spendings_fts_delete",
+			"DROP TRIGGER IF EXISTS spendings_fts_insert",
+			"DROP TABLE spendings_fts",
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,29 @@
+package database
+
+import "gorm.io/gorm"
+
+// migrationSpendingImportHashes adds the hash identifying spendings imported
+// from files. It is unique, so a row can't be imported twice even by
+// concurrent imports; spendings created from messages leave it empty.
+var migrationSpendingImportHashes = migration{
+	Version: 5,
+	Name:    "spending_import_hashes",
+	Up: func(tx *gorm.DB) error {
+		statements := []string{
+			"ALTER TABLE spendings ADD COLUMN import_hash varchar(64)",
+			"CREATE UNIQUE INDEX idx_spendings_import_hash ON spendings (import_hash)",
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Migrator().DropIndex("spendings", "idx_spendings_import_hash"); err != nil {
+			return err
+		}
+		return tx.Exec("ALTER TABLE spendings DROP COLUMN import_hash").Error
+	},
+}
@@ -0,0 +1,16 @@
+package database
+
+import "gorm.io/gorm"
+
+// migrationSpendingManualCorrections adds the flag marking spendings that
+// were corrected by hand, so reparsing their descriptions leaves them alone.
+var migrationSpendingManualCorrections = migration{
+	Version: 6,
+	Name:    "spending_manual_corrections",
+	Up: func(tx *gorm.DB) error {
+		return tx.Exec("ALTER TABLE spendings ADD COLUMN manually_corrected boolean NOT NULL DEFAULT false").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Exec("ALTER TABLE spendings DROP COLUMN manually_corrected").Error
+	},
+}
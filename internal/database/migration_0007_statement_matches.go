@@ -0,0 +1,43 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationStatementMatches adds the table of bank statement lines matched
+// with spendings. A line and a spending can only be matched once.
+var migrationStatementMatches = migration{
+	Version: 7,
+	Name:    "statement_matches",
+	Up: func(tx *gorm.DB) error {
+		type StatementMatch struct {
+			gorm.Model
+			ChatId      int64
+			SpendingId  uint
+			LineId      string `gorm:"size:255"`
+			Amount      float64
+			BookedAt    time.Time
+			Description string
+		}
+
+		if err := tx.Migrator().CreateTable(&StatementMatch{}); err != nil {
+			return err
+		}
+
+		statements := []string{
+			"CREATE UNIQUE INDEX idx_statement_matches_line ON statement_matches (chat_id, line_id)",
+			"CREATE UNIQUE INDEX idx_statement_matches_spending ON statement_matches (spending_id)",
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("statement_matches")
+	},
+}
@@ -0,0 +1,27 @@
+package database
+
+import "gorm.io/gorm"
+
+// migrationAPITokens adds the table of the API tokens of chats, looked up
+// by the hash of the token.
+var migrationAPITokens = migration{
+	Version: 8,
+	Name:    "api_tokens",
+	Up: func(tx *gorm.DB) error {
+		type APIToken struct {
+			gorm.Model
+			ChatId    int64
+			UserId    int64
+			Scope     string `gorm:"size:16"`
+			TokenHash string `gorm:"size:64"`
+		}
+
+		if err := tx.Migrator().CreateTable(&APIToken{}); err != nil {
+			return err
+		}
+		return tx.Exec("CREATE UNIQUE INDEX idx_api_tokens_token_hash ON api_tokens (token_hash)").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("api_tokens")
+	},
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationDashboardSessions adds the table of dashboard login links and
+// sessions, looked up by the hash of their token.
+var migrationDashboardSessions = migration{
+	Version: 9,
+	Name:    "dashboard_sessions",
+	Up: func(tx *gorm.DB) error {
+		type DashboardSession struct {
+			gorm.Model
+			ChatId     int64
+			UserId     int64
+			TokenHash  string `gorm:"size:64"`
+			ExpiresAt  time.Time
+			LoggedInAt *time.Time
+		}
+
+		if err := tx.Migrator().CreateTable(&DashboardSession{}); err != nil {
+			return err
+		}
+		return tx.Exec("CREATE UNIQUE INDEX idx_dashboard_sessions_token_hash ON dashboard_sessions (token_hash)").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("dashboard_sessions")
+	},
+}
@@ -0,0 +1,39 @@
+package database
+
+import "gorm.io/gorm"
+
+// migrationSpendingMessages makes the message a spending was created from
+// unique within its chat. Message IDs are only unique per chat, so the same
+// ID in two chats is two spendings. Spendings stored twice before, like when
+// an edit arrived while the message was still being handled, are merged into
+// the latest one, which has the text the message ended up with. Spendings not
+// created from a message have message ID 0 and are left out.
+//
+// MySQL has no partial indexes, so it indexes NULL instead of 0, which unique
+// indexes allow any number of times.
+var migrationSpendingMessages = migration{
+	Version: 10,
+	Name:    "spending_messages",
+	Up: func(tx *gorm.DB) error {
+		// The derived table lets MySQL delete from the table it reads
+		duplicates := `SELECT id FROM (SELECT id FROM spendings WHERE message_id <> 0 AND id NOT IN (
+			SELECT MAX(id) FROM spendings WHERE message_id <> 0 GROUP BY chat_id, message_id
+		)) AS duplicates`
+		for _, table := range []string{"spending_tag", "spending_shares", "statement_matches"} {
+			if err := tx.Exec("DELETE FROM " + table + " WHERE spending_id IN (" + duplicates + ")").Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("DELETE FROM spendings WHERE id IN (" + duplicates + ")").Error; err != nil {
+			return err
+		}
+
+		if tx.Dialector.Name() == "mysql" {
+			return tx.Exec("CREATE UNIQUE INDEX idx_spendings_chat_message ON spendings (chat_id, (NULLIF(message_id, 0)))").Error
+		}
+		return tx.Exec("CREATE UNIQUE INDEX idx_spendings_chat_message ON spendings (chat_id, message_id) WHERE message_id <> 0").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropIndex("spendings", "idx_spendings_chat_message")
+	},
+}
@@ -0,0 +1,33 @@
+package database
+
+import "gorm.io/gorm"
+
+// migrationSettlementMessages makes the /settle message a settlement was
+// recorded from unique within its chat, so handling the message again doesn't
+// record the repayment twice. Settlements recorded twice before are merged
+// into the first one. Settlements without a message have message ID 0 and are
+// left out.
+//
+// MySQL has no partial indexes, so it indexes NULL instead of 0, which unique
+// indexes allow any number of times.
+var migrationSettlementMessages = migration{
+	Version: 11,
+	Name:    "settlement_messages",
+	Up: func(tx *gorm.DB) error {
+		// The derived table lets MySQL delete from the table it reads
+		err := tx.Exec(`DELETE FROM settlements WHERE message_id <> 0 AND id NOT IN (
+			SELECT id FROM (SELECT MIN(id) AS id FROM settlements WHERE message_id <> 0 GROUP BY chat_id, message_id) AS kept
+		)`).Error
+		if err != nil {
+			return err
+		}
+
+		if tx.Dialector.Name() == "mysql" {
+			return tx.Exec("CREATE UNIQUE INDEX idx_settlements_chat_message ON settlements (chat_id, (NULLIF(message_id, 0)))").Error
+		}
+		return tx.Exec("CREATE UNIQUE INDEX idx_settlements_chat_message ON settlements (chat_id, message_id) WHERE message_id <> 0").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropIndex("settlements", "idx_settlements_chat_message")
+	},
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migration is a numbered schema change. Up applies it and Down reverts it;
+// both run inside a transaction together with the bookkeeping in
+// schema_migrations.
+//
+// Migrations must never change once released: they describe the schema as it
+// was at that version, so they don't use the types of the models package.
+type migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// migrations lists every schema change in the order they are applied.
+var migrations = []migration{
+	migrationInitialSchema,
+	migrationUniqueIndexes,
+	migrationUTCSpendingTimes,
+	migrationSpendingsSearch,
+	migrationSpendingImportHashes,
+	migrationSpendingManualCorrections,
+	migrationStatementMatches,
+	migrationAPITokens,
+	migrationDashboardSessions,
+	migrationSpendingMessages,
+	migrationSettlementMessages,
+}
+
+// SchemaMigration records a migration applied to the database.
+type SchemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// MigrationStatus describes a migration and whether it has been applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// MigrateUp applies all pending migrations in order and returns the ones that
+// were applied. It stops at the first failing migration, whose changes are
+// rolled back.
+func (c *Client) MigrateUp(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := c.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		record := SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}
+		err := c.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&record).Error
+		})
+		if err != nil {
+			return statuses, fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Name, err)
+		}
+
+		statuses = append(statuses, MigrationStatus{Version: m.Version, Name: m.Name, AppliedAt: &record.AppliedAt})
+	}
+
+	return statuses, nil
+}
+
+// MigrateDown reverts the latest applied migration and returns it, or nil
+// when no migration has been applied.
+func (c *Client) MigrateDown(ctx context.Context) (*MigrationStatus, error) {
+	applied, err := c.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *SchemaMigration
+	for _, record := range applied {
+		if latest == nil || record.Version > latest.Version {
+			latest = &record
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+
+	m := findMigration(latest.Version)
+	if m == nil {
+		return nil, fmt.Errorf("migration %d (%s) is unknown to this version and can't be reverted", latest.Version, latest.Name)
+	}
+
+	err = c.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := m.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&SchemaMigration{}, latest.Version).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to revert migration %d (%s): %w", m.Version, m.Name, err)
+	}
+
+	return &MigrationStatus{Version: m.Version, Name: m.Name}, nil
+}
+
+// MigrationStatus returns all known migrations along with when they were
+// applied. Applied migrations this version doesn't know about are included
+// too.
+func (c *Client) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := c.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if record, ok := applied[m.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, record := range applied {
+		statuses = append(statuses, MigrationStatus{Version: record.Version, Name: record.Name, AppliedAt: &record.AppliedAt})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// appliedMigrations returns the applied migrations by version, creating the
+// schema_migrations table if it doesn't exist yet.
+func (c *Client) appliedMigrations(ctx context.Context) (map[int]SchemaMigration, error) {
+	db := c.DB.WithContext(ctx)
+
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		if err := db.Migrator().CreateTable(&SchemaMigration{}); err != nil {
+			return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+		}
+	}
+
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+
+	applied := make(map[int]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+func findMigration(version int) *migration {
+	for i := range migrations {
+		if migrations[i].Version == version {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
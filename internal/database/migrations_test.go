@@ -0,0 +1,313 @@
+package database
+
+import (
+	"context"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient connects to the database at TEST_DATABASE_URL, which takes
+// the same URLs as DATABASE_URL, or to an in-memory SQLite database when it
+// isn't set. All tables are dropped before and after the test, so it must
+// point at a throwaway database.
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		databaseURL = "sqlite://:memory:"
+	}
+
+	t.Setenv("DATABASE_URL", databaseURL)
+	databaseClient, err := NewDatabaseClient()
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	client := databaseClient.(*Client)
+
+	dropTables(t, client)
+	t.Cleanup(func() {
+		dropTables(t, client)
+		client.Close()
+	})
+
+	return client
+}
+
+func dropTables(t *testing.T, client *Client) {
+	t.Helper()
+
+	tables, err := client.DB.Migrator().GetTables()
+	if err != nil {
+		t.Fatalf("Failed to list tables: %v", err)
+	}
+
+	// Join tables reference the others, so they go first
+	sort.SliceStable(tables, func(i, j int) bool {
+		return strings.HasSuffix(tables[i], "_tag") && !strings.HasSuffix(tables[j], "_tag")
+	})
+	for _, table := range tables {
+		if err := client.DB.Migrator().DropTable(table); err != nil {
+			t.Fatalf("Failed to drop table %s: %v", table, err)
+		}
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	applied, err := client.MigrateUp(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("Expected %d migrations to be applied, got %d", len(migrations), len(applied))
+	}
+
+	applied, err = client.MigrateUp(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("Expected no pending migrations, got %v (%v)", applied, err)
+	}
+
+	statuses, err := client.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("Expected migration %d to be applied", status.Version)
+		}
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		reverted, err := client.MigrateDown(ctx)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if reverted == nil || reverted.Version != migrations[i].Version {
+			t.Fatalf("Expected migration %d to be reverted, got %v", migrations[i].Version, reverted)
+		}
+	}
+
+	if client.DB.Migrator().HasTable("spendings") {
+		t.Error("Expected spendings table to be dropped")
+	}
+
+	reverted, err := client.MigrateDown(ctx)
+	if err != nil || reverted != nil {
+		t.Errorf("Expected nothing to revert, got %v (%v)", reverted, err)
+	}
+}
+
+func TestUniqueIndexesMigrationMergesDuplicateTags(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	// Start from a database that only has the initial schema
+	if _, err := client.appliedMigrations(ctx); err != nil {
+		t.Fatalf("Failed to create schema_migrations table: %v", err)
+	}
+	if err := client.DB.Transaction(migrationInitialSchema.Up); err != nil {
+		t.Fatalf("Failed to create initial schema: %v", err)
+	}
+	if err := client.DB.Create(&SchemaMigration{Version: 1, Name: "initial_schema"}).Error; err != nil {
+		t.Fatalf("Failed to record migration: %v", err)
+	}
+
+	statements := []string{
+		"INSERT INTO tags (id, name) VALUES (1, 'food'), (2, 'food'), (3, 'rent')",
+		"INSERT INTO spendings (id, cost) VALUES (1, 10), (2, 20)",
+		"INSERT INTO spending_tag (spending_id, tag_id) VALUES (1, 1), (1, 2), (2, 2)",
+		"INSERT INTO allowlist_entries (id, kind, entity_id) VALUES (1, 'user', 5), (2, 'user', 5)",
+	}
+	for _, statement := range statements {
+		if err := client.DB.Exec(statement).Error; err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+	}
+
+	if _, err := client.MigrateUp(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var tagIDs []uint
+	client.DB.Raw("SELECT tag_id FROM spending_tag ORDER BY spending_id").Scan(&tagIDs)
+	if len(tagIDs) != 2 || tagIDs[0] != 1 || tagIDs[1] != 1 {
+		t.Errorf("Expected both spendings to be tagged with tag 1, got %v", tagIDs)
+	}
+
+	var tags, entries int64
+	client.DB.Table("tags").Count(&tags)
+	client.DB.Table("allowlist_entries").Count(&entries)
+	if tags != 2 || entries != 1 {
+		t.Errorf("Expected 2 tags and 1 allowlist entry, got %d and %d", tags, entries)
+	}
+}
+
+func TestUTCSpendingTimesMigration(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	if client.DB.Dialector.Name() != "sqlite" {
+		t.Skip("Only SQLite stores times with their offset")
+	}
+
+	if _, err := client.MigrateUp(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Stored by an earlier version running in UTC+2
+	err := client.DB.Exec("INSERT INTO spendings (id, cost, spent_at) VALUES (1, 10, '2024-05-01 01:00:00+02:00')").Error
+	if err != nil {
+		t.Fatalf("Failed to insert test data: %v", err)
+	}
+
+	if err := client.DB.Transaction(migrationUTCSpendingTimes.Up); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	spendings, err := client.GetSpendingsByDateRange(ctx,
+		time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 4, 30, 23, 59, 59, 0, time.UTC),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(spendings) != 1 {
+		t.Errorf("Expected the spending to be in April in UTC, got %d spendings", len(spendings))
+	}
+}
+
+func TestSpendingMessagesMigration(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	if _, err := client.MigrateUp(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The same message ID in another chat and spendings without a message
+	// are separate spendings
+	statements := []string{
+		"INSERT INTO spendings (chat_id, message_id, cost) VALUES (1, 5, 10)",
+		"INSERT INTO spendings (chat_id, message_id, cost) VALUES (2, 5, 20)",
+		"INSERT INTO spendings (chat_id, message_id, cost) VALUES (1, 0, 30)",
+		"INSERT INTO spendings (chat_id, message_id, cost) VALUES (1, 0, 40)",
+	}
+	for _, statement := range statements {
+		if err := client.DB.Exec(statement).Error; err != nil {
+			t.Fatalf("Unexpected error for %q: %v", statement, err)
+		}
+	}
+
+	err := client.DB.Exec("INSERT INTO spendings (chat_id, message_id, cost) VALUES (1, 5, 50)").Error
+	if err == nil {
+		t.Error("Expected a second spending for the same message in the same chat to be rejected")
+	}
+}
+
+func TestSpendingMessagesMigrationRemovesDuplicates(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	// Start from the schema before the migration
+	if _, err := client.MigrateUp(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, version := range []int{migrationSettlementMessages.Version, migrationSpendingMessages.Version} {
+		if reverted, err := client.MigrateDown(ctx); err != nil || reverted.Version != version {
+			t.Fatalf("Expected migration %d to be reverted, got %v, %v", version, reverted, err)
+		}
+	}
+
+	// A message stored twice with its tags, shares and statement match, the
+	// same message ID in another chat and spendings without a message
+	statements := []string{
+		"INSERT INTO spendings (id, chat_id, message_id, cost) VALUES (1, 1, 5, 10)",
+		"INSERT INTO spendings (id, chat_id, message_id, cost) VALUES (2, 1, 5, 12)",
+		"INSERT INTO spendings (id, chat_id, message_id, cost) VALUES (3, 2, 5, 20)",
+		"INSERT INTO spendings (id, chat_id, message_id, cost) VALUES (4, 1, 0, 30)",
+		"INSERT INTO spendings (id, chat_id, message_id, cost) VALUES (5, 1, 0, 30)",
+		"INSERT INTO tags (id, name) VALUES (1, 'food')",
+		"INSERT INTO spending_tag (spending_id, tag_id) VALUES (1, 1)",
+		"INSERT INTO spending_tag (spending_id, tag_id) VALUES (2, 1)",
+		"INSERT INTO spending_shares (spending_id, member_id, amount) VALUES (1, 1, 10)",
+		"INSERT INTO spending_shares (spending_id, member_id, amount) VALUES (2, 1, 12)",
+		"INSERT INTO statement_matches (chat_id, spending_id, line_id, amount) VALUES (1, 1, 'a', 10)",
+	}
+	for _, statement := range statements {
+		if err := client.DB.Exec(statement).Error; err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+	}
+
+	if _, err := client.MigrateUp(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var ids []uint
+	client.DB.Raw("SELECT id FROM spendings ORDER BY id").Scan(&ids)
+	if !slices.Equal(ids, []uint{2, 3, 4, 5}) {
+		t.Errorf("Expected only the latest copy of the spending to be kept, got %v", ids)
+	}
+	for _, table := range []string{"spending_tag", "spending_shares", "statement_matches"} {
+		var orphans int64
+		client.DB.Raw("SELECT COUNT(*) FROM " + table + " WHERE spending_id = 1").Scan(&orphans)
+		if orphans != 0 {
+			t.Errorf("Expected the rows of the removed spending in %s to be removed, got %d", table, orphans)
+		}
+	}
+
+	err := client.DB.Exec("INSERT INTO spendings (chat_id, message_id, cost) VALUES (2, 5, 20)").Error
+	if err == nil {
+		t.Error("Expected a second spending for the same message in the same chat to be rejected")
+	}
+}
+
+func TestSettlementMessagesMigration(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	// Start from the schema before the migration
+	if _, err := client.MigrateUp(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reverted, err := client.MigrateDown(ctx); err != nil || reverted.Version != migrationSettlementMessages.Version {
+		t.Fatalf("Expected the settlement_messages migration to be reverted, got %v, %v", reverted, err)
+	}
+
+	// A /settle message handled twice, the same message ID in another chat
+	// and settlements without a message
+	statements := []string{
+		"INSERT INTO settlements (id, chat_id, message_id, amount) VALUES (1, 1, 5, 10)",
+		"INSERT INTO settlements (id, chat_id, message_id, amount) VALUES (2, 1, 5, 10)",
+		"INSERT INTO settlements (id, chat_id, message_id, amount) VALUES (3, 2, 5, 20)",
+		"INSERT INTO settlements (id, chat_id, message_id, amount) VALUES (4, 1, 0, 30)",
+		"INSERT INTO settlements (id, chat_id, message_id, amount) VALUES (5, 1, 0, 30)",
+	}
+	for _, statement := range statements {
+		if err := client.DB.Exec(statement).Error; err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+	}
+
+	if _, err := client.MigrateUp(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var ids []uint
+	client.DB.Raw("SELECT id FROM settlements ORDER BY id").Scan(&ids)
+	if !slices.Equal(ids, []uint{1, 3, 4, 5}) {
+		t.Errorf("Expected the duplicate settlement to be removed, got %v", ids)
+	}
+
+	err := client.DB.Exec("INSERT INTO settlements (chat_id, message_id, amount) VALUES (2, 5, 20)").Error
+	if err == nil {
+		t.Error("Expected a second settlement for the same message in the same chat to be rejected")
+	}
+}
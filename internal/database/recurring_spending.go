@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/models"
+	"gorm.io/gorm"
+)
+
+func (c *Client) CreateRecurringSpending(ctx context.Context, recurringSpending *models.RecurringSpending) (*models.RecurringSpending, error) {
+	result := c.DB.WithContext(ctx).Create(&recurringSpending)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return recurringSpending, nil
+}
+
+func (c *Client) FindRecurringSpending(ctx context.Context, id uint) (*models.RecurringSpending, error) {
+	var recurringSpending models.RecurringSpending
+	err := c.DB.WithContext(ctx).Preload("Tags").First(&recurringSpending, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find recurring spending: %w", err)
+	}
+	return &recurringSpending, nil
+}
+
+func (c *Client) DeleteRecurringSpending(ctx context.Context, recurringSpending *models.RecurringSpending) error {
+	return c.DB.WithContext(ctx).Delete(recurringSpending).Error
+}
+
+func (c *Client) GetRecurringSpendings(ctx context.Context) ([]models.RecurringSpending, error) {
+	var recurringSpendings []models.RecurringSpending
+	err := c.DB.WithContext(ctx).Preload("Tags").Order("id").Find(&recurringSpendings).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recurring spendings: %w", err)
+	}
+	return recurringSpendings, nil
+}
+
+func (c *Client) GetRecurringSpendingsByChat(ctx context.Context, chatID int64) ([]models.RecurringSpending, error) {
+	var recurringSpendings []models.RecurringSpending
+	err := c.DB.WithContext(ctx).Preload("Tags").Where("chat_id = ?", chatID).Order("id").Find(&recurringSpendings).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recurring spendings by chat: %w", err)
+	}
+	return recurringSpendings, nil
+}
+
+func (c *Client) SyncRecurringSpendingTags(ctx context.Context, recurringSpending *models.RecurringSpending, tags *[]models.Tag) error {
+	return c.DB.WithContext(ctx).Model(recurringSpending).Association("Tags").Replace(tags)
+}
+
+func (c *Client) FindSpendingByRecurrence(ctx context.Context, recurringSpendingID uint, startDate, endDate time.Time) (*models.Spending, error) {
+	var spending models.Spending
+	err := c.DB.WithContext(ctx).Where("recurring_spending_id = ? AND spent_at BETWEEN ? AND ?", recurringSpendingID, startDate.UTC(), endDate.UTC()).First(&spending).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find spending by recurrence: %w", err)
+	}
+	return &spending, nil
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kiasaty/spendings-tracker/models"
+	"gorm.io/gorm"
+)
+
+// spendingsSearchTable is the SQLite FTS5 index of spending descriptions. It
+// is only available when the SQLite driver is built with FTS5, which needs
+// the sqlite_fts5 build tag.
+const spendingsSearchTable = "spendings_fts"
+
+// SearchSpendings returns the page of spendings matching filter, the ones
+// whose description matches its text best first, and the total number of
+// matching spendings. Words of the text match words of descriptions starting
+// with them.
+//
+// Without a full-text index, e.g. on other databases than SQLite, each word
+// of the text is matched anywhere in descriptions and the newest spendings
+// come first.
+func (c *Client) SearchSpendings(ctx context.Context, filter SpendingFilter) ([]models.Spending, int64, error) {
+	query, err := c.filterSpendings(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	match := fullTextQuery(filter.Text)
+	if match == "" || !c.DB.WithContext(ctx).Migrator().HasTable(spendingsSearchTable) {
+		for _, word := range strings.Fields(strings.ToLower(filter.Text)) {
+			query = query.Where("LOWER(spendings.description) LIKE ? ESCAPE ?", containsPattern(word), `\`)
+		}
+		return findSpendingsPage(query, filter, "spendings.spent_at DESC, spendings.id DESC")
+	}
+
+	query = query.
+		Joins("JOIN "+spendingsSearchTable+" ON "+spendingsSearchTable+".rowid = spendings.id").
+		Where(spendingsSearchTable+" MATCH ?", match)
+
+	return findSpendingsPage(query, filter, "bm25("+spendingsSearchTable+"), spendings.spent_at DESC, spendings.id DESC")
+}
+
+// fullTextQuery turns text into an FTS5 query matching descriptions that
+// contain words starting with each of its words. The words are quoted, so
+// characters with a meaning in FTS5 queries are matched literally.
+func fullTextQuery(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// fullTextSearchSupported reports whether the database can use the FTS5
+// index of spending descriptions.
+func fullTextSearchSupported(db *gorm.DB) bool {
+	if db.Dialector.Name() != "sqlite" {
+		return false
+	}
+
+	var enabled bool
+	err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled).Error
+	return err == nil && enabled
+}
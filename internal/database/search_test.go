@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+func TestSearchSpendingsRanking(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	if !fullTextSearchSupported(client.DB) {
+		t.Skip("SQLite is built without FTS5, run the tests with -tags sqlite_fts5")
+	}
+	if _, err := client.MigrateUp(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	day := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	descriptions := []string{
+		"Groceries at the market with a long list of fruit, vegetables, bread and a coffee",
+		"Coffee coffee coffee",
+		"Coffee beans",
+	}
+	for i, description := range descriptions {
+		spending := &models.Spending{ChatId: 1, MessageId: i + 1, Cost: float64(i + 1), Description: description, SpentAt: day.Add(time.Duration(i) * time.Hour)}
+		if _, err := client.CreateSpending(ctx, spending); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	spendings, total, err := client.SearchSpendings(ctx, SpendingFilter{ChatId: 1, Text: "coffee"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if total != 3 || len(spendings) != 3 {
+		t.Fatalf("Expected 3 matching spendings, got %d of %d", len(spendings), total)
+	}
+	if spendings[0].Description != "Coffee coffee coffee" || spendings[2].Cost != 1 {
+		t.Errorf("Expected the most relevant spendings first, got %q, %q, %q", spendings[0].Description, spendings[1].Description, spendings[2].Description)
+	}
+
+	// The index follows changes of descriptions and deletions
+	spendings[0].Description = "Tea"
+	if err := client.UpdateSpending(ctx, &spendings[0]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := client.DB.Unscoped().Delete(&spendings[1]).Error; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	spendings, _, err = client.SearchSpendings(ctx, SpendingFilter{ChatId: 1, Text: "coff"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(spendings) != 1 || spendings[0].Cost != 1 {
+		t.Errorf("Expected only the groceries to match, got %v", spendings)
+	}
+
+	spendings, _, err = client.SearchSpendings(ctx, SpendingFilter{ChatId: 1, Text: "tea"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(spendings) != 1 || spendings[0].Cost != 2 {
+		t.Errorf("Expected the updated spending to match, got %v", spendings)
+	}
+}
+
+func TestSpendingsSearchMigration(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	if !fullTextSearchSupported(client.DB) {
+		t.Skip("SQLite is built without FTS5, run the tests with -tags sqlite_fts5")
+	}
+	if _, err := client.MigrateUp(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	spending := &models.Spending{ChatId: 1, MessageId: 1, Cost: 3, Description: "Coffee beans", SpentAt: time.Now()}
+	if _, err := client.CreateSpending(ctx, spending); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Reverting the migration removes the index
+	for range len(migrations) - migrationSpendingsSearch.Version + 1 {
+		if _, err := client.MigrateDown(ctx); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if client.DB.Migrator().HasTable(spendingsSearchTable) {
+		t.Fatalf("Expected no full-text index without the spendings_search migration")
+	}
+
+	// Applying it again indexes the existing spendings
+	if _, err := client.MigrateUp(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !client.DB.Migrator().HasTable(spendingsSearchTable) {
+		t.Fatalf("Expected the full-text index to be created")
+	}
+	spendings, _, err := client.SearchSpendings(ctx, SpendingFilter{ChatId: 1, Text: "bean"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(spendings) != 1 {
+		t.Errorf("Expected the existing spending to be indexed, got %v", spendings)
+	}
+}
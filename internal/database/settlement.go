@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kiasaty/spendings-tracker/models"
+	"gorm.io/gorm"
+)
+
+func (c *Client) CreateSettlement(ctx context.Context, settlement *models.Settlement) (*models.Settlement, error) {
+	result := c.DB.WithContext(ctx).Create(&settlement)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return settlement, nil
+}
+
+// FindSettlementByMessageId finds the settlement recorded from a /settle
+// message of a chat.
+func (c *Client) FindSettlementByMessageId(ctx context.Context, chatID int64, messageID int) (*models.Settlement, error) {
+	var settlement models.Settlement
+	err := c.DB.WithContext(ctx).Where("chat_id = ? AND message_id = ?", chatID, messageID).First(&settlement).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find settlement: %w", err)
+	}
+	return &settlement, nil
+}
+
+func (c *Client) GetSettlementsByChat(ctx context.Context, chatID int64) ([]models.Settlement, error) {
+	var settlements []models.Settlement
+	err := c.DB.WithContext(ctx).Where("chat_id = ?", chatID).Find(&settlements).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settlements by chat: %w", err)
+	}
+	return settlements, nil
+}
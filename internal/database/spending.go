@@ -1,15 +1,20 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/kiasaty/spendings-tracker/models"
 	"gorm.io/gorm"
 )
 
-func (c *Client) CreateSpending(spending *models.Spending) (*models.Spending, error) {
-	result := c.DB.Create(&spending)
+// Spending times are stored in UTC. SQLite compares them as text, so times
+// with different offsets would otherwise be ordered wrongly.
+func (c *Client) CreateSpending(ctx context.Context, spending *models.Spending) (*models.Spending, error) {
+	spending.SpentAt = spending.SpentAt.UTC()
+	result := c.DB.WithContext(ctx).Create(&spending)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -18,9 +23,9 @@ func (c *Client) CreateSpending(spending *models.Spending) (*models.Spending, er
 	return spending, nil
 }
 
-func (c *Client) FindSpendingByMessageId(messageID int) (*models.Spending, error) {
+func (c *Client) FindSpending(ctx context.Context, id uint) (*models.Spending, error) {
 	var spending models.Spending
-	err := c.DB.Where("message_id = ?", messageID).First(&spending).Error
+	err := c.DB.WithContext(ctx).Preload("Tags").Preload("Shares").First(&spending, id).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -30,21 +35,220 @@ func (c *Client) FindSpendingByMessageId(messageID int) (*models.Spending, error
 	return &spending, nil
 }
 
-func (c *Client) UpdateSpending(spending *models.Spending) error {
-	result := c.DB.Save(&spending)
+// FindSpendingByMessageId finds the spending created from a message. Message
+// IDs are only unique within a chat.
+func (c *Client) FindSpendingByMessageId(ctx context.Context, chatID int64, messageID int) (*models.Spending, error) {
+	var spending models.Spending
+	err := c.DB.WithContext(ctx).Where("chat_id = ? AND message_id = ?", chatID, messageID).First(&spending).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find spending: %w", err)
+	}
+	return &spending, nil
+}
+
+func (c *Client) FindSpendingByImportHash(ctx context.Context, importHash string) (*models.Spending, error) {
+	var spending models.Spending
+	err := c.DB.WithContext(ctx).Where("import_hash = ?", importHash).First(&spending).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find spending: %w", err)
+	}
+	return &spending, nil
+}
+
+func (c *Client) UpdateSpending(ctx context.Context, spending *models.Spending) error {
+	spending.SpentAt = spending.SpentAt.UTC()
+	result := c.DB.WithContext(ctx).Save(&spending)
 
 	return result.Error
 }
 
-func (c *Client) SyncSpendingTags(spending *models.Spending, tags *[]models.Tag) error {
-	return c.DB.Model(spending).Association("Tags").Replace(tags)
+// DeleteSpending deletes a spending along with its tags, shares and
+// statement match. It is deleted for good rather than soft deleted, so the
+// unique indexes on import hashes and recurrences allow creating it again.
+func (c *Client) DeleteSpending(ctx context.Context, spending *models.Spending) error {
+	return c.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(spending).Association("Tags").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("spending_id = ?", spending.ID).Delete(&models.SpendingShare{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("spending_id = ?", spending.ID).Delete(&models.StatementMatch{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(spending).Error
+	})
 }
 
-func (c *Client) GetSpendingsByDateRange(startDate, endDate time.Time) ([]models.Spending, error) {
+func (c *Client) SyncSpendingTags(ctx context.Context, spending *models.Spending, tags *[]models.Tag) error {
+	return c.DB.WithContext(ctx).Model(spending).Association("Tags").Replace(tags)
+}
+
+func (c *Client) GetSpendingsByDateRange(ctx context.Context, startDate, endDate time.Time) ([]models.Spending, error) {
 	var spendings []models.Spending
-	err := c.DB.Preload("Tags").Where("spent_at BETWEEN ? AND ?", startDate, endDate).Find(&spendings).Error
+	err := c.DB.WithContext(ctx).Preload("Tags").Where("spent_at BETWEEN ? AND ?", startDate.UTC(), endDate.UTC()).Find(&spendings).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to get spendings by date range: %w", err)
 	}
 	return spendings, nil
 }
+
+func (c *Client) SyncSpendingShares(ctx context.Context, spending *models.Spending, shares *[]models.SpendingShare) error {
+	err := c.DB.WithContext(ctx).Unscoped().Where("spending_id = ?", spending.ID).Delete(&models.SpendingShare{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete spending shares: %w", err)
+	}
+
+	spending.Shares = nil
+	if len(*shares) == 0 {
+		return nil
+	}
+
+	for i := range *shares {
+		(*shares)[i].SpendingId = spending.ID
+	}
+	if err := c.DB.WithContext(ctx).Create(shares).Error; err != nil {
+		return fmt.Errorf("failed to create spending shares: %w", err)
+	}
+	spending.Shares = *shares
+
+	return nil
+}
+
+func (c *Client) GetSharedSpendingsByChat(ctx context.Context, chatID int64) ([]models.Spending, error) {
+	var spendings []models.Spending
+	err := c.DB.WithContext(ctx).Preload("Shares").
+		Where("chat_id = ? AND id IN (?)", chatID, c.DB.WithContext(ctx).Model(&models.SpendingShare{}).Select("spending_id")).
+		Find(&spendings).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared spendings by chat: %w", err)
+	}
+	return spendings, nil
+}
+
+func (c *Client) GetChatSpendingsByDateRange(ctx context.Context, chatID int64, startDate, endDate time.Time) ([]models.Spending, error) {
+	var spendings []models.Spending
+	err := c.DB.WithContext(ctx).Preload("Tags").Where("chat_id = ? AND spent_at BETWEEN ? AND ?", chatID, startDate.UTC(), endDate.UTC()).Find(&spendings).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat spendings by date range: %w", err)
+	}
+	return spendings, nil
+}
+
+// SpendingFilter narrows down the spendings returned by FindSpendings and
+// SearchSpendings. Zero values don't filter.
+type SpendingFilter struct {
+	ChatId    int64
+	StartDate time.Time
+	EndDate   time.Time
+	// Text matches descriptions containing it, ignoring case
+	Text string
+	// Tags matches spendings that have all of them
+	Tags  []string
+	Costs []CostCondition
+
+	Offset int
+	Limit  int
+}
+
+// CostCondition compares the cost of spendings with an amount using one of
+// the operators >, >=, < and <=.
+type CostCondition struct {
+	Operator string
+	Amount   float64
+}
+
+// Matches reports whether the condition holds for cost.
+func (c CostCondition) Matches(cost float64) bool {
+	switch c.Operator {
+	case ">":
+		return cost > c.Amount
+	case ">=":
+		return cost >= c.Amount
+	case "<":
+		return cost < c.Amount
+	case "<=":
+		return cost <= c.Amount
+	}
+	return false
+}
+
+// FindSpendings returns the page of spendings matching filter, newest first,
+// and the total number of matching spendings.
+func (c *Client) FindSpendings(ctx context.Context, filter SpendingFilter) ([]models.Spending, int64, error) {
+	query, err := c.filterSpendings(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	if filter.Text != "" {
+		query = query.Where("LOWER(spendings.description) LIKE ? ESCAPE ?", containsPattern(strings.ToLower(filter.Text)), `\`)
+	}
+
+	return findSpendingsPage(query, filter, "spendings.spent_at DESC, spendings.id DESC")
+}
+
+// containsPattern returns a LIKE pattern matching text anywhere, with the
+// wildcards in text matched literally. It is used with ESCAPE '\', passed as
+// a parameter since MySQL reads a backslash in a string literal as an escape.
+func containsPattern(text string) string {
+	text = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+	return "%" + text + "%"
+}
+
+// filterSpendings returns a query for the spendings matching all of filter
+// but its text and page.
+func (c *Client) filterSpendings(ctx context.Context, filter SpendingFilter) (*gorm.DB, error) {
+	query := c.DB.WithContext(ctx).Model(&models.Spending{})
+
+	if filter.ChatId != 0 {
+		query = query.Where("spendings.chat_id = ?", filter.ChatId)
+	}
+	if !filter.StartDate.IsZero() {
+		query = query.Where("spendings.spent_at >= ?", filter.StartDate.UTC())
+	}
+	if !filter.EndDate.IsZero() {
+		query = query.Where("spendings.spent_at <= ?", filter.EndDate.UTC())
+	}
+	for _, tag := range filter.Tags {
+		query = query.Where(
+			"spendings.id IN (SELECT spending_tag.spending_id FROM spending_tag JOIN tags ON tags.id = spending_tag.tag_id WHERE tags.name = ?)",
+			tag,
+		)
+	}
+	for _, condition := range filter.Costs {
+		switch condition.Operator {
+		case ">", ">=", "<", "<=":
+			query = query.Where("spendings.cost "+condition.Operator+" ?", condition.Amount)
+		default:
+			return nil, fmt.Errorf("invalid cost operator %q", condition.Operator)
+		}
+	}
+
+	return query, nil
+}
+
+// findSpendingsPage returns the page of filter from the spendings of query
+// in the given order, and the total number of them.
+func findSpendingsPage(query *gorm.DB, filter SpendingFilter, order string) ([]models.Spending, int64, error) {
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count spendings: %w", err)
+	}
+
+	query = query.Preload("Tags").Order(order).Offset(filter.Offset)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var spendings []models.Spending
+	if err := query.Find(&spendings).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to find spendings: %w", err)
+	}
+	return spendings, total, nil
+}
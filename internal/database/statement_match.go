@@ -0,0 +1,27 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kiasaty/spendings-tracker/models"
+)
+
+func (c *Client) CreateStatementMatch(ctx context.Context, match *models.StatementMatch) (*models.StatementMatch, error) {
+	result := c.DB.WithContext(ctx).Create(&match)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return match, nil
+}
+
+func (c *Client) GetStatementMatchesByChat(ctx context.Context, chatID int64) ([]models.StatementMatch, error) {
+	var matches []models.StatementMatch
+	err := c.DB.WithContext(ctx).Where("chat_id = ?", chatID).Order("booked_at, id").Find(&matches).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statement matches by chat: %w", err)
+	}
+	return matches, nil
+}
@@ -1,14 +1,15 @@
 package database
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/kiasaty/spendings-tracker/models"
 	"gorm.io/gorm"
 )
 
-func (c *Client) CreateTag(tag *models.Tag) (*models.Tag, error) {
-	result := c.DB.Create(&tag)
+func (c *Client) CreateTag(ctx context.Context, tag *models.Tag) (*models.Tag, error) {
+	result := c.DB.WithContext(ctx).Create(&tag)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -17,9 +18,9 @@ func (c *Client) CreateTag(tag *models.Tag) (*models.Tag, error) {
 	return tag, nil
 }
 
-func (c *Client) FindTagByName(name string) (*models.Tag, error) {
+func (c *Client) FindTagByName(ctx context.Context, name string) (*models.Tag, error) {
 	var tag models.Tag
-	err := c.DB.Where("name = ?", name).First(&tag).Error
+	err := c.DB.WithContext(ctx).Where("name = ?", name).First(&tag).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -28,3 +29,21 @@ func (c *Client) FindTagByName(name string) (*models.Tag, error) {
 	}
 	return &tag, nil
 }
+
+// FindTags returns the tags of a chat's spendings by name, or all tags when
+// chatID is 0.
+func (c *Client) FindTags(ctx context.Context, chatID int64) ([]models.Tag, error) {
+	query := c.DB.WithContext(ctx).Order("name")
+	if chatID != 0 {
+		query = query.Where("id IN (?)", c.DB.WithContext(ctx).Table("spending_tag").
+			Select("spending_tag.tag_id").
+			Joins("JOIN spendings ON spendings.id = spending_tag.spending_id").
+			Where("spendings.chat_id = ? AND spendings.deleted_at IS NULL", chatID))
+	}
+
+	var tags []models.Tag
+	if err := query.Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to find tags: %w", err)
+	}
+	return tags, nil
+}
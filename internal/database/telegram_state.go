@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kiasaty/spendings-tracker/models"
+	"gorm.io/gorm"
+)
+
+// GetLastUpdateId returns the ID of the last fully handled Telegram update,
+// or 0 when no update has been handled yet.
+func (c *Client) GetLastUpdateId(ctx context.Context) (int, error) {
+	var state models.TelegramState
+	err := c.DB.WithContext(ctx).First(&state).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get last update id: %w", err)
+	}
+	return state.LastUpdateId, nil
+}
+
+func (c *Client) SaveLastUpdateId(ctx context.Context, updateID int) error {
+	var state models.TelegramState
+	err := c.DB.WithContext(ctx).FirstOrInit(&state).Error
+	if err != nil {
+		return fmt.Errorf("failed to get telegram state: %w", err)
+	}
+
+	state.LastUpdateId = updateID
+	return c.DB.WithContext(ctx).Save(&state).Error
+}
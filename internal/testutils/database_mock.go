@@ -1,75 +1,301 @@
 package testutils
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unicode"
 
+	"github.com/kiasaty/spendings-tracker/internal/database"
 	"github.com/kiasaty/spendings-tracker/models"
+	"gorm.io/gorm"
 )
 
-// MockDatabaseClient implements database.DatabaseClient interface
+// MockDatabaseClient implements database.DatabaseClient interface. It is
+// safe for concurrent use; transactions are applied one at a time.
 type MockDatabaseClient struct {
-	spendings           map[int]*models.Spending
+	mu   sync.Mutex
+	txMu sync.Mutex
+	mockDatabaseState
+}
+
+// mockDatabaseState holds the records of the mock, separately from its
+// locks, so it can be copied for rolling back transactions.
+type mockDatabaseState struct {
+	spendings           map[MockSpendingKey]*models.Spending
 	tags                map[string]*models.Tag
+	allowlist           map[string]*models.AllowlistEntry
+	chatMembers         []*models.ChatMember
+	settlements         []*models.Settlement
+	statementMatches    []*models.StatementMatch
+	apiTokens           []*models.APIToken
+	dashboardSessions   []*models.DashboardSession
+	budgets             []*models.Budget
+	recurringSpendings  []*models.RecurringSpending
+	lastSpendingId      uint
+	lastTagId           uint
+	lastUpdateId        int
 	shouldErrorOnCreate bool
 	shouldErrorOnFind   bool
 }
 
 func NewMockDatabaseClient() *MockDatabaseClient {
 	return &MockDatabaseClient{
-		spendings: make(map[int]*models.Spending),
-		tags:      make(map[string]*models.Tag),
+		mockDatabaseState: mockDatabaseState{
+			spendings: make(map[MockSpendingKey]*models.Spending),
+			tags:      make(map[string]*models.Tag),
+			allowlist: make(map[string]*models.AllowlistEntry),
+		},
 	}
 }
 
+// MockSpendingKey identifies a spending of the mock by the message it was
+// created from. Message IDs are only unique within a chat, like in the real
+// database.
+type MockSpendingKey struct {
+	ChatId    int64
+	MessageId int
+}
+
 type MockDatabaseClientConfig struct {
-	InitialSpendings map[int]*models.Spending
+	InitialSpendings map[MockSpendingKey]*models.Spending
 	InitialTags      map[string]*models.Tag
 }
 
 func NewMockDatabaseClientWithConfig(config MockDatabaseClientConfig) *MockDatabaseClient {
 	return &MockDatabaseClient{
-		spendings: config.InitialSpendings,
-		tags:      config.InitialTags,
+		mockDatabaseState: mockDatabaseState{
+			spendings: config.InitialSpendings,
+			tags:      config.InitialTags,
+			allowlist: make(map[string]*models.AllowlistEntry),
+		},
+	}
+}
+
+func (m *MockDatabaseClient) MigrateUp(ctx context.Context) ([]database.MigrationStatus, error) {
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) MigrateDown(ctx context.Context) (*database.MigrationStatus, error) {
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) MigrationStatus(ctx context.Context) ([]database.MigrationStatus, error) {
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) Close() error {
+	return nil
+}
+
+// Transaction runs fn against the mock itself and restores the previous
+// state when fn returns an error.
+func (m *MockDatabaseClient) Transaction(ctx context.Context, fn func(tx database.DatabaseClient) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	m.mu.Lock()
+	snapshot := m.snapshot()
+	m.mu.Unlock()
+
+	if err := fn(m); err != nil {
+		m.mu.Lock()
+		m.restore(snapshot)
+		m.mu.Unlock()
+		return err
 	}
+	return nil
 }
 
-func (m *MockDatabaseClient) Migrate() {}
+// snapshot returns a copy of the stored records, so changes made to them
+// afterwards don't affect the snapshot.
+func (m *MockDatabaseClient) snapshot() *mockDatabaseState {
+	snapshot := m.mockDatabaseState
+
+	snapshot.spendings = make(map[MockSpendingKey]*models.Spending, len(m.spendings))
+	for key, spending := range m.spendings {
+		copied := *spending
+		snapshot.spendings[key] = &copied
+	}
+
+	snapshot.tags = make(map[string]*models.Tag, len(m.tags))
+	for key, tag := range m.tags {
+		copied := *tag
+		snapshot.tags[key] = &copied
+	}
 
-func (m *MockDatabaseClient) CreateTag(tag *models.Tag) (*models.Tag, error) {
+	snapshot.allowlist = make(map[string]*models.AllowlistEntry, len(m.allowlist))
+	for key, entry := range m.allowlist {
+		copied := *entry
+		snapshot.allowlist[key] = &copied
+	}
+
+	snapshot.chatMembers = copyRecords(m.chatMembers)
+	snapshot.settlements = copyRecords(m.settlements)
+	snapshot.statementMatches = copyRecords(m.statementMatches)
+	snapshot.apiTokens = copyRecords(m.apiTokens)
+	snapshot.dashboardSessions = copyRecords(m.dashboardSessions)
+	snapshot.budgets = copyRecords(m.budgets)
+	snapshot.recurringSpendings = copyRecords(m.recurringSpendings)
+
+	return &snapshot
+}
+
+func (m *MockDatabaseClient) restore(snapshot *mockDatabaseState) {
+	m.mockDatabaseState = *snapshot
+}
+
+func copyRecords[T any](records []*T) []*T {
+	if records == nil {
+		return nil
+	}
+	copied := make([]*T, len(records))
+	for i, record := range records {
+		value := *record
+		copied[i] = &value
+	}
+	return copied
+}
+
+func (m *MockDatabaseClient) CreateTag(ctx context.Context, tag *models.Tag) (*models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastTagId++
+	tag.ID = m.lastTagId
 	m.tags[tag.Name] = tag
 	return tag, nil
 }
 
-func (m *MockDatabaseClient) FindTagByName(name string) (*models.Tag, error) {
+func (m *MockDatabaseClient) FindTagByName(ctx context.Context, name string) (*models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if tag, exists := m.tags[name]; exists {
 		return tag, nil
 	}
 	return nil, nil
 }
 
-func (m *MockDatabaseClient) CreateSpending(spending *models.Spending) (*models.Spending, error) {
+// FindTags returns the tags of a chat's spendings by name, or all tags when
+// chatID is 0.
+func (m *MockDatabaseClient) FindTags(ctx context.Context, chatID int64) ([]models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	used := make(map[string]bool)
+	for _, spending := range m.spendings {
+		if spending.ChatId == chatID {
+			for _, tag := range spending.Tags {
+				used[tag.Name] = true
+			}
+		}
+	}
+
+	var result []models.Tag
+	for name, tag := range m.tags {
+		if chatID == 0 || used[name] {
+			result = append(result, *tag)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func (m *MockDatabaseClient) CreateSpending(ctx context.Context, spending *models.Spending) (*models.Spending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.shouldErrorOnCreate {
 		return nil, fmt.Errorf("mock error on create")
 	}
-	m.spendings[spending.MessageId] = spending
+	if _, exists := m.spendings[spendingKey(spending)]; exists && spending.MessageId != 0 {
+		return nil, fmt.Errorf("spending for message %d in chat %d already exists", spending.MessageId, spending.ChatId)
+	}
+	m.lastSpendingId++
+	spending.ID = m.lastSpendingId
+	m.spendings[spendingKey(spending)] = spending
 	return spending, nil
 }
 
-func (m *MockDatabaseClient) FindSpendingByMessageId(messageID int) (*models.Spending, error) {
-	if spending, exists := m.spendings[messageID]; exists {
+func (m *MockDatabaseClient) FindSpending(ctx context.Context, id uint) (*models.Spending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, spending := range m.spendings {
+		if spending.ID == id {
+			return spending, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) FindSpendingByMessageId(ctx context.Context, chatID int64, messageID int) (*models.Spending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if spending, exists := m.spendings[MockSpendingKey{ChatId: chatID, MessageId: messageID}]; exists {
 		return spending, nil
 	}
 	return nil, nil
 }
 
-func (m *MockDatabaseClient) UpdateSpending(spending *models.Spending) error {
-	m.spendings[spending.MessageId] = spending
+func (m *MockDatabaseClient) FindSpendingByImportHash(ctx context.Context, importHash string) (*models.Spending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, spending := range m.spendings {
+		if spending.ImportHash != nil && *spending.ImportHash == importHash {
+			return spending, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) UpdateSpending(ctx context.Context, spending *models.Spending) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.spendings[spendingKey(spending)] = spending
+	return nil
+}
+
+func (m *MockDatabaseClient) DeleteSpending(ctx context.Context, spending *models.Spending) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.spendings, spendingKey(spending))
+
+	var matches []*models.StatementMatch
+	for _, match := range m.statementMatches {
+		if match.SpendingId != spending.ID {
+			matches = append(matches, match)
+		}
+	}
+	m.statementMatches = matches
 	return nil
 }
 
-func (m *MockDatabaseClient) SyncSpendingTags(spending *models.Spending, tags *[]models.Tag) error {
+// spendingKey keys spendings by chat and message ID. Spendings that were not
+// created from a message (e.g. recurring ones) are keyed by their negated ID
+// instead.
+func spendingKey(spending *models.Spending) MockSpendingKey {
+	if spending.MessageId == 0 {
+		return MockSpendingKey{ChatId: spending.ChatId, MessageId: -int(spending.ID)}
+	}
+	return MockSpendingKey{ChatId: spending.ChatId, MessageId: spending.MessageId}
+}
+
+func (m *MockDatabaseClient) SyncSpendingTags(ctx context.Context, spending *models.Spending, tags *[]models.Tag) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	spending.Tags = *tags
 	return nil
 }
@@ -90,24 +316,47 @@ func (m *MockDatabaseClient) VerifySpending(t *testing.T, spending *models.Spend
 	}
 }
 
-func (m *MockDatabaseClient) GetSpendings() map[int]*models.Spending {
+func (m *MockDatabaseClient) GetSpendings() map[MockSpendingKey]*models.Spending {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	return m.spendings
 }
 
 func (m *MockDatabaseClient) GetTags() map[string]*models.Tag {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	return m.tags
 }
 
 func (m *MockDatabaseClient) SetErrorOnCreate(shouldError bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.shouldErrorOnCreate = shouldError
 }
 
 func (m *MockDatabaseClient) Reset() {
-	m.spendings = make(map[int]*models.Spending)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.spendings = make(map[MockSpendingKey]*models.Spending)
 	m.tags = make(map[string]*models.Tag)
+	m.lastSpendingId = 0
+	m.lastTagId = 0
+	m.allowlist = make(map[string]*models.AllowlistEntry)
+	m.chatMembers = nil
+	m.settlements = nil
+	m.budgets = nil
+	m.recurringSpendings = nil
+	m.lastUpdateId = 0
 }
 
 func (m *MockDatabaseClient) FindTagsBySpendingId(spendingID uint) ([]models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	for _, spending := range m.spendings {
 		if spending.ID == spendingID {
 			return spending.Tags, nil
@@ -140,12 +389,580 @@ func (m *MockDatabaseClient) VerifySpendingTags(t *testing.T, spending *models.S
 	}
 }
 
-func (m *MockDatabaseClient) GetSpendingsByDateRange(startDate, endDate time.Time) ([]models.Spending, error) {
+func (m *MockDatabaseClient) GetSpendingsByDateRange(ctx context.Context, startDate, endDate time.Time) ([]models.Spending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []models.Spending
+	for _, spending := range m.spendings {
+		if !spending.SpentAt.Before(startDate) && !spending.SpentAt.After(endDate) {
+			result = append(result, *spending)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockDatabaseClient) CreateAllowlistEntry(ctx context.Context, entry *models.AllowlistEntry) (*models.AllowlistEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.allowlist[allowlistKey(entry.Kind, entry.EntityId)] = entry
+	return entry, nil
+}
+
+func (m *MockDatabaseClient) FindAllowlistEntry(ctx context.Context, kind string, entityID int64) (*models.AllowlistEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, exists := m.allowlist[allowlistKey(kind, entityID)]; exists {
+		return entry, nil
+	}
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) DeleteAllowlistEntry(ctx context.Context, entry *models.AllowlistEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.allowlist, allowlistKey(entry.Kind, entry.EntityId))
+	return nil
+}
+
+func allowlistKey(kind string, entityID int64) string {
+	return fmt.Sprintf("%s:%d", kind, entityID)
+}
+
+func (m *MockDatabaseClient) SyncSpendingShares(ctx context.Context, spending *models.Spending, shares *[]models.SpendingShare) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range *shares {
+		(*shares)[i].SpendingId = spending.ID
+	}
+	spending.Shares = *shares
+	return nil
+}
+
+func (m *MockDatabaseClient) GetSharedSpendingsByChat(ctx context.Context, chatID int64) ([]models.Spending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []models.Spending
+	for _, spending := range m.spendings {
+		if spending.ChatId == chatID && len(spending.Shares) > 0 {
+			result = append(result, *spending)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockDatabaseClient) CreateChatMember(ctx context.Context, member *models.ChatMember) (*models.ChatMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	member.ID = uint(len(m.chatMembers) + 1)
+	m.chatMembers = append(m.chatMembers, member)
+	return member, nil
+}
+
+func (m *MockDatabaseClient) UpdateChatMember(ctx context.Context, member *models.ChatMember) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return nil
+}
+
+func (m *MockDatabaseClient) FindChatMemberByUserId(ctx context.Context, chatID, userID int64) (*models.ChatMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, member := range m.chatMembers {
+		if member.ChatId == chatID && member.UserId == userID {
+			return member, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) FindChatMemberByUserName(ctx context.Context, chatID int64, userName string) (*models.ChatMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, member := range m.chatMembers {
+		if member.ChatId == chatID && strings.EqualFold(member.UserName, userName) {
+			return member, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) GetChatMembers(ctx context.Context, chatID int64) ([]models.ChatMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []models.ChatMember
+	for _, member := range m.chatMembers {
+		if member.ChatId == chatID {
+			result = append(result, *member)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockDatabaseClient) CreateSettlement(ctx context.Context, settlement *models.Settlement) (*models.Settlement, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Like the unique index of the database
+	for _, existing := range m.settlements {
+		if settlement.MessageId != 0 && existing.ChatId == settlement.ChatId && existing.MessageId == settlement.MessageId {
+			return nil, fmt.Errorf("settlement for message %d in chat %d already exists", settlement.MessageId, settlement.ChatId)
+		}
+	}
+
+	settlement.ID = uint(len(m.settlements) + 1)
+	m.settlements = append(m.settlements, settlement)
+	return settlement, nil
+}
+
+func (m *MockDatabaseClient) FindSettlementByMessageId(ctx context.Context, chatID int64, messageID int) (*models.Settlement, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, settlement := range m.settlements {
+		if settlement.ChatId == chatID && settlement.MessageId == messageID {
+			return settlement, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) GetSettlementsByChat(ctx context.Context, chatID int64) ([]models.Settlement, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []models.Settlement
+	for _, settlement := range m.settlements {
+		if settlement.ChatId == chatID {
+			result = append(result, *settlement)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockDatabaseClient) CreateStatementMatch(ctx context.Context, match *models.StatementMatch) (*models.StatementMatch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Like the unique indexes of the database
+	for _, existing := range m.statementMatches {
+		if existing.SpendingId == match.SpendingId || (existing.ChatId == match.ChatId && existing.LineId == match.LineId) {
+			return nil, fmt.Errorf("statement line or spending already matched")
+		}
+	}
+
+	match.ID = uint(len(m.statementMatches) + 1)
+	m.statementMatches = append(m.statementMatches, match)
+	return match, nil
+}
+
+func (m *MockDatabaseClient) GetStatementMatchesByChat(ctx context.Context, chatID int64) ([]models.StatementMatch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []models.StatementMatch
+	for _, match := range m.statementMatches {
+		if match.ChatId == chatID {
+			result = append(result, *match)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].BookedAt.Before(result[j].BookedAt)
+	})
+	return result, nil
+}
+
+func (m *MockDatabaseClient) CreateAPIToken(ctx context.Context, token *models.APIToken) (*models.APIToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Like the unique index of the database
+	for _, existing := range m.apiTokens {
+		if existing.TokenHash == token.TokenHash {
+			return nil, fmt.Errorf("API token already exists")
+		}
+	}
+
+	token.ID = uint(len(m.apiTokens) + 1)
+	m.apiTokens = append(m.apiTokens, token)
+	return token, nil
+}
+
+// DeleteAPIToken marks the token as deleted, like gorm's soft delete, so
+// the IDs of the remaining tokens stay unique.
+func (m *MockDatabaseClient) DeleteAPIToken(ctx context.Context, token *models.APIToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.apiTokens {
+		if existing.ID == token.ID {
+			existing.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+func (m *MockDatabaseClient) FindAPIToken(ctx context.Context, id uint) (*models.APIToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, token := range m.apiTokens {
+		if token.ID == id && !token.DeletedAt.Valid {
+			return token, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) FindAPITokenByHash(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, token := range m.apiTokens {
+		if token.TokenHash == tokenHash && !token.DeletedAt.Valid {
+			return token, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) GetAPITokensByChat(ctx context.Context, chatID int64) ([]models.APIToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []models.APIToken
+	for _, token := range m.apiTokens {
+		if token.ChatId == chatID && !token.DeletedAt.Valid {
+			result = append(result, *token)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockDatabaseClient) CreateDashboardSession(ctx context.Context, session *models.DashboardSession) (*models.DashboardSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Like the unique index of the database
+	for _, existing := range m.dashboardSessions {
+		if existing.TokenHash == session.TokenHash {
+			return nil, fmt.Errorf("dashboard session already exists")
+		}
+	}
+
+	session.ID = uint(len(m.dashboardSessions) + 1)
+	m.dashboardSessions = append(m.dashboardSessions, session)
+	return session, nil
+}
+
+func (m *MockDatabaseClient) UpdateDashboardSession(ctx context.Context, session *models.DashboardSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, existing := range m.dashboardSessions {
+		if existing.ID == session.ID {
+			m.dashboardSessions[i] = session
+		}
+	}
+	return nil
+}
+
+// DeleteDashboardSession marks the session as deleted, like gorm's soft
+// delete, so the IDs of the remaining sessions stay unique.
+func (m *MockDatabaseClient) DeleteDashboardSession(ctx context.Context, session *models.DashboardSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.dashboardSessions {
+		if existing.ID == session.ID {
+			existing.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+func (m *MockDatabaseClient) FindDashboardSessionByHash(ctx context.Context, tokenHash string) (*models.DashboardSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, session := range m.dashboardSessions {
+		if session.TokenHash == tokenHash && !session.DeletedAt.Valid {
+			copied := *session
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) GetChatSpendingsByDateRange(ctx context.Context, chatID int64, startDate, endDate time.Time) ([]models.Spending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	var result []models.Spending
 	for _, spending := range m.spendings {
+		if spending.ChatId != chatID {
+			continue
+		}
 		if !spending.SpentAt.Before(startDate) && !spending.SpentAt.After(endDate) {
 			result = append(result, *spending)
 		}
 	}
 	return result, nil
 }
+
+func (m *MockDatabaseClient) CreateBudget(ctx context.Context, budget *models.Budget) (*models.Budget, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	budget.ID = uint(len(m.budgets) + 1)
+	m.budgets = append(m.budgets, budget)
+	return budget, nil
+}
+
+func (m *MockDatabaseClient) UpdateBudget(ctx context.Context, budget *models.Budget) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return nil
+}
+
+func (m *MockDatabaseClient) DeleteBudget(ctx context.Context, budget *models.Budget) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, existing := range m.budgets {
+		if existing.ID == budget.ID {
+			m.budgets = append(m.budgets[:i], m.budgets[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *MockDatabaseClient) FindBudget(ctx context.Context, chatID int64, tagName string) (*models.Budget, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, budget := range m.budgets {
+		if budget.ChatId == chatID && budget.TagName == tagName {
+			return budget, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) GetBudgetsByChat(ctx context.Context, chatID int64) ([]models.Budget, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []models.Budget
+	for _, budget := range m.budgets {
+		if budget.ChatId == chatID {
+			result = append(result, *budget)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].TagName < result[j].TagName
+	})
+	return result, nil
+}
+
+func (m *MockDatabaseClient) CreateRecurringSpending(ctx context.Context, recurringSpending *models.RecurringSpending) (*models.RecurringSpending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	recurringSpending.ID = uint(len(m.recurringSpendings) + 1)
+	m.recurringSpendings = append(m.recurringSpendings, recurringSpending)
+	return recurringSpending, nil
+}
+
+func (m *MockDatabaseClient) FindRecurringSpending(ctx context.Context, id uint) (*models.RecurringSpending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, recurringSpending := range m.recurringSpendings {
+		if recurringSpending.ID == id && !recurringSpending.DeletedAt.Valid {
+			return recurringSpending, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) DeleteRecurringSpending(ctx context.Context, recurringSpending *models.RecurringSpending) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	recurringSpending.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	return nil
+}
+
+func (m *MockDatabaseClient) GetRecurringSpendings(ctx context.Context) ([]models.RecurringSpending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []models.RecurringSpending
+	for _, recurringSpending := range m.recurringSpendings {
+		if !recurringSpending.DeletedAt.Valid {
+			result = append(result, *recurringSpending)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockDatabaseClient) GetRecurringSpendingsByChat(ctx context.Context, chatID int64) ([]models.RecurringSpending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []models.RecurringSpending
+	for _, recurringSpending := range m.recurringSpendings {
+		if recurringSpending.ChatId == chatID && !recurringSpending.DeletedAt.Valid {
+			result = append(result, *recurringSpending)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockDatabaseClient) SyncRecurringSpendingTags(ctx context.Context, recurringSpending *models.RecurringSpending, tags *[]models.Tag) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	recurringSpending.Tags = *tags
+	return nil
+}
+
+func (m *MockDatabaseClient) FindSpendingByRecurrence(ctx context.Context, recurringSpendingID uint, startDate, endDate time.Time) (*models.Spending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, spending := range m.spendings {
+		if spending.RecurringSpendingId == nil || *spending.RecurringSpendingId != recurringSpendingID {
+			continue
+		}
+		if !spending.SpentAt.Before(startDate) && !spending.SpentAt.After(endDate) {
+			return spending, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDatabaseClient) GetLastUpdateId(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lastUpdateId, nil
+}
+
+func (m *MockDatabaseClient) SaveLastUpdateId(ctx context.Context, updateID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastUpdateId = updateID
+	return nil
+}
+
+func (m *MockDatabaseClient) FindSpendings(ctx context.Context, filter database.SpendingFilter) ([]models.Spending, int64, error) {
+	return m.findSpendings(filter, func(description string) bool {
+		return strings.Contains(strings.ToLower(description), strings.ToLower(filter.Text))
+	})
+}
+
+// SearchSpendings matches the words of the filter's text with the start of
+// words in descriptions like the full-text index does, but doesn't rank the
+// results.
+func (m *MockDatabaseClient) SearchSpendings(ctx context.Context, filter database.SpendingFilter) ([]models.Spending, int64, error) {
+	return m.findSpendings(filter, func(description string) bool {
+		words := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for _, term := range strings.Fields(strings.ToLower(filter.Text)) {
+			found := false
+			for _, word := range words {
+				if strings.HasPrefix(word, term) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (m *MockDatabaseClient) findSpendings(filter database.SpendingFilter, matchText func(description string) bool) ([]models.Spending, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []models.Spending
+	for _, spending := range m.spendings {
+		if filter.ChatId != 0 && spending.ChatId != filter.ChatId {
+			continue
+		}
+		if !filter.StartDate.IsZero() && spending.SpentAt.Before(filter.StartDate) {
+			continue
+		}
+		if !filter.EndDate.IsZero() && spending.SpentAt.After(filter.EndDate) {
+			continue
+		}
+		if filter.Text != "" && !matchText(spending.Description) {
+			continue
+		}
+		if !hasTags(spending, filter.Tags) || !matchesCosts(spending, filter.Costs) {
+			continue
+		}
+		result = append(result, *spending)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].SpentAt.Equal(result[j].SpentAt) {
+			return result[i].SpentAt.After(result[j].SpentAt)
+		}
+		return result[i].ID > result[j].ID
+	})
+
+	total := int64(len(result))
+	if filter.Offset >= len(result) {
+		return nil, total, nil
+	}
+	result = result[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(result) {
+		result = result[:filter.Limit]
+	}
+	return result, total, nil
+}
+
+func hasTags(spending *models.Spending, names []string) bool {
+	for _, name := range names {
+		found := false
+		for _, tag := range spending.Tags {
+			if tag.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesCosts(spending *models.Spending, conditions []database.CostCondition) bool {
+	for _, condition := range conditions {
+		if !condition.Matches(spending.Cost) {
+			return false
+		}
+	}
+	return true
+}
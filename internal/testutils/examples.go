@@ -1,6 +1,7 @@
 package testutils
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/kiasaty/spendings-tracker/models"
@@ -8,6 +9,7 @@ import (
 
 // ExampleMockDatabaseClient demonstrates how to use the mock database client
 func ExampleMockDatabaseClient() {
+	ctx := context.Background()
 	mockDB := NewMockDatabaseClient()
 
 	// Create a spending
@@ -19,14 +21,14 @@ func ExampleMockDatabaseClient() {
 	}
 
 	// Store the spending
-	_, err := mockDB.CreateSpending(spending)
+	_, err := mockDB.CreateSpending(ctx, spending)
 	if err != nil {
 		fmt.Println("Error creating spending:", err)
 		return
 	}
 
 	// Retrieve the spending
-	retrieved, _ := mockDB.FindSpendingByMessageId(1)
+	retrieved, _ := mockDB.FindSpendingByMessageId(ctx, 123456789, 1)
 	fmt.Printf("Retrieved spending cost: %.2f\n", retrieved.Cost)
 	// Output: Retrieved spending cost: 15.50
 }
@@ -1,6 +1,8 @@
 package testutils
 
 import (
+	"strings"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -14,3 +16,41 @@ func NewTestUpdate(messageID int, chatID int64, text string) *tgbotapi.Update {
 		},
 	}
 }
+
+// NewTestUpdateFromUser creates a test update with a message sent by a specific user
+func NewTestUpdateFromUser(messageID int, chatID int64, userID int64, text string) *tgbotapi.Update {
+	update := NewTestUpdate(messageID, chatID, text)
+	update.Message.From = &tgbotapi.User{ID: userID}
+	return update
+}
+
+// NewTestCommandUpdate creates a test update whose message starts with a bot command
+func NewTestCommandUpdate(messageID int, chatID int64, userID int64, text string) *tgbotapi.Update {
+	update := NewTestUpdateFromUser(messageID, chatID, userID, text)
+
+	commandLength := len(text)
+	if i := strings.Index(text, " "); i != -1 {
+		commandLength = i
+	}
+	update.Message.Entities = []tgbotapi.MessageEntity{
+		{Type: "bot_command", Offset: 0, Length: commandLength},
+	}
+
+	return update
+}
+
+// NewTestGroupUpdate creates a test update with a message sent by a user in a
+// group chat. Texts starting with "/" are treated as bot commands.
+func NewTestGroupUpdate(messageID int, chatID int64, from tgbotapi.User, text string) *tgbotapi.Update {
+	var update *tgbotapi.Update
+	if strings.HasPrefix(text, "/") {
+		update = NewTestCommandUpdate(messageID, chatID, from.ID, text)
+	} else {
+		update = NewTestUpdateFromUser(messageID, chatID, from.ID, text)
+	}
+
+	update.Message.Chat.Type = "group"
+	update.Message.From = &from
+
+	return update
+}
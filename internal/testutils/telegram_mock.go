@@ -1,6 +1,9 @@
 package testutils
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"testing"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -8,10 +11,31 @@ import (
 
 // MockTelegramBot implements telegram.BotInterface
 type MockTelegramBot struct {
+	mu               sync.Mutex
 	sentMessages     []string
+	sentMessageChats []int64
+	unreachableChats map[int64]bool
+	chatTypes        map[int64]string
 	expectedMessages []string
+	pendingUpdates   []tgbotapi.Update
+	keyboards        []tgbotapi.InlineKeyboardMarkup
+	editedMessages   []string
+	callbackAnswers  []string
+	documents        []MockDocument
+	photos           []MockDocument
 }
 
+// MockDocument is a file sent with SendDocument or SendPhoto
+type MockDocument struct {
+	ChatID   int64
+	FileName string
+	Data     []byte
+	Caption  string
+}
+
+// MockBotUserId is the user ID of the mock bot
+const MockBotUserId int64 = 4242
+
 func NewMockTelegramBot() *MockTelegramBot {
 	return &MockTelegramBot{
 		sentMessages:     make([]string, 0),
@@ -19,17 +43,204 @@ func NewMockTelegramBot() *MockTelegramBot {
 	}
 }
 
-func (m *MockTelegramBot) GetUpdates() tgbotapi.UpdatesChannel {
-	// For tests, we don't need a real updates channel
-	return make(chan tgbotapi.Update)
+func (m *MockTelegramBot) SelfID() int64 {
+	return MockBotUserId
+}
+
+// GetUpdates returns the queued updates with an ID of at least the offset,
+// dropping the ones before it like Telegram does.
+func (m *MockTelegramBot) GetUpdates(ctx context.Context, offset int) ([]tgbotapi.Update, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var updates []tgbotapi.Update
+	for _, update := range m.pendingUpdates {
+		if update.UpdateID >= offset {
+			updates = append(updates, update)
+		}
+	}
+	m.pendingUpdates = updates
+	return updates, nil
+}
+
+// QueueUpdates adds updates to be returned by GetUpdates
+func (m *MockTelegramBot) QueueUpdates(updates ...*tgbotapi.Update) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, update := range updates {
+		m.pendingUpdates = append(m.pendingUpdates, *update)
+	}
+}
+
+func (m *MockTelegramBot) SendMessage(ctx context.Context, chatID int64, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.unreachableChats[chatID] {
+		return fmt.Errorf("failed to send message: chat %d not found", chatID)
+	}
+	m.sentMessages = append(m.sentMessages, text)
+	m.sentMessageChats = append(m.sentMessageChats, chatID)
+	return nil
+}
+
+// SetUnreachable makes sending messages to a chat fail, like for users who
+// never started a private chat with the bot.
+func (m *MockTelegramBot) SetUnreachable(chatID int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.unreachableChats == nil {
+		m.unreachableChats = make(map[int64]bool)
+	}
+	m.unreachableChats[chatID] = true
+}
+
+// GetChat returns a chat of the type set with SetChatType. Other chats are
+// private chats for positive IDs and groups for negative ones.
+func (m *MockTelegramBot) GetChat(ctx context.Context, chatID int64) (*tgbotapi.Chat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chatType := m.chatTypes[chatID]
+	switch {
+	case chatType != "":
+	case chatID > 0:
+		chatType = "private"
+	default:
+		chatType = "group"
+	}
+	return &tgbotapi.Chat{ID: chatID, Type: chatType}, nil
 }
 
-func (m *MockTelegramBot) SendMessage(chatID int64, text string) error {
+// SetChatType sets the type GetChat returns for a chat, like "channel".
+func (m *MockTelegramBot) SetChatType(chatID int64, chatType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.chatTypes == nil {
+		m.chatTypes = make(map[int64]string)
+	}
+	m.chatTypes[chatID] = chatType
+}
+
+func (m *MockTelegramBot) SendMessageWithKeyboard(ctx context.Context, chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.sentMessages = append(m.sentMessages, text)
+	m.sentMessageChats = append(m.sentMessageChats, chatID)
+	m.keyboards = append(m.keyboards, keyboard)
+	return nil
+}
+
+func (m *MockTelegramBot) EditMessage(ctx context.Context, chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.editedMessages = append(m.editedMessages, text)
+	if keyboard != nil {
+		m.keyboards = append(m.keyboards, *keyboard)
+	}
+	return nil
+}
+
+func (m *MockTelegramBot) AnswerCallbackQuery(ctx context.Context, callbackQueryID string, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.callbackAnswers = append(m.callbackAnswers, callbackQueryID)
+	return nil
+}
+
+func (m *MockTelegramBot) SendDocument(ctx context.Context, chatID int64, fileName string, data []byte, caption string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.documents = append(m.documents, MockDocument{ChatID: chatID, FileName: fileName, Data: data, Caption: caption})
+	return nil
+}
+
+func (m *MockTelegramBot) SendPhoto(ctx context.Context, chatID int64, fileName string, data []byte, caption string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.photos = append(m.photos, MockDocument{ChatID: chatID, FileName: fileName, Data: data, Caption: caption})
 	return nil
 }
 
+// LastKeyboard returns the inline keyboard of the latest message sent or
+// edited with one.
+func (m *MockTelegramBot) LastKeyboard() *tgbotapi.InlineKeyboardMarkup {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.keyboards) == 0 {
+		return nil
+	}
+	keyboard := m.keyboards[len(m.keyboards)-1]
+	return &keyboard
+}
+
+// SentMessages returns the texts of the messages sent so far.
+func (m *MockTelegramBot) SentMessages() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.sentMessages...)
+}
+
+// SentMessagesTo returns the texts of the messages sent to a chat so far.
+func (m *MockTelegramBot) SentMessagesTo(chatID int64) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var messages []string
+	for i, text := range m.sentMessages {
+		if m.sentMessageChats[i] == chatID {
+			messages = append(messages, text)
+		}
+	}
+	return messages
+}
+
+// EditedMessages returns the texts messages were edited to.
+func (m *MockTelegramBot) EditedMessages() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.editedMessages...)
+}
+
+// CallbackAnswers returns the IDs of the answered callback queries.
+func (m *MockTelegramBot) CallbackAnswers() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.callbackAnswers...)
+}
+
+// Documents returns the files sent so far.
+func (m *MockTelegramBot) Documents() []MockDocument {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]MockDocument(nil), m.documents...)
+}
+
+// Photos returns the photos sent so far.
+func (m *MockTelegramBot) Photos() []MockDocument {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]MockDocument(nil), m.photos...)
+}
+
 func (m *MockTelegramBot) VerifyMessage(t *testing.T, expectedText string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	for _, msg := range m.sentMessages {
 		if msg == expectedText {
 			return
@@ -39,15 +250,31 @@ func (m *MockTelegramBot) VerifyMessage(t *testing.T, expectedText string) {
 }
 
 func (m *MockTelegramBot) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.sentMessages = make([]string, 0)
+	m.sentMessageChats = nil
 	m.expectedMessages = make([]string, 0)
+	m.pendingUpdates = nil
+	m.keyboards = nil
+	m.editedMessages = nil
+	m.callbackAnswers = nil
+	m.documents = nil
+	m.photos = nil
 }
 
 func (m *MockTelegramBot) ExpectMessage(text string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.expectedMessages = append(m.expectedMessages, text)
 }
 
 func (m *MockTelegramBot) VerifyExpectations(t *testing.T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if len(m.expectedMessages) != len(m.sentMessages) {
 		t.Errorf("Expected %d messages, got %d", len(m.expectedMessages), len(m.sentMessages))
 		return
@@ -0,0 +1,440 @@
+package testutils
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// FakeTelegramServer is an in-process Telegram Bot API for end-to-end tests.
+// It implements getMe, getUpdates, getChat, sendMessage, sendDocument,
+// sendPhoto, editMessageText, setMyCommands and answerCallbackQuery, hands
+// out queued updates like Telegram does and records everything the bot sends.
+type FakeTelegramServer struct {
+	server *httptest.Server
+	closed chan struct{}
+
+	mu              sync.Mutex
+	updates         []tgbotapi.Update
+	newUpdates      chan struct{}
+	lastUpdateId    int
+	lastMessageId   int
+	offset          int
+	sentMessages    []FakeSentMessage
+	editedMessages  []FakeSentMessage
+	messageSent     chan struct{}
+	commands        []tgbotapi.BotCommand
+	callbackAnswers []FakeCallbackAnswer
+	documents       []FakeSentDocument
+	photos          []FakeSentDocument
+	chats           map[int64]tgbotapi.Chat
+}
+
+// FakeSentMessage is a message sent through the fake Bot API.
+type FakeSentMessage struct {
+	MessageID   int
+	ChatID      int64
+	Text        string
+	ReplyMarkup string
+}
+
+// FakeSentDocument is a file sent through the fake Bot API, as a document or
+// as a photo.
+type FakeSentDocument struct {
+	MessageID int
+	ChatID    int64
+	FileName  string
+	Data      []byte
+	Caption   string
+}
+
+// FakeCallbackAnswer is an answer to a callback query sent through the fake
+// Bot API.
+type FakeCallbackAnswer struct {
+	CallbackQueryID string
+	Text            string
+}
+
+// NewFakeTelegramServer starts a fake Bot API that is shut down when the test
+// finishes.
+func NewFakeTelegramServer(t *testing.T) *FakeTelegramServer {
+	f := &FakeTelegramServer{
+		closed:      make(chan struct{}),
+		newUpdates:  make(chan struct{}),
+		messageSent: make(chan struct{}),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+
+	t.Cleanup(func() {
+		// Release pending long polls, the server waits for them on close
+		close(f.closed)
+		f.server.Close()
+	})
+
+	return f
+}
+
+// Endpoint returns the API endpoint to use as TELEGRAM_API_ENDPOINT.
+func (f *FakeTelegramServer) Endpoint() string {
+	return f.server.URL + "/bot%s/%s"
+}
+
+// AddChat makes getChat return the chat. Other chats are not found.
+func (f *FakeTelegramServer) AddChat(chat tgbotapi.Chat) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.chats == nil {
+		f.chats = make(map[int64]tgbotapi.Chat)
+	}
+	f.chats[chat.ID] = chat
+}
+
+// QueueUpdates adds updates to be returned by getUpdates. Updates without an
+// ID get the next one.
+func (f *FakeTelegramServer) QueueUpdates(updates ...*tgbotapi.Update) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, update := range updates {
+		if update.UpdateID == 0 {
+			update.UpdateID = f.lastUpdateId + 1
+		}
+		if update.UpdateID > f.lastUpdateId {
+			f.lastUpdateId = update.UpdateID
+		}
+		f.updates = append(f.updates, *update)
+	}
+
+	close(f.newUpdates)
+	f.newUpdates = make(chan struct{})
+}
+
+// Offset returns the offset of the latest getUpdates request. Updates before
+// it have been acknowledged by the bot.
+func (f *FakeTelegramServer) Offset() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.offset
+}
+
+// SentMessages returns the messages sent so far.
+func (f *FakeTelegramServer) SentMessages() []FakeSentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]FakeSentMessage(nil), f.sentMessages...)
+}
+
+// WaitForMessages waits until at least count messages have been sent and
+// returns them, failing the test when that takes longer than timeout.
+func (f *FakeTelegramServer) WaitForMessages(t *testing.T, count int, timeout time.Duration) []FakeSentMessage {
+	t.Helper()
+
+	deadline := time.After(timeout)
+	for {
+		f.mu.Lock()
+		messages := append([]FakeSentMessage(nil), f.sentMessages...)
+		messageSent := f.messageSent
+		f.mu.Unlock()
+
+		if len(messages) >= count {
+			return messages
+		}
+
+		select {
+		case <-messageSent:
+		case <-deadline:
+			t.Fatalf("Expected %d messages to be sent, got %d: %v", count, len(messages), messages)
+			return nil
+		}
+	}
+}
+
+// EditedMessages returns the edits of sent messages so far.
+func (f *FakeTelegramServer) EditedMessages() []FakeSentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]FakeSentMessage(nil), f.editedMessages...)
+}
+
+// Commands returns the commands set with setMyCommands.
+func (f *FakeTelegramServer) Commands() []tgbotapi.BotCommand {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]tgbotapi.BotCommand(nil), f.commands...)
+}
+
+// Documents returns the files sent so far.
+func (f *FakeTelegramServer) Documents() []FakeSentDocument {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]FakeSentDocument(nil), f.documents...)
+}
+
+// Photos returns the photos sent so far.
+func (f *FakeTelegramServer) Photos() []FakeSentDocument {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]FakeSentDocument(nil), f.photos...)
+}
+
+// CallbackAnswers returns the answers to callback queries sent so far.
+func (f *FakeTelegramServer) CallbackAnswers() []FakeCallbackAnswer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]FakeCallbackAnswer(nil), f.callbackAnswers...)
+}
+
+func (f *FakeTelegramServer) handle(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			writeFakeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	} else if err := r.ParseForm(); err != nil {
+		writeFakeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	switch method {
+	case "getMe":
+		writeFakeResult(w, tgbotapi.User{ID: 1, IsBot: true, FirstName: "Spendings", UserName: "spendings_bot"})
+	case "getUpdates":
+		f.handleGetUpdates(w, r)
+	case "getChat":
+		chatID, _ := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+		f.mu.Lock()
+		chat, ok := f.chats[chatID]
+		f.mu.Unlock()
+		if !ok {
+			writeFakeError(w, http.StatusBadRequest, "Bad Request: chat not found")
+			return
+		}
+		writeFakeResult(w, chat)
+	case "sendMessage":
+		f.handleSendMessage(w, r)
+	case "sendDocument":
+		f.handleSendFile(w, r, "document")
+	case "sendPhoto":
+		f.handleSendFile(w, r, "photo")
+	case "editMessageText":
+		f.handleEditMessageText(w, r)
+	case "setMyCommands":
+		var commands []tgbotapi.BotCommand
+		if err := json.Unmarshal([]byte(r.FormValue("commands")), &commands); err != nil {
+			writeFakeError(w, http.StatusBadRequest, "invalid commands")
+			return
+		}
+		f.mu.Lock()
+		f.commands = commands
+		f.mu.Unlock()
+		writeFakeResult(w, true)
+	case "answerCallbackQuery":
+		f.mu.Lock()
+		f.callbackAnswers = append(f.callbackAnswers, FakeCallbackAnswer{
+			CallbackQueryID: r.FormValue("callback_query_id"),
+			Text:            r.FormValue("text"),
+		})
+		f.mu.Unlock()
+		writeFakeResult(w, true)
+	default:
+		writeFakeError(w, http.StatusNotFound, "Not Found: method "+method+" is not implemented by the fake")
+	}
+}
+
+// handleGetUpdates returns the updates at or after the offset, waiting up to
+// the requested timeout for new ones when there are none yet.
+func (f *FakeTelegramServer) handleGetUpdates(w http.ResponseWriter, r *http.Request) {
+	offset, _ := strconv.Atoi(r.FormValue("offset"))
+	timeout, _ := strconv.Atoi(r.FormValue("timeout"))
+	deadline := time.After(time.Duration(timeout) * time.Second)
+
+	for {
+		f.mu.Lock()
+		f.offset = offset
+
+		// Updates before the offset are acknowledged and won't be sent again
+		var updates []tgbotapi.Update
+		for _, update := range f.updates {
+			if update.UpdateID >= offset {
+				updates = append(updates, update)
+			}
+		}
+		f.updates = updates
+		newUpdates := f.newUpdates
+		f.mu.Unlock()
+
+		if len(updates) > 0 {
+			writeFakeResult(w, updates)
+			return
+		}
+
+		select {
+		case <-newUpdates:
+		case <-deadline:
+			writeFakeResult(w, []tgbotapi.Update{})
+			return
+		case <-r.Context().Done():
+			return
+		case <-f.closed:
+			writeFakeResult(w, []tgbotapi.Update{})
+			return
+		}
+	}
+}
+
+func (f *FakeTelegramServer) handleSendMessage(w http.ResponseWriter, r *http.Request) {
+	chatID, err := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+	if err != nil {
+		writeFakeError(w, http.StatusBadRequest, "Bad Request: chat_id is invalid")
+		return
+	}
+	text := r.FormValue("text")
+	if text == "" {
+		writeFakeError(w, http.StatusBadRequest, "Bad Request: message text is empty")
+		return
+	}
+
+	f.mu.Lock()
+	f.lastMessageId++
+	message := tgbotapi.Message{
+		MessageID: f.lastMessageId,
+		Date:      int(time.Now().Unix()),
+		Chat:      &tgbotapi.Chat{ID: chatID},
+		Text:      text,
+	}
+	f.sentMessages = append(f.sentMessages, FakeSentMessage{
+		MessageID:   message.MessageID,
+		ChatID:      chatID,
+		Text:        text,
+		ReplyMarkup: r.FormValue("reply_markup"),
+	})
+	close(f.messageSent)
+	f.messageSent = make(chan struct{})
+	f.mu.Unlock()
+
+	writeFakeResult(w, message)
+}
+
+// handleSendFile records the file uploaded in the field, "document" or
+// "photo", of the request.
+func (f *FakeTelegramServer) handleSendFile(w http.ResponseWriter, r *http.Request, field string) {
+	chatID, err := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+	if err != nil {
+		writeFakeError(w, http.StatusBadRequest, "Bad Request: chat_id is invalid")
+		return
+	}
+	if r.MultipartForm == nil || len(r.MultipartForm.File[field]) == 0 {
+		writeFakeError(w, http.StatusBadRequest, "Bad Request: there is no "+field+" in the request")
+		return
+	}
+
+	header := r.MultipartForm.File[field][0]
+	file, err := header.Open()
+	if err != nil {
+		writeFakeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeFakeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	f.lastMessageId++
+	message := tgbotapi.Message{
+		MessageID: f.lastMessageId,
+		Date:      int(time.Now().Unix()),
+		Chat:      &tgbotapi.Chat{ID: chatID},
+		Caption:   r.FormValue("caption"),
+	}
+	sent := FakeSentDocument{
+		MessageID: message.MessageID,
+		ChatID:    chatID,
+		FileName:  header.Filename,
+		Data:      data,
+		Caption:   message.Caption,
+	}
+	if field == "photo" {
+		message.Photo = []tgbotapi.PhotoSize{{FileSize: len(data)}}
+		f.photos = append(f.photos, sent)
+	} else {
+		message.Document = &tgbotapi.Document{FileName: header.Filename, FileSize: len(data)}
+		f.documents = append(f.documents, sent)
+	}
+	f.mu.Unlock()
+
+	writeFakeResult(w, message)
+}
+
+func (f *FakeTelegramServer) handleEditMessageText(w http.ResponseWriter, r *http.Request) {
+	chatID, _ := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+	messageID, _ := strconv.Atoi(r.FormValue("message_id"))
+	text := r.FormValue("text")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var sent *FakeSentMessage
+	for i := range f.sentMessages {
+		if f.sentMessages[i].ChatID == chatID && f.sentMessages[i].MessageID == messageID {
+			sent = &f.sentMessages[i]
+		}
+	}
+	if sent == nil {
+		writeFakeError(w, http.StatusBadRequest, "Bad Request: message to edit not found")
+		return
+	}
+
+	edit := FakeSentMessage{
+		MessageID:   messageID,
+		ChatID:      chatID,
+		Text:        text,
+		ReplyMarkup: r.FormValue("reply_markup"),
+	}
+	if edit.Text == sent.Text && edit.ReplyMarkup == sent.ReplyMarkup {
+		writeFakeError(w, http.StatusBadRequest, "Bad Request: message is not modified")
+		return
+	}
+	*sent = edit
+	f.editedMessages = append(f.editedMessages, edit)
+
+	writeFakeResult(w, tgbotapi.Message{MessageID: messageID, Chat: &tgbotapi.Chat{ID: chatID}, Text: text})
+}
+
+func writeFakeResult(w http.ResponseWriter, result interface{}) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		writeFakeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: raw})
+}
+
+func writeFakeError(w http.ResponseWriter, status int, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: false, ErrorCode: status, Description: description})
+}
@@ -1,6 +1,12 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/joho/godotenv"
 	"github.com/kiasaty/spendings-tracker/internal/app"
 	"github.com/kiasaty/spendings-tracker/internal/database"
@@ -12,10 +18,19 @@ func main() {
 		panic("Loading .env file failed!")
 	}
 
+	// Cancelled on Ctrl+C or SIGTERM so long running commands can stop cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	databaseClient, err := database.NewDatabaseClient()
 	if err != nil {
 		panic("Setting up database client failed!")
 	}
+	defer func() {
+		if err := databaseClient.Close(); err != nil {
+			fmt.Println("Closing database failed:", err)
+		}
+	}()
 
 	bot, err := telegram.NewTelegramBot()
 	if err != nil {
@@ -27,5 +42,5 @@ func main() {
 		panic("Setting up app failed: " + err.Error())
 	}
 
-	app.HandleCommand()
+	app.HandleCommand(ctx)
 }
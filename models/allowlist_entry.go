@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	AllowlistKindUser = "user"
+	AllowlistKindChat = "chat"
+)
+
+type AllowlistEntry struct {
+	gorm.Model
+	Kind     string
+	EntityId int64
+}
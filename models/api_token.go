@@ -0,0 +1,23 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	APITokenScopeRead  = "read"
+	APITokenScopeWrite = "write"
+)
+
+// APIToken lets clients of the API access the spendings of a chat. Only a
+// hash of the token is stored; the token itself is shown once when it's
+// created.
+type APIToken struct {
+	gorm.Model
+	ChatId int64
+	// UserId is the Telegram ID of the user who created the token
+	UserId int64
+	// Scope is either read, or write to also create, change and delete
+	// spendings
+	Scope string
+	// TokenHash is the hex encoded SHA-256 hash of the token
+	TokenHash string
+}
@@ -0,0 +1,18 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	BudgetPeriodWeekly  = "weekly"
+	BudgetPeriodMonthly = "monthly"
+	BudgetPeriodYearly  = "yearly"
+)
+
+// Budget limits how much a chat wants to spend on a tag per period.
+type Budget struct {
+	gorm.Model
+	ChatId  int64
+	TagName string
+	Amount  float64
+	Period  string
+}
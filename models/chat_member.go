@@ -0,0 +1,27 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ChatMember is a person taking part in a group chat's shared expenses.
+// Members mentioned before they ever wrote in the chat have no UserId yet.
+type ChatMember struct {
+	gorm.Model
+	ChatId      int64
+	UserId      int64
+	UserName    string
+	DisplayName string
+}
+
+func (m *ChatMember) Name() string {
+	if m.DisplayName != "" {
+		return m.DisplayName
+	}
+	if m.UserName != "" {
+		return "@" + m.UserName
+	}
+	return fmt.Sprintf("user %d", m.UserId)
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DashboardSession lets a browser see the dashboard of a chat. It starts as
+// a login link sent by the bot, and once the link is used its token is
+// replaced with the one of the session cookie.
+type DashboardSession struct {
+	gorm.Model
+	ChatId int64
+	// UserId is the Telegram ID of the user who asked for the login link
+	UserId int64
+	// TokenHash is the hex encoded SHA-256 hash of the link's or the
+	// cookie's token
+	TokenHash string
+	ExpiresAt time.Time
+	// LoggedInAt is when the login link was used, nil until then
+	LoggedInAt *time.Time
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	RecurrenceWeekly  = "weekly"
+	RecurrenceMonthly = "monthly"
+	RecurrenceYearly  = "yearly"
+)
+
+// RecurringSpending is a rule that creates a spending every period, e.g.
+// rent on the 1st of every month.
+//
+// Day is the weekday (1 = Monday, 7 = Sunday) for weekly rules and the day
+// of the month otherwise. Yearly rules repeat in the month they start in.
+type RecurringSpending struct {
+	gorm.Model
+	ChatId     int64
+	SenderId   int64
+	SenderName string
+	Name       string
+	Cost       float64
+	Frequency  string
+	Day        int
+	StartsAt   time.Time
+	Tags       []Tag `gorm:"many2many:recurring_spending_tag;"`
+}
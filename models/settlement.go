@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Settlement is a repayment from one chat member to another.
+type Settlement struct {
+	gorm.Model
+	ChatId       int64
+	MessageId    int
+	FromMemberId uint
+	ToMemberId   uint
+	Amount       float64
+	SettledAt    time.Time
+}
@@ -8,10 +8,20 @@ import (
 
 type Spending struct {
 	gorm.Model
-	ChatId      int64
-	MessageId   int
-	Cost        float64
-	Description string
-	SpentAt     time.Time
-	Tags        []Tag `gorm:"many2many:spending_tag;"`
+	ChatId              int64
+	MessageId           int
+	SenderId            int64
+	SenderName          string
+	Cost                float64
+	Description         string
+	SpentAt             time.Time
+	RecurringSpendingId *uint
+	Tags                []Tag `gorm:"many2many:spending_tag;"`
+	Shares              []SpendingShare
+	// ImportHash identifies spendings imported from a file, which have no
+	// message, so importing it again doesn't duplicate them
+	ImportHash *string
+	// ManuallyCorrected marks spendings whose cost, date or tags were set by
+	// hand, which reparsing their description must not overwrite
+	ManuallyCorrected bool
 }
@@ -0,0 +1,11 @@
+package models
+
+import "gorm.io/gorm"
+
+// SpendingShare is the part of a spending a chat member has to pay for.
+type SpendingShare struct {
+	gorm.Model
+	SpendingId uint
+	MemberId   uint
+	Amount     float64
+}
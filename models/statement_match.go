@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StatementMatch links a line of a bank statement to the spending it was
+// reconciled with, so reconciling the statement again skips both.
+type StatementMatch struct {
+	gorm.Model
+	ChatId     int64
+	SpendingId uint
+	// LineId identifies the statement line: the bank's transaction ID or a
+	// hash of the line when the statement has none
+	LineId      string
+	Amount      float64
+	BookedAt    time.Time
+	Description string
+}
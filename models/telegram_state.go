@@ -0,0 +1,10 @@
+package models
+
+import "gorm.io/gorm"
+
+// TelegramState keeps track of how far the bot got in processing updates,
+// so that fetching can resume after a restart.
+type TelegramState struct {
+	gorm.Model
+	LastUpdateId int
+}
@@ -0,0 +1,231 @@
+// Package chart draws charts of spendings as PNG images: pie charts of the
+// shares of tags, bar charts of daily totals and line charts of the trend
+// of monthly totals. Everything is drawn in pure Go with an embedded font,
+// so the images are the same wherever they are drawn.
+package chart
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	gochart "github.com/wcharczuk/go-chart/v2"
+	"github.com/wcharczuk/go-chart/v2/drawing"
+)
+
+const (
+	// Width and Height are the size of the images in pixels
+	Width  = 800
+	Height = 500
+
+	// maxPieSlices is the number of the largest values a pie chart shows,
+	// the others are combined in one slice
+	maxPieSlices = 8
+	// restLabel labels the slice of the values that didn't fit
+	restLabel = "rest"
+
+	titleFontSize = 16
+	// titleHeight is the space above the charts for their title
+	titleHeight = 60
+	// plotWidth is about the width left for the bars next to the axis
+	plotWidth   = Width - 100
+	maxBarWidth = 60
+	// maxTicks is the most lines on the axis of amounts
+	maxTicks = 6
+)
+
+// ErrNoData is returned when there is nothing to draw, e.g. only zero values.
+var ErrNoData = errors.New("nothing to draw")
+
+// Value is a labelled amount, like the total of a tag or of a day.
+type Value struct {
+	Label string
+	Value float64
+}
+
+var (
+	textColor = drawing.ColorFromHex("333333")
+	barColor  = drawing.ColorFromHex("2f6feb")
+	lineColor = drawing.ColorFromHex("2f6feb")
+	fillColor = drawing.ColorFromHex("2f6feb").WithAlpha(48)
+)
+
+// Pie draws the positive values as slices of a pie, largest first, labelled
+// with their label and amount. Values beyond the largest few are combined
+// in one slice.
+func Pie(w io.Writer, title string, values []Value) error {
+	var positive []Value
+	for _, value := range values {
+		if value.Value > 0 {
+			positive = append(positive, value)
+		}
+	}
+	if len(positive) == 0 {
+		return ErrNoData
+	}
+
+	sort.SliceStable(positive, func(i, j int) bool {
+		return positive[i].Value > positive[j].Value
+	})
+	if len(positive) > maxPieSlices {
+		rest := Value{Label: restLabel}
+		for _, value := range positive[maxPieSlices-1:] {
+			rest.Value += value.Value
+		}
+		positive = append(positive[:maxPieSlices-1], rest)
+	}
+
+	// The pie chart draws its title over the pie, so it is drawn above it
+	// instead
+	pie := gochart.PieChart{
+		Width:  Width,
+		Height: Height,
+		Background: gochart.Style{
+			Padding: gochart.Box{Top: titleHeight, Bottom: 20},
+		},
+		Elements: []gochart.Renderable{
+			func(r gochart.Renderer, _ gochart.Box, defaults gochart.Style) {
+				gochart.Draw.TextWithin(r, title, gochart.Box{Top: 20, Right: Width, Bottom: titleHeight}, gochart.Style{
+					Font:                defaults.Font,
+					FontSize:            titleFontSize,
+					FontColor:           textColor,
+					TextHorizontalAlign: gochart.TextHorizontalAlignCenter,
+					TextVerticalAlign:   gochart.TextVerticalAlignTop,
+				})
+			},
+		},
+	}
+	for _, value := range positive {
+		pie.Values = append(pie.Values, gochart.Value{
+			Label: fmt.Sprintf("%s %.2f", value.Label, value.Value),
+			Value: value.Value,
+		})
+	}
+	return render(pie, w)
+}
+
+// Bars draws a bar for each value, in the given order, on an axis starting
+// at zero.
+func Bars(w io.Writer, title string, values []Value) error {
+	highest, ok := highestValue(values)
+	if !ok {
+		return ErrNoData
+	}
+
+	axisMax, ticks := amountTicks(highest)
+	slot := plotWidth / len(values)
+	barWidth := min(slot*3/4, maxBarWidth)
+	bars := gochart.BarChart{
+		Title:      title,
+		TitleStyle: gochart.Style{FontSize: titleFontSize},
+		Width:      Width,
+		Height:     Height,
+		Background: gochart.Style{
+			Padding: gochart.Box{Top: titleHeight, Left: 20, Right: 20, Bottom: 20},
+		},
+		BarWidth:   max(barWidth, 1),
+		BarSpacing: max(slot-barWidth, 1),
+		XAxis:      gochart.Style{FontSize: 8},
+		YAxis: gochart.YAxis{
+			Range: &gochart.ContinuousRange{Min: 0, Max: axisMax},
+			Ticks: ticks,
+		},
+	}
+	for _, value := range values {
+		bars.Bars = append(bars.Bars, gochart.Value{
+			Label: value.Label,
+			Value: value.Value,
+			Style: gochart.Style{FillColor: barColor, StrokeColor: barColor, StrokeWidth: 1},
+		})
+	}
+	return render(bars, w)
+}
+
+// Trend draws the values, in the given order, as a line on an axis starting
+// at zero.
+func Trend(w io.Writer, title string, values []Value) error {
+	if len(values) < 2 {
+		return errors.New("a trend needs at least two values")
+	}
+	highest, ok := highestValue(values)
+	if !ok {
+		return ErrNoData
+	}
+
+	series := gochart.ContinuousSeries{
+		Style: gochart.Style{StrokeColor: lineColor, StrokeWidth: 3, FillColor: fillColor, DotColor: lineColor, DotWidth: 4},
+	}
+	var ticks []gochart.Tick
+	for i, value := range values {
+		series.XValues = append(series.XValues, float64(i))
+		series.YValues = append(series.YValues, value.Value)
+		ticks = append(ticks, gochart.Tick{Value: float64(i), Label: value.Label})
+	}
+
+	axisMax, amounts := amountTicks(highest)
+	trend := gochart.Chart{
+		Title:      title,
+		TitleStyle: gochart.Style{FontSize: titleFontSize},
+		Width:      Width,
+		Height:     Height,
+		Background: gochart.Style{
+			Padding: gochart.Box{Top: titleHeight, Left: 20, Right: 30, Bottom: 20},
+		},
+		XAxis: gochart.XAxis{
+			Range: &gochart.ContinuousRange{Min: 0, Max: float64(len(values) - 1)},
+			Ticks: ticks,
+		},
+		YAxis: gochart.YAxis{
+			Range: &gochart.ContinuousRange{Min: 0, Max: axisMax},
+			Ticks: amounts,
+		},
+		Series: []gochart.Series{series},
+	}
+	return render(trend, w)
+}
+
+// renderable is implemented by the charts of go-chart
+type renderable interface {
+	Render(rp gochart.RendererProvider, w io.Writer) error
+}
+
+func render(chart renderable, w io.Writer) error {
+	if err := chart.Render(gochart.PNG, w); err != nil {
+		return fmt.Errorf("failed to draw chart: %w", err)
+	}
+	return nil
+}
+
+// highestValue returns the highest of the values, and false when there is
+// no positive one.
+func highestValue(values []Value) (float64, bool) {
+	var highest float64
+	for _, value := range values {
+		highest = math.Max(highest, value.Value)
+	}
+	return highest, highest > 0
+}
+
+// amountTicks returns the top of an axis from zero to at least the highest
+// value and its ticks, in round steps like 50, 200 or 2500.
+func amountTicks(highest float64) (float64, []gochart.Tick) {
+	magnitude := math.Pow(10, math.Floor(math.Log10(highest/maxTicks)))
+	step := 10 * magnitude
+	for _, factor := range []float64{1, 2, 2.5, 5} {
+		if highest/(factor*magnitude) <= maxTicks {
+			step = factor * magnitude
+			break
+		}
+	}
+
+	top := math.Ceil(highest/step) * step
+	var ticks []gochart.Tick
+	for i := 0.0; i*step <= top*(1+1e-9); i++ {
+		value := i * step
+		ticks = append(ticks, gochart.Tick{Value: value, Label: strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)})
+	}
+	return top, ticks
+}
@@ -0,0 +1,166 @@
+package chart_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/kiasaty/spendings-tracker/pkg/chart"
+)
+
+// Run "go test ./pkg/chart -update" to regenerate the golden images after
+// intended changes to the charts, and look at them before committing.
+var update = flag.Bool("update", false, "update the golden images in testdata")
+
+const (
+	// channelTolerance and pixelTolerance absorb the small differences of
+	// anti-aliasing between platforms
+	channelTolerance = 8
+	pixelTolerance   = 0.005
+)
+
+var testTags = []chart.Value{
+	{Label: "food", Value: 35},
+	{Label: "work", Value: 25},
+	{Label: "rent", Value: 900},
+	{Label: "other", Value: 5},
+	{Label: "books", Value: 0},
+}
+
+func TestPie(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := chart.Pie(&buffer, "Spendings for May 2024 by tag", testTags); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	verifyGolden(t, "pie.png", buffer.Bytes())
+}
+
+func TestPieCombinesSmallSlices(t *testing.T) {
+	var values []chart.Value
+	for _, label := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		values = append(values, chart.Value{Label: label, Value: 10})
+	}
+
+	var buffer bytes.Buffer
+	if err := chart.Pie(&buffer, "Many tags", values); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	verifyGolden(t, "pie_rest.png", buffer.Bytes())
+}
+
+func TestBars(t *testing.T) {
+	var days []chart.Value
+	for day := 1; day <= 31; day++ {
+		days = append(days, chart.Value{Label: strconv.Itoa(day), Value: float64(day%7) * 12.5})
+	}
+
+	var buffer bytes.Buffer
+	if err := chart.Bars(&buffer, "Spendings for May 2024 by day", days); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	verifyGolden(t, "bars.png", buffer.Bytes())
+}
+
+func TestTrend(t *testing.T) {
+	months := []string{"Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec", "Jan", "Feb", "Mar", "Apr", "May"}
+	totals := []float64{820, 950, 0, 1010, 870, 930, 1250, 890, 860, 905, 900, 40}
+	var values []chart.Value
+	for i, month := range months {
+		values = append(values, chart.Value{Label: month, Value: totals[i]})
+	}
+
+	var buffer bytes.Buffer
+	if err := chart.Trend(&buffer, "Spendings from June 2023 to May 2024", values); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	verifyGolden(t, "trend.png", buffer.Bytes())
+}
+
+func TestNoData(t *testing.T) {
+	zeros := []chart.Value{{Label: "food", Value: 0}, {Label: "rent", Value: 0}}
+	for name, draw := range map[string]func(io.Writer, string, []chart.Value) error{
+		"pie":   chart.Pie,
+		"bars":  chart.Bars,
+		"trend": chart.Trend,
+	} {
+		if err := draw(io.Discard, "Nothing", zeros); !errors.Is(err, chart.ErrNoData) {
+			t.Errorf("%s: expected ErrNoData, got %v", name, err)
+		}
+		if err := draw(io.Discard, "Nothing", nil); err == nil {
+			t.Errorf("%s: expected an error without values", name)
+		}
+	}
+}
+
+// verifyGolden compares the PNG with the golden image in testdata, pixel by
+// pixel within a small tolerance, or overwrites it with -update.
+func verifyGolden(t *testing.T, name string, data []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatalf("Failed to update %s: %v", path, err)
+		}
+		return
+	}
+
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s, run the tests with -update to create it: %v", path, err)
+	}
+	expected, err := png.Decode(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatalf("Failed to decode %s: %v", path, err)
+	}
+	actual, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode the chart: %v", err)
+	}
+
+	if expected.Bounds() != actual.Bounds() {
+		t.Fatalf("Expected a %v image like %s, got %v", expected.Bounds(), path, actual.Bounds())
+	}
+	if actual.Bounds() != image.Rect(0, 0, chart.Width, chart.Height) {
+		t.Errorf("Expected a %dx%d image, got %v", chart.Width, chart.Height, actual.Bounds())
+	}
+
+	bounds := actual.Bounds()
+	different := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if !similarColors(expected.At(x, y), actual.At(x, y)) {
+				different++
+			}
+		}
+	}
+	if share := float64(different) / float64(bounds.Dx()*bounds.Dy()); share > pixelTolerance {
+		written := ""
+		if failed, err := os.CreateTemp("", "*-"+name); err == nil {
+			_, _ = failed.Write(data)
+			_ = failed.Close()
+			written = ", the chart was written to " + failed.Name()
+		}
+		t.Errorf("%d pixels (%.2f%%) differ from %s%s", different, share*100, path, written)
+	}
+}
+
+func similarColors(expected, actual color.Color) bool {
+	er, eg, eb, ea := expected.RGBA()
+	ar, ag, ab, aa := actual.RGBA()
+	for _, pair := range [][2]uint32{{er, ar}, {eg, ag}, {eb, ab}, {ea, aa}} {
+		difference := int(pair[0]>>8) - int(pair[1]>>8)
+		if difference < -channelTolerance || difference > channelTolerance {
+			return false
+		}
+	}
+	return true
+}
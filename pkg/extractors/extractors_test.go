@@ -47,6 +47,51 @@ func TestExtractHashtags(t *testing.T) {
 	}
 }
 
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		testName  string
+		inputText string
+		expected  []string
+	}{
+		{
+			testName:  "it extracts all the mentions in a text",
+			inputText: "dinner 90 @ali @sara",
+			expected:  []string{"ali", "sara"},
+		},
+		{
+			testName:  "it returns an empty list when there is no mention in a text",
+			inputText: "dinner 90 #food",
+			expected:  []string{},
+		},
+		{
+			testName:  "it extracts a mention at the start of a text",
+			inputText: "@ali dinner 90",
+			expected:  []string{"ali"},
+		},
+		{
+			testName:  "it ignores e-mail addresses",
+			inputText: "domain 12 billed to kia@example.com (@sara)",
+			expected:  []string{"sara"},
+		},
+		{
+			testName:  "it extracts a mention once when it's repeated",
+			inputText: "dinner 90 @ali @sara @ali",
+			expected:  []string{"ali", "sara"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			mentions := extractors.ExtractMentions(tt.inputText)
+
+			if !reflect.DeepEqual(mentions, tt.expected) {
+				t.Fail()
+			}
+		})
+
+	}
+}
+
 func TestExtractPrices(t *testing.T) {
 	tests := []struct {
 		testName      string
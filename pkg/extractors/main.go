@@ -3,6 +3,7 @@ package extractors
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"strconv"
 	"time"
 )
@@ -23,6 +24,27 @@ func ExtractHashtags(text string) []string {
 	return hashtags
 }
 
+// ExtractMentions returns the user names mentioned in a text, each once. An
+// "@" only starts a mention at the start of the text or after a character
+// that can't be part of a word, so e-mail addresses aren't mentions.
+func ExtractMentions(text string) []string {
+	pattern := `(?:^|[^\w@])@(\w+)`
+
+	regex := regexp.MustCompile(pattern)
+
+	matches := regex.FindAllStringSubmatch(text, -1)
+
+	mentions := make([]string, 0, len(matches))
+
+	for _, match := range matches {
+		if !slices.Contains(mentions, match[1]) {
+			mentions = append(mentions, match[1])
+		}
+	}
+
+	return mentions
+}
+
 func ExtractPrice(text string) (float64, error) {
 	pattern := `\b\d+(\.\d+)?\b`
 
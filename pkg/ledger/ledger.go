@@ -0,0 +1,308 @@
+package ledger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The accounts of expenses and payments when there is no mapping for them
+const (
+	DefaultExpenseAccount = "Expenses:Other"
+	DefaultAssetAccount   = "Assets:Cash"
+)
+
+// Transaction is a spending paid from an asset account for an expense
+// account.
+type Transaction struct {
+	Date        time.Time
+	Payee       string
+	Description string
+	Tags        []string
+	// Metadata is written along with the transaction, e.g. the ID of the
+	// spending it was exported from
+	Metadata       []Metadata
+	Amount         float64
+	Currency       string
+	ExpenseAccount string
+	AssetAccount   string
+}
+
+// Metadata is a key and value attached to a transaction.
+type Metadata struct {
+	Key   string
+	Value string
+}
+
+// Accounts maps tags, chats and payers to accounts.
+type Accounts struct {
+	Tags    map[string]string
+	Chats   map[int64]string
+	Payers  map[int64]string
+	Expense string
+	Asset   string
+}
+
+// ParseAccounts reads a mapping of accounts with one "key = account" per
+// line. Keys are tag.<name>, chat.<chat ID>, payer.<user ID>, and expense and
+// asset for the accounts of everything not mapped otherwise. Empty lines and
+// lines starting with # are ignored.
+func ParseAccounts(r io.Reader) (*Accounts, error) {
+	accounts := &Accounts{
+		Tags:   make(map[string]string),
+		Chats:  make(map[int64]string),
+		Payers: make(map[int64]string),
+	}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		key, account, ok := strings.Cut(text, "=")
+		key, account = strings.TrimSpace(key), strings.TrimSpace(account)
+		if !ok || key == "" || account == "" {
+			return nil, fmt.Errorf("line %d: expected key = account", line)
+		}
+
+		kind, name, _ := strings.Cut(key, ".")
+		switch kind {
+		case "tag":
+			accounts.Tags[name] = account
+		case "chat", "payer":
+			id, err := strconv.ParseInt(name, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s ID %q", line, kind, name)
+			}
+			if kind == "chat" {
+				accounts.Chats[id] = account
+			} else {
+				accounts.Payers[id] = account
+			}
+		case "expense":
+			accounts.Expense = account
+		case "asset":
+			accounts.Asset = account
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %q, expected tag.<name>, chat.<ID>, payer.<ID>, expense or asset", line, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// ExpenseAccount returns the account of an expense with the given tags.
+// Mapped tags win over the others, and more specific tags over less specific
+// ones. Tags without a mapping become accounts below Expenses, with
+// underscores separating levels: food_groceries is Expenses:Food:Groceries.
+func (a *Accounts) ExpenseAccount(tags []string) string {
+	best, bestMapped, bestDepth := "", false, 0
+	for _, tag := range tags {
+		_, mapped := a.tagAccount(tag)
+		depth := len(strings.FieldsFunc(tag, isTagSeparator))
+		better := best == "" ||
+			(mapped && !bestMapped) ||
+			(mapped == bestMapped && (depth > bestDepth || (depth == bestDepth && tag < best)))
+		if better {
+			best, bestMapped, bestDepth = tag, mapped, depth
+		}
+	}
+
+	if best == "" {
+		if a != nil && a.Expense != "" {
+			return a.Expense
+		}
+		return DefaultExpenseAccount
+	}
+	account, _ := a.tagAccount(best)
+	return account
+}
+
+func (a *Accounts) tagAccount(tag string) (string, bool) {
+	if a != nil {
+		if account, ok := a.Tags[tag]; ok {
+			return account, true
+		}
+	}
+
+	parts := []string{"Expenses"}
+	for _, part := range strings.FieldsFunc(tag, isTagSeparator) {
+		first, size := utf8.DecodeRuneInString(part)
+		parts = append(parts, string(unicode.ToUpper(first))+part[size:])
+	}
+	if len(parts) == 1 {
+		return DefaultExpenseAccount, false
+	}
+	return strings.Join(parts, ":"), false
+}
+
+func isTagSeparator(r rune) bool {
+	return r == '_'
+}
+
+// AssetAccount returns the account a payer paid from in a chat. Payers are
+// mapped before chats.
+func (a *Accounts) AssetAccount(chatID, payerID int64) string {
+	if a != nil {
+		if account, ok := a.Payers[payerID]; ok && payerID != 0 {
+			return account
+		}
+		if account, ok := a.Chats[chatID]; ok {
+			return account
+		}
+		if a.Asset != "" {
+			return a.Asset
+		}
+	}
+	return DefaultAssetAccount
+}
+
+// hledgerText replaces the characters with a meaning in descriptions:
+// semicolons start comments and pipes separate payee and note.
+var hledgerText = strings.NewReplacer(";", ",", "|", "/")
+
+// WriteHledger writes transactions as an hledger journal, which ledger reads
+// as well.
+func WriteHledger(w io.Writer, transactions []Transaction) error {
+	out := bufio.NewWriter(w)
+
+	for i, transaction := range transactions {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+
+		description := hledgerText.Replace(singleLine(transaction.Description))
+		if transaction.Payee != "" {
+			description = hledgerText.Replace(transaction.Payee) + " | " + description
+		}
+		fmt.Fprintf(out, "%s %s\n", transaction.Date.Format("2006-01-02"), strings.TrimSpace(description))
+
+		var tags []string
+		for _, metadata := range transaction.Metadata {
+			tags = append(tags, metadata.Key+":"+strings.ReplaceAll(metadata.Value, ",", " "))
+		}
+		for _, tag := range transaction.Tags {
+			tags = append(tags, tag+":")
+		}
+		if len(tags) > 0 {
+			fmt.Fprintf(out, "    ; %s\n", strings.Join(tags, ", "))
+		}
+
+		amount := formatAmount(transaction.Amount)
+		if transaction.Currency != "" {
+			amount += " " + transaction.Currency
+		}
+		fmt.Fprintf(out, "    %s  %s\n", transaction.ExpenseAccount, amount)
+		fmt.Fprintf(out, "    %s\n", transaction.AssetAccount)
+	}
+
+	return out.Flush()
+}
+
+var (
+	beancountTagPattern         = regexp.MustCompile(`^[A-Za-z0-9\-_/.]+$`)
+	beancountAccountPartPattern = regexp.MustCompile(`[^\pL\pN\-]`)
+	beancountMetadataKeyPattern = regexp.MustCompile(`^[a-z][a-zA-Z0-9\-_]*$`)
+)
+
+// WriteBeancount writes transactions as a Beancount file, opening all their
+// accounts on the date of the first transaction. Beancount needs the
+// currency of every transaction.
+func WriteBeancount(w io.Writer, transactions []Transaction) error {
+	accounts := make(map[string]bool)
+	var first time.Time
+	for _, transaction := range transactions {
+		if transaction.Currency == "" {
+			return errors.New("beancount needs a currency for every transaction")
+		}
+		accounts[beancountAccount(transaction.ExpenseAccount)] = true
+		accounts[beancountAccount(transaction.AssetAccount)] = true
+		if first.IsZero() || transaction.Date.Before(first) {
+			first = transaction.Date
+		}
+	}
+
+	out := bufio.NewWriter(w)
+
+	names := make([]string, 0, len(accounts))
+	for name := range accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "%s open %s\n", first.Format("2006-01-02"), name)
+	}
+
+	for _, transaction := range transactions {
+		fmt.Fprintln(out)
+
+		header := transaction.Date.Format("2006-01-02") + " *"
+		if transaction.Payee != "" {
+			header += " " + beancountString(transaction.Payee)
+		}
+		header += " " + beancountString(singleLine(transaction.Description))
+		for _, tag := range transaction.Tags {
+			if beancountTagPattern.MatchString(tag) {
+				header += " #" + tag
+			}
+		}
+		fmt.Fprintln(out, header)
+
+		for _, metadata := range transaction.Metadata {
+			if beancountMetadataKeyPattern.MatchString(metadata.Key) {
+				fmt.Fprintf(out, "  %s: %s\n", metadata.Key, beancountString(metadata.Value))
+			}
+		}
+		fmt.Fprintf(out, "  %s  %s %s\n", beancountAccount(transaction.ExpenseAccount), formatAmount(transaction.Amount), transaction.Currency)
+		fmt.Fprintf(out, "  %s\n", beancountAccount(transaction.AssetAccount))
+	}
+
+	return out.Flush()
+}
+
+// beancountAccount turns an account into one Beancount accepts, whose parts
+// start with a capital letter or number and only contain letters, numbers
+// and dashes.
+func beancountAccount(account string) string {
+	parts := strings.Split(account, ":")
+	for i, part := range parts {
+		part = beancountAccountPartPattern.ReplaceAllString(strings.TrimSpace(part), "-")
+		first, size := utf8.DecodeRuneInString(part)
+		if first == utf8.RuneError {
+			part = "X"
+		} else if !unicode.IsDigit(first) {
+			part = string(unicode.ToUpper(first)) + part[size:]
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, ":")
+}
+
+func beancountString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// singleLine returns the first line of text.
+func singleLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return strings.TrimSpace(line)
+}
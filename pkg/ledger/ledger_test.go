@@ -0,0 +1,164 @@
+package ledger_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/pkg/ledger"
+)
+
+const testAccounts = `# Accounts of the flat
+tag.food = Expenses:Groceries
+tag.rent = Expenses:Housing:Rent
+chat.-100 = Assets:Flat:Cash
+payer.7 = Liabilities:CreditCard:Sara
+expense = Expenses:Misc
+`
+
+func TestParseAccounts(t *testing.T) {
+	accounts, err := ledger.ParseAccounts(strings.NewReader(testAccounts))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if accounts.Tags["rent"] != "Expenses:Housing:Rent" || accounts.Chats[-100] != "Assets:Flat:Cash" ||
+		accounts.Payers[7] != "Liabilities:CreditCard:Sara" || accounts.Expense != "Expenses:Misc" || accounts.Asset != "" {
+		t.Errorf("Unexpected accounts %+v", accounts)
+	}
+
+	for _, invalid := range []string{"tag.food", "chat.flat = Assets:Flat", "category.food = Expenses:Food"} {
+		if _, err := ledger.ParseAccounts(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestExpenseAccount(t *testing.T) {
+	accounts, err := ledger.ParseAccounts(strings.NewReader(testAccounts))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		testName string
+		accounts *ledger.Accounts
+		tags     []string
+		expected string
+	}{
+		{"it nests hierarchical tags", nil, []string{"food_groceries"}, "Expenses:Food:Groceries"},
+		{"it prefers more specific tags", nil, []string{"work", "food_groceries"}, "Expenses:Food:Groceries"},
+		{"it uses the first tag by name when equally specific", nil, []string{"work", "food"}, "Expenses:Food"},
+		{"it uses the default account without tags", nil, nil, "Expenses:Other"},
+		{"it prefers mapped tags", accounts, []string{"food", "transport_taxi"}, "Expenses:Groceries"},
+		{"it uses the mapped default account", accounts, nil, "Expenses:Misc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if account := tt.accounts.ExpenseAccount(tt.tags); account != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, account)
+			}
+		})
+	}
+}
+
+func TestAssetAccount(t *testing.T) {
+	accounts, err := ledger.ParseAccounts(strings.NewReader(testAccounts))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		testName string
+		accounts *ledger.Accounts
+		chatID   int64
+		payerID  int64
+		expected string
+	}{
+		{"it prefers the payer", accounts, -100, 7, "Liabilities:CreditCard:Sara"},
+		{"it uses the chat of other payers", accounts, -100, 8, "Assets:Flat:Cash"},
+		{"it uses the default account", accounts, 123, 8, "Assets:Cash"},
+		{"it works without a mapping", nil, -100, 7, "Assets:Cash"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if account := tt.accounts.AssetAccount(tt.chatID, tt.payerID); account != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, account)
+			}
+		})
+	}
+}
+
+var testTransactions = []ledger.Transaction{
+	{
+		Date:           time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC),
+		Payee:          "Sara",
+		Description:    "Groceries; \"organic\" 23.40 #food_groceries\nfrom the market",
+		Tags:           []string{"food_groceries"},
+		Metadata:       []ledger.Metadata{{Key: "spending_id", Value: "1"}},
+		Amount:         23.4,
+		Currency:       "EUR",
+		ExpenseAccount: "Expenses:Food:Groceries",
+		AssetAccount:   "Assets:Cash",
+	},
+	{
+		Date:           time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC),
+		Description:    "Taxi 12",
+		Amount:         12,
+		Currency:       "EUR",
+		ExpenseAccount: "Expenses:Other",
+		AssetAccount:   "Assets:flat cash",
+	},
+}
+
+func TestWriteHledger(t *testing.T) {
+	var out bytes.Buffer
+	if err := ledger.WriteHledger(&out, testTransactions); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `2024-05-10 Sara | Groceries, "organic" 23.40 #food_groceries
+    ; spending_id:1, food_groceries:
+    Expenses:Food:Groceries  23.40 EUR
+    Assets:Cash
+
+2024-05-11 Taxi 12
+    Expenses:Other  12.00 EUR
+    Assets:flat cash
+`
+	if out.String() != expected {
+		t.Errorf("Expected journal:\n%s\nGot:\n%s", expected, out.String())
+	}
+}
+
+func TestWriteBeancount(t *testing.T) {
+	var out bytes.Buffer
+	if err := ledger.WriteBeancount(&out, testTransactions); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `2024-05-10 open Assets:Cash
+2024-05-10 open Assets:Flat-cash
+2024-05-10 open Expenses:Food:Groceries
+2024-05-10 open Expenses:Other
+
+2024-05-10 * "Sara" "Groceries; \"organic\" 23.40 #food_groceries" #food_groceries
+  spending_id: "1"
+  Expenses:Food:Groceries  23.40 EUR
+  Assets:Cash
+
+2024-05-11 * "Taxi 12"
+  Expenses:Other  12.00 EUR
+  Assets:Flat-cash
+`
+	if out.String() != expected {
+		t.Errorf("Expected Beancount file:\n%s\nGot:\n%s", expected, out.String())
+	}
+
+	withoutCurrency := []ledger.Transaction{{Amount: 1, ExpenseAccount: "Expenses:Other", AssetAccount: "Assets:Cash"}}
+	if err := ledger.WriteBeancount(&out, withoutCurrency); err == nil {
+		t.Error("Expected an error for a transaction without a currency")
+	}
+}
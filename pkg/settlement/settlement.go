@@ -0,0 +1,102 @@
+package settlement
+
+import (
+	"math"
+	"sort"
+)
+
+// Transfer is a payment from a debtor to a creditor that settles
+// (part of) their balances.
+type Transfer struct {
+	From   uint
+	To     uint
+	Amount float64
+}
+
+// Split divides an amount into n shares that add up to exactly the amount.
+// Leftover cents go to the first shares.
+func Split(amount float64, n int) []float64 {
+	if n <= 0 {
+		return nil
+	}
+
+	total := ToCents(amount)
+	base := total / int64(n)
+	remainder := total % int64(n)
+
+	shares := make([]float64, n)
+	for i := range shares {
+		cents := base
+		if int64(i) < remainder {
+			cents++
+		}
+		shares[i] = FromCents(cents)
+	}
+	return shares
+}
+
+// Minimize returns a short list of transfers that brings every balance to
+// zero. Positive balances are owed money, negative balances owe money.
+//
+// The largest debtor repeatedly pays the largest creditor, which needs at
+// most n-1 transfers for n people.
+func Minimize(balances map[uint]float64) []Transfer {
+	type party struct {
+		id    uint
+		cents int64
+	}
+
+	var creditors, debtors []party
+	for id, balance := range balances {
+		cents := ToCents(balance)
+		switch {
+		case cents > 0:
+			creditors = append(creditors, party{id, cents})
+		case cents < 0:
+			debtors = append(debtors, party{id, -cents})
+		}
+	}
+
+	byAmount := func(parties []party) func(i, j int) bool {
+		return func(i, j int) bool {
+			if parties[i].cents != parties[j].cents {
+				return parties[i].cents > parties[j].cents
+			}
+			return parties[i].id < parties[j].id
+		}
+	}
+
+	var transfers []Transfer
+	for len(creditors) > 0 && len(debtors) > 0 {
+		sort.Slice(creditors, byAmount(creditors))
+		sort.Slice(debtors, byAmount(debtors))
+
+		amount := min(creditors[0].cents, debtors[0].cents)
+		transfers = append(transfers, Transfer{
+			From:   debtors[0].id,
+			To:     creditors[0].id,
+			Amount: FromCents(amount),
+		})
+
+		creditors[0].cents -= amount
+		debtors[0].cents -= amount
+		if creditors[0].cents == 0 {
+			creditors = creditors[1:]
+		}
+		if debtors[0].cents == 0 {
+			debtors = debtors[1:]
+		}
+	}
+
+	return transfers
+}
+
+// ToCents returns an amount in whole cents, so sums of amounts are exact
+func ToCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// FromCents returns the amount of a number of cents
+func FromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
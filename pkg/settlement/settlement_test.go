@@ -0,0 +1,92 @@
+package settlement_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kiasaty/spendings-tracker/pkg/settlement"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		testName string
+		amount   float64
+		n        int
+		expected []float64
+	}{
+		{
+			testName: "it splits an amount evenly",
+			amount:   90,
+			n:        3,
+			expected: []float64{30, 30, 30},
+		},
+		{
+			testName: "it gives leftover cents to the first shares",
+			amount:   100,
+			n:        3,
+			expected: []float64{33.34, 33.33, 33.33},
+		},
+		{
+			testName: "it returns nothing when there is nobody to split between",
+			amount:   10,
+			n:        0,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			shares := settlement.Split(tt.amount, tt.n)
+
+			if !reflect.DeepEqual(shares, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, shares)
+			}
+		})
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	tests := []struct {
+		testName string
+		balances map[uint]float64
+		expected []settlement.Transfer
+	}{
+		{
+			testName: "it settles a single debt directly",
+			balances: map[uint]float64{1: 30, 2: -30},
+			expected: []settlement.Transfer{{From: 2, To: 1, Amount: 30}},
+		},
+		{
+			testName: "it lets several debtors pay one creditor",
+			balances: map[uint]float64{1: 60, 2: -30, 3: -30},
+			expected: []settlement.Transfer{
+				{From: 2, To: 1, Amount: 30},
+				{From: 3, To: 1, Amount: 30},
+			},
+		},
+		{
+			testName: "it needs at most n-1 transfers",
+			balances: map[uint]float64{1: 50, 2: 10, 3: -45, 4: -15},
+			expected: []settlement.Transfer{
+				{From: 3, To: 1, Amount: 45},
+				{From: 4, To: 2, Amount: 10},
+				{From: 4, To: 1, Amount: 5},
+			},
+		},
+		{
+			testName: "it returns nothing when everyone is settled up",
+			balances: map[uint]float64{1: 0, 2: 0},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			transfers := settlement.Minimize(tt.balances)
+
+			if !reflect.DeepEqual(transfers, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, transfers)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package statement
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type camtDocument struct {
+	Statements []struct {
+		Currency string      `xml:"Acct>Ccy"`
+		Entries  []camtEntry `xml:"Ntry"`
+	} `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtEntry struct {
+	Amount struct {
+		Value    string `xml:",chardata"`
+		Currency string `xml:"Ccy,attr"`
+	} `xml:"Amt"`
+	CreditDebit string `xml:"CdtDbtInd"`
+	// Status is the code itself up to version 8 and in a Cd element after
+	Status struct {
+		Value string `xml:",chardata"`
+		Code  string `xml:"Cd"`
+	} `xml:"Sts"`
+	BookingDate    camtDate `xml:"BookgDt"`
+	ValueDate      camtDate `xml:"ValDt"`
+	Reference      string   `xml:"AcctSvcrRef"`
+	EntryReference string   `xml:"NtryRef"`
+	AdditionalInfo string   `xml:"AddtlNtryInf"`
+	Details        []struct {
+		Reference    string   `xml:"Refs>AcctSvcrRef"`
+		Creditor     string   `xml:"RltdPties>Cdtr>Nm"`
+		CreditorV8   string   `xml:"RltdPties>Cdtr>Pty>Nm"`
+		Debtor       string   `xml:"RltdPties>Dbtr>Nm"`
+		DebtorV8     string   `xml:"RltdPties>Dbtr>Pty>Nm"`
+		Unstructured []string `xml:"RmtInf>Ustrd"`
+	} `xml:"NtryDtls>TxDtls"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+// ParseCAMT053 reads the booked entries of ISO 20022 CAMT.053 bank to
+// customer statements. Entries batching several transactions are read as a
+// single line.
+func ParseCAMT053(r io.Reader) ([]Line, error) {
+	var document camtDocument
+	if err := xml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, fmt.Errorf("failed to read CAMT.053: %w", err)
+	}
+
+	var lines []Line
+	for _, statement := range document.Statements {
+		for i, entry := range statement.Entries {
+			status := strings.TrimSpace(entry.Status.Code)
+			if status == "" {
+				status = strings.TrimSpace(entry.Status.Value)
+			}
+			if status == "PDNG" || status == "INFO" {
+				continue
+			}
+
+			line, err := camtLine(entry, statement.Currency)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i+1, err)
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, nil
+}
+
+func camtLine(entry camtEntry, currency string) (Line, error) {
+	date, err := entry.BookingDate.parse()
+	if err != nil {
+		if date, err = entry.ValueDate.parse(); err != nil {
+			return Line{}, err
+		}
+	}
+
+	amount, err := parseAmount(entry.Amount.Value)
+	if err != nil {
+		return Line{}, err
+	}
+	if entry.CreditDebit == "DBIT" {
+		amount = -amount
+	}
+	if entry.Amount.Currency != "" {
+		currency = entry.Amount.Currency
+	}
+
+	line := Line{
+		ID:       entry.Reference,
+		Date:     date,
+		Amount:   amount,
+		Currency: currency,
+	}
+	if line.ID == "" {
+		line.ID = entry.EntryReference
+	}
+
+	var parts []string
+	if len(entry.Details) > 0 {
+		details := entry.Details[0]
+		if line.ID == "" {
+			line.ID = details.Reference
+		}
+		// The other party is the creditor of payments and the debtor of
+		// money coming in
+		if amount < 0 {
+			parts = append(parts, details.Creditor, details.CreditorV8)
+		} else {
+			parts = append(parts, details.Debtor, details.DebtorV8)
+		}
+		parts = append(parts, details.Unstructured...)
+	}
+	parts = append(parts, entry.AdditionalInfo)
+	line.Description = joinDescription(parts...)
+
+	return line, nil
+}
+
+func (d camtDate) parse() (time.Time, error) {
+	value := d.Date
+	if value == "" && len(d.DateTime) >= 10 {
+		value = d.DateTime[:10]
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid booking date %q", value)
+	}
+	return date, nil
+}
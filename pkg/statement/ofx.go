@@ -0,0 +1,87 @@
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ofxTagPattern matches the tags of OFX files along with the value following
+// them. Version 1 files are SGML, whose elements have no closing tags, and
+// version 2 files are XML, so values end at the next tag either way.
+var ofxTagPattern = regexp.MustCompile(`<(/?)([A-Za-z0-9.]+)>([^<]*)`)
+
+// ParseOFX reads the transactions of bank and credit card statements in OFX
+// or QFX files, versions 1 and 2.
+func ParseOFX(r io.Reader) ([]Line, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OFX: %w", err)
+	}
+	if !strings.Contains(strings.ToUpper(string(data)), "<OFX>") {
+		return nil, errors.New("not an OFX file")
+	}
+
+	var lines []Line
+	var currency string
+	var transaction map[string]string
+	for _, match := range ofxTagPattern.FindAllStringSubmatch(string(data), -1) {
+		closing, name := match[1] == "/", strings.ToUpper(match[2])
+		value := strings.TrimSpace(html.UnescapeString(match[3]))
+
+		switch {
+		case name == "STMTTRN" && !closing:
+			transaction = make(map[string]string)
+		case name == "STMTTRN" && closing:
+			if transaction == nil {
+				continue
+			}
+			line, err := ofxLine(transaction, currency)
+			if err != nil {
+				return nil, fmt.Errorf("transaction %d: %w", len(lines)+1, err)
+			}
+			lines = append(lines, line)
+			transaction = nil
+		case name == "CURDEF" && !closing:
+			currency = value
+		case transaction != nil && !closing && value != "":
+			transaction[name] = value
+		}
+	}
+
+	return lines, nil
+}
+
+func ofxLine(transaction map[string]string, currency string) (Line, error) {
+	date, err := parseOFXDate(transaction["DTPOSTED"])
+	if err != nil {
+		return Line{}, err
+	}
+	amount, err := parseAmount(transaction["TRNAMT"])
+	if err != nil {
+		return Line{}, err
+	}
+
+	return Line{
+		ID:          transaction["FITID"],
+		Date:        date,
+		Amount:      amount,
+		Currency:    currency,
+		Description: joinDescription(transaction["NAME"], transaction["MEMO"]),
+	}, nil
+}
+
+// parseOFXDate parses the date of an OFX date and time like
+// 20240511120000.000[-5:EST]. Only the date is kept.
+func parseOFXDate(value string) (time.Time, error) {
+	if len(value) >= 8 {
+		if date, err := time.ParseInLocation("20060102", value[:8], time.Local); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
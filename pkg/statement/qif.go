@@ -0,0 +1,127 @@
+package statement
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseQIF reads the transactions of a QIF file. Dates with slashes are
+// read month first, like 05/11/2024 or 5/11'24, dates with dots day first,
+// like 11.05.2024, and dates with dashes year first.
+func ParseQIF(r io.Reader) ([]Line, error) {
+	scanner := bufio.NewScanner(r)
+
+	var lines []Line
+	fields := make(map[byte]string)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if number == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if text == "" || strings.HasPrefix(text, "!") {
+			continue
+		}
+
+		if text[0] != '^' {
+			// Only the first of repeated fields, like the categories of
+			// split transactions, is kept
+			if _, ok := fields[text[0]]; !ok {
+				fields[text[0]] = strings.TrimSpace(text[1:])
+			}
+			continue
+		}
+
+		if len(fields) > 0 {
+			line, err := qifLine(fields)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", number, err)
+			}
+			lines = append(lines, line)
+		}
+		fields = make(map[byte]string)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read QIF: %w", err)
+	}
+	if len(fields) > 0 {
+		return nil, fmt.Errorf("line %d: transaction isn't ended with ^", number)
+	}
+
+	return lines, nil
+}
+
+func qifLine(fields map[byte]string) (Line, error) {
+	date, err := parseQIFDate(fields['D'])
+	if err != nil {
+		return Line{}, err
+	}
+
+	value, ok := fields['T']
+	if !ok {
+		value = fields['U']
+	}
+	amount, err := parseAmount(value)
+	if err != nil {
+		return Line{}, err
+	}
+
+	return Line{
+		Date:        date,
+		Amount:      amount,
+		Description: joinDescription(fields['P'], fields['M']),
+	}, nil
+}
+
+func parseQIFDate(value string) (time.Time, error) {
+	invalid := fmt.Errorf("invalid date %q", value)
+
+	normalized := strings.ReplaceAll(strings.ReplaceAll(value, " ", ""), "'", "/")
+	var separator string
+	for _, candidate := range []string{"/", ".", "-"} {
+		if strings.Contains(normalized, candidate) {
+			separator = candidate
+			break
+		}
+	}
+	if separator == "" {
+		return time.Time{}, invalid
+	}
+
+	parts := strings.Split(normalized, separator)
+	if len(parts) != 3 {
+		return time.Time{}, invalid
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		numbers[i] = n
+	}
+
+	var year, month, day int
+	switch separator {
+	case "/":
+		month, day, year = numbers[0], numbers[1], numbers[2]
+	case ".":
+		day, month, year = numbers[0], numbers[1], numbers[2]
+	default:
+		year, month, day = numbers[0], numbers[1], numbers[2]
+	}
+	if year < 100 {
+		year += 2000
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+	if date.Month() != time.Month(month) || date.Day() != day {
+		return time.Time{}, invalid
+	}
+	return date, nil
+}
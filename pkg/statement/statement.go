@@ -0,0 +1,121 @@
+package statement
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The formats of bank statements that can be parsed
+const (
+	FormatOFX     = "ofx"
+	FormatQIF     = "qif"
+	FormatCAMT053 = "camt053"
+)
+
+// Line is a transaction on a bank statement. Amount is negative for money
+// leaving the account, like card payments, and positive for money coming in.
+type Line struct {
+	// ID identifies the line among all lines of the account: the bank's
+	// transaction ID when the statement has one, otherwise a hash of the
+	// line, so parsing the same statement again gives the same IDs
+	ID          string
+	Date        time.Time
+	Amount      float64
+	Currency    string
+	Description string
+}
+
+// Parse reads the lines of a statement in the given format.
+func Parse(r io.Reader, format string) ([]Line, error) {
+	var lines []Line
+	var err error
+	switch format {
+	case FormatOFX:
+		lines, err = ParseOFX(r)
+	case FormatQIF:
+		lines, err = ParseQIF(r)
+	case FormatCAMT053:
+		lines, err = ParseCAMT053(r)
+	default:
+		return nil, fmt.Errorf("unknown statement format %q, expected ofx, qif or camt053", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	assignIDs(lines)
+	return lines, nil
+}
+
+// FormatOf returns the format of a statement file by its extension, or ""
+// when it isn't known.
+func FormatOf(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".ofx", ".qfx":
+		return FormatOFX
+	case ".qif":
+		return FormatQIF
+	case ".xml", ".camt", ".053":
+		return FormatCAMT053
+	default:
+		return ""
+	}
+}
+
+// assignIDs gives lines without a transaction ID one derived from their
+// contents. Identical lines are told apart by how many came before them.
+func assignIDs(lines []Line) {
+	occurrences := make(map[string]int)
+	for i := range lines {
+		if lines[i].ID != "" {
+			continue
+		}
+
+		content := strings.Join([]string{
+			lines[i].Date.Format("2006-01-02"),
+			strconv.FormatFloat(lines[i].Amount, 'f', 2, 64),
+			lines[i].Description,
+		}, "\x00")
+		sum := sha256.Sum256([]byte(content + "\x00" + strconv.Itoa(occurrences[content])))
+		occurrences[content]++
+		lines[i].ID = hex.EncodeToString(sum[:])
+	}
+}
+
+// parseAmount parses an amount, ignoring spaces and thousands separators.
+// The decimal separator is the comma when it comes after all points, like
+// in 1.234,56, unless it is the only separator and followed by exactly three
+// digits, like in 1,234.
+func parseAmount(value string) (float64, error) {
+	cleaned := strings.ReplaceAll(strings.TrimSpace(value), " ", "")
+	point, comma := strings.LastIndex(cleaned, "."), strings.LastIndex(cleaned, ",")
+	if comma > point && (point >= 0 || len(cleaned)-comma-1 != 3) {
+		cleaned = strings.ReplaceAll(cleaned, ".", "")
+		cleaned = strings.Replace(cleaned, ",", ".", 1)
+	}
+	cleaned = strings.ReplaceAll(cleaned, ",", "")
+
+	amount, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	return amount, nil
+}
+
+// joinDescription joins the non-empty parts of a description.
+func joinDescription(parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		part = strings.Join(strings.Fields(part), " ")
+		if part != "" && (len(kept) == 0 || kept[len(kept)-1] != part) {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, " - ")
+}
@@ -0,0 +1,192 @@
+package statement_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kiasaty/spendings-tracker/pkg/statement"
+)
+
+const testOFX = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>EUR
+<BANKTRANLIST>
+<DTSTART>20240501
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240511120000.000[-5:EST]
+<TRNAMT>-25.50
+<FITID>2024051101
+<NAME>Lunch &amp; Co
+<MEMO>Card payment
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240512
+<TRNAMT>1500,00
+<FITID>2024051201
+<NAME>Salary
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const testQIF = "!Type:Bank\r\n" +
+	"D05/11/2024\r\nT-25.50\r\nPLunch\r\nMCard payment\r\n^\r\n" +
+	"D5/12'24\r\nT-1,200.00\r\nPRent\r\n^\r\n" +
+	"D5/12'24\r\nT-1,200.00\r\nPRent\r\n^\r\n"
+
+const testCAMT053 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08">
+  <BkToCstmrStmt>
+    <Stmt>
+      <Acct><Ccy>EUR</Ccy></Acct>
+      <Ntry>
+        <Amt Ccy="EUR">25.50</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><Dt>2024-05-11</Dt></BookgDt>
+        <AcctSvcrRef>REF-1</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <RltdPties><Cdtr><Pty><Nm>Lunch Co</Nm></Pty></Cdtr></RltdPties>
+          <RmtInf><Ustrd>Card payment</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">12.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts><Cd>PDNG</Cd></Sts>
+        <BookgDt><Dt>2024-05-12</Dt></BookgDt>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="USD">100</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><DtTm>2024-05-13T08:00:00+02:00</DtTm></BookgDt>
+        <AddtlNtryInf>Refund</AddtlNtryInf>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>
+`
+
+func date(month time.Month, day int) time.Time {
+	return time.Date(2024, month, day, 0, 0, 0, 0, time.Local)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		testName string
+		format   string
+		data     string
+		expected []statement.Line
+	}{
+		{
+			testName: "OFX",
+			format:   statement.FormatOFX,
+			data:     testOFX,
+			expected: []statement.Line{
+				{ID: "2024051101", Date: date(5, 11), Amount: -25.5, Currency: "EUR", Description: "Lunch & Co - Card payment"},
+				{ID: "2024051201", Date: date(5, 12), Amount: 1500, Currency: "EUR", Description: "Salary"},
+			},
+		},
+		{
+			testName: "QIF",
+			format:   statement.FormatQIF,
+			data:     testQIF,
+			expected: []statement.Line{
+				{Date: date(5, 11), Amount: -25.5, Description: "Lunch - Card payment"},
+				{Date: date(5, 12), Amount: -1200, Description: "Rent"},
+				{Date: date(5, 12), Amount: -1200, Description: "Rent"},
+			},
+		},
+		{
+			testName: "CAMT.053",
+			format:   statement.FormatCAMT053,
+			data:     testCAMT053,
+			expected: []statement.Line{
+				{ID: "REF-1", Date: date(5, 11), Amount: -25.5, Currency: "EUR", Description: "Lunch Co - Card payment"},
+				{Date: date(5, 13), Amount: 100, Currency: "USD", Description: "Refund"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			lines, err := statement.Parse(strings.NewReader(test.data), test.format)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(lines) != len(test.expected) {
+				t.Fatalf("Expected %d lines, got %d: %+v", len(test.expected), len(lines), lines)
+			}
+
+			ids := make(map[string]bool)
+			for i, line := range lines {
+				expected := test.expected[i]
+				if expected.ID != "" && line.ID != expected.ID {
+					t.Errorf("Line %d: expected ID %q, got %q", i, expected.ID, line.ID)
+				}
+				if line.ID == "" || ids[line.ID] {
+					t.Errorf("Line %d: expected a unique ID, got %q", i, line.ID)
+				}
+				ids[line.ID] = true
+
+				if !line.Date.Equal(expected.Date) || line.Amount != expected.Amount ||
+					line.Currency != expected.Currency || line.Description != expected.Description {
+					t.Errorf("Line %d: expected %+v, got %+v", i, expected, line)
+				}
+			}
+
+			again, _ := statement.Parse(strings.NewReader(test.data), test.format)
+			for i := range again {
+				if again[i].ID != lines[i].ID {
+					t.Errorf("Line %d: expected the same ID when parsing again, got %q and %q", i, lines[i].ID, again[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		testName string
+		format   string
+		data     string
+	}{
+		{"Not OFX", statement.FormatOFX, "date,amount\n"},
+		{"OFX without an amount", statement.FormatOFX, "<OFX><STMTTRN><DTPOSTED>20240511</STMTTRN></OFX>"},
+		{"QIF with an invalid date", statement.FormatQIF, "!Type:Bank\nD13/45/2024\nT-1\n^\n"},
+		{"QIF without an end", statement.FormatQIF, "!Type:Bank\nD05/11/2024\nT-1\n"},
+		{"Invalid CAMT.053", statement.FormatCAMT053, "<Document><BkToCstmrStmt>"},
+		{"Unknown format", "mt940", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			if _, err := statement.Parse(strings.NewReader(test.data), test.format); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	for fileName, expected := range map[string]string{
+		"May.OFX":              statement.FormatOFX,
+		"card.qfx":             statement.FormatOFX,
+		"export.qif":           statement.FormatQIF,
+		"camt053_20240531.xml": statement.FormatCAMT053,
+		"statement.csv":        "",
+	} {
+		if format := statement.FormatOf(fileName); format != expected {
+			t.Errorf("Expected the format of %s to be %q, got %q", fileName, expected, format)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package telegram
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -9,8 +10,15 @@ import (
 
 // BotInterface defines the interface for interacting with Telegram
 type BotInterface interface {
-	GetUpdates() tgbotapi.UpdatesChannel
-	SendMessage(chatID int64, text string) error
+	GetUpdates(ctx context.Context, offset int) ([]tgbotapi.Update, error)
+	SendMessage(ctx context.Context, chatID int64, text string) error
+	SendMessageWithKeyboard(ctx context.Context, chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) error
+	EditMessage(ctx context.Context, chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error
+	AnswerCallbackQuery(ctx context.Context, callbackQueryID string, text string) error
+	SendDocument(ctx context.Context, chatID int64, fileName string, data []byte, caption string) error
+	SendPhoto(ctx context.Context, chatID int64, fileName string, data []byte, caption string) error
+	GetChat(ctx context.Context, chatID int64) (*tgbotapi.Chat, error)
+	SelfID() int64
 }
 
 // telegramBot implements the TelegramBot interface
@@ -18,34 +26,168 @@ type telegramBot struct {
 	bot *tgbotapi.BotAPI
 }
 
-// NewTelegramBot creates a new instance of TelegramBot
+// NewTelegramBot creates a new instance of TelegramBot. Requests go to the
+// Bot API at TELEGRAM_API_ENDPOINT, a format string taking the token and the
+// method name like tgbotapi.APIEndpoint, which is used when it isn't set.
+// TELEGRAM_DEBUG=true logs the requests.
 func NewTelegramBot() (BotInterface, error) {
-	bot, err := tgbotapi.NewBotAPI(os.Getenv("TELEGRAM_BOT_TOKEN"))
+	endpoint := os.Getenv("TELEGRAM_API_ENDPOINT")
+	if endpoint == "" {
+		endpoint = tgbotapi.APIEndpoint
+	}
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint(os.Getenv("TELEGRAM_BOT_TOKEN"), endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bot: %w", err)
 	}
 
-	bot.Debug = true
+	// Debug logging includes every request, with the tokens and login links
+	// sent to users, so it is off unless asked for
+	bot.Debug = os.Getenv("TELEGRAM_DEBUG") == "true"
 
 	return &telegramBot{
 		bot: bot,
 	}, nil
 }
 
-// GetUpdates retrieves updates from Telegram
-func (t *telegramBot) GetUpdates() tgbotapi.UpdatesChannel {
-	fmt.Println("Setting up update channel...")
-	u := tgbotapi.NewUpdate(0)
+// GetUpdates long-polls Telegram for updates starting at the given offset.
+// Telegram considers all updates before the offset as handled and won't
+// return them again.
+//
+// When the context is cancelled GetUpdates returns right away; the pending
+// poll is abandoned and its result discarded.
+func (t *telegramBot) GetUpdates(ctx context.Context, offset int) ([]tgbotapi.Update, error) {
+	u := tgbotapi.NewUpdate(offset)
 	u.Timeout = 60
-	return t.bot.GetUpdatesChan(u)
+
+	type result struct {
+		updates []tgbotapi.Update
+		err     error
+	}
+	results := make(chan result, 1)
+
+	go func() {
+		updates, err := t.bot.GetUpdates(u)
+		results <- result{updates, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-results:
+		if r.err != nil {
+			return nil, fmt.Errorf("failed to get updates: %w", r.err)
+		}
+		return r.updates, nil
+	}
+}
+
+// GetChat returns the chat with the ID, like to tell groups from channels
+// when only the ID is known
+func (t *telegramBot) GetChat(ctx context.Context, chatID int64) (*tgbotapi.Chat, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get chat: %w", err)
+	}
+
+	chat, err := t.bot.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat: %w", err)
+	}
+	return &chat, nil
+}
+
+// SelfID returns the user ID of the bot
+func (t *telegramBot) SelfID() int64 {
+	return t.bot.Self.ID
 }
 
 // SendMessage sends a message to a Telegram chat
-func (t *telegramBot) SendMessage(chatID int64, text string) error {
+func (t *telegramBot) SendMessage(ctx context.Context, chatID int64, text string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	_, err := t.bot.Send(msg)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+// SendMessageWithKeyboard sends a message with inline buttons below it
+func (t *telegramBot) SendMessageWithKeyboard(ctx context.Context, chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
 	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
 	_, err := t.bot.Send(msg)
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
 	return nil
 }
+
+// EditMessage replaces the text and inline buttons of a message sent by the
+// bot. Without a keyboard the buttons are removed.
+func (t *telegramBot) EditMessage(ctx context.Context, chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to edit message: %w", err)
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ReplyMarkup = keyboard
+	_, err := t.bot.Send(edit)
+	if err != nil {
+		return fmt.Errorf("failed to edit message: %w", err)
+	}
+	return nil
+}
+
+// AnswerCallbackQuery acknowledges a press of an inline button, optionally
+// showing a notification with text
+func (t *telegramBot) AnswerCallbackQuery(ctx context.Context, callbackQueryID string, text string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to answer callback query: %w", err)
+	}
+
+	_, err := t.bot.Request(tgbotapi.NewCallback(callbackQueryID, text))
+	if err != nil {
+		return fmt.Errorf("failed to answer callback query: %w", err)
+	}
+	return nil
+}
+
+// SendDocument sends data as a file named fileName to a Telegram chat, with
+// an optional caption below it
+func (t *telegramBot) SendDocument(ctx context.Context, chatID int64, fileName string, data []byte, caption string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to send document: %w", err)
+	}
+
+	document := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: fileName, Bytes: data})
+	document.Caption = caption
+	_, err := t.bot.Send(document)
+	if err != nil {
+		return fmt.Errorf("failed to send document: %w", err)
+	}
+	return nil
+}
+
+// SendPhoto sends an image named fileName, like a PNG chart, to a Telegram
+// chat, with an optional caption below it
+func (t *telegramBot) SendPhoto(ctx context.Context, chatID int64, fileName string, data []byte, caption string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to send photo: %w", err)
+	}
+
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: fileName, Bytes: data})
+	photo.Caption = caption
+	_, err := t.bot.Send(photo)
+	if err != nil {
+		return fmt.Errorf("failed to send photo: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kiasaty/spendings-tracker/internal/testutils"
+)
+
+func newTestBot(t *testing.T) (BotInterface, *testutils.FakeTelegramServer) {
+	t.Helper()
+
+	server := testutils.NewFakeTelegramServer(t)
+	t.Setenv("TELEGRAM_API_ENDPOINT", server.Endpoint())
+	t.Setenv("TELEGRAM_BOT_TOKEN", "test-token")
+
+	bot, err := NewTelegramBot()
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	return bot, server
+}
+
+func TestGetUpdates(t *testing.T) {
+	ctx := context.Background()
+	bot, server := newTestBot(t)
+
+	server.QueueUpdates(
+		testutils.NewTestUpdate(1, 100, "Lunch 25"),
+		testutils.NewTestUpdate(2, 100, "Taxi 12"),
+	)
+
+	updates, err := bot.GetUpdates(ctx, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(updates) != 2 || updates[0].Message.Text != "Lunch 25" || updates[1].UpdateID != 2 {
+		t.Fatalf("Expected both updates, got %+v", updates)
+	}
+
+	// The offset acknowledges the updates before it
+	updates, err = bot.GetUpdates(ctx, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(updates) != 1 || updates[0].UpdateID != 2 {
+		t.Fatalf("Expected only the second update, got %+v", updates)
+	}
+}
+
+func TestGetUpdatesReturnsWhenCancelled(t *testing.T) {
+	bot, _ := newTestBot(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := bot.GetUpdates(ctx, 0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context's error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected GetUpdates to return right away, took %v", elapsed)
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	bot, server := newTestBot(t)
+
+	if err := bot.SendMessage(context.Background(), 100, "Hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	messages := server.SentMessages()
+	if len(messages) != 1 || messages[0].ChatID != 100 || messages[0].Text != "Hello" {
+		t.Errorf("Expected the message to be sent, got %+v", messages)
+	}
+
+	// Telegram rejects empty messages
+	if err := bot.SendMessage(context.Background(), 100, ""); err == nil {
+		t.Error("Expected an error for an empty message")
+	}
+}
+
+func TestGetChat(t *testing.T) {
+	bot, server := newTestBot(t)
+	server.AddChat(tgbotapi.Chat{ID: -100, Type: "supergroup", Title: "Flat"})
+
+	chat, err := bot.GetChat(context.Background(), -100)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if chat.ID != -100 || !chat.IsSuperGroup() || chat.Title != "Flat" {
+		t.Errorf("Expected the supergroup, got %+v", chat)
+	}
+
+	if _, err := bot.GetChat(context.Background(), -200); err == nil {
+		t.Error("Expected an error for an unknown chat")
+	}
+}
+
+func TestSendDocument(t *testing.T) {
+	bot, server := newTestBot(t)
+
+	if err := bot.SendDocument(context.Background(), 100, "spendings.csv", []byte("cost\n12.50\n"), "Spendings"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	documents := server.Documents()
+	if len(documents) != 1 {
+		t.Fatalf("Expected the document to be sent, got %+v", documents)
+	}
+	document := documents[0]
+	if document.ChatID != 100 || document.FileName != "spendings.csv" || string(document.Data) != "cost\n12.50\n" || document.Caption != "Spendings" {
+		t.Errorf("Expected the document to be sent, got %+v", document)
+	}
+}
+
+func TestSendPhoto(t *testing.T) {
+	bot, server := newTestBot(t)
+
+	if err := bot.SendPhoto(context.Background(), 100, "chart.png", []byte("\x89PNG"), "Spendings by tag"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	photos := server.Photos()
+	if len(photos) != 1 {
+		t.Fatalf("Expected the photo to be sent, got %+v", photos)
+	}
+	photo := photos[0]
+	if photo.ChatID != 100 || photo.FileName != "chart.png" || string(photo.Data) != "\x89PNG" || photo.Caption != "Spendings by tag" {
+		t.Errorf("Expected the photo to be sent, got %+v", photo)
+	}
+	if len(server.Documents()) != 0 {
+		t.Errorf("Expected no documents, got %+v", server.Documents())
+	}
+}